@@ -0,0 +1,148 @@
+// File: cmd/octobackup/backups.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Browsing what is on the remote. octobackup list prints the backups the
+//   configured strategy finds there (images, rsync snapshots, borg archives,
+//   zfs/btrfs snapshots) with dates and sizes, as a table or JSON. The TUI
+//   Backups page (b on the welcome screen) shows the same list and starts a
+//   restore (Enter), a checksum verify (v, or V to also decode), or a delete
+//   (x, confirmed with a second x) of the selected backup. octobackup verify
+//   is the headless verify.
+
+package main
+
+import (
+    context "context"
+    json "encoding/json"
+    fmt "fmt"
+    os "os"
+    os_signal "os/signal"
+    syscall "syscall"
+    time "time"
+
+    tea "github.com/charmbracelet/bubbletea"
+
+    "cloudcurio.cc/octobackup/internal/backend"
+    "cloudcurio.cc/octobackup/internal/config"
+)
+
+func cmdList(args []string) int {
+    fs, cfgFile := newFlagSet("list")
+    job := jobFlag(fs)
+    strategy := fs.String("strategy", "", "override the configured strategy")
+    asJSON := fs.Bool("json", false, "print the backups as a JSON array")
+    if err := fs.Parse(args); err != nil { return exitUsage }
+
+    cfg, err := loadHeadlessConfig(*cfgFile, *job, *strategy)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+    b, _ := backend.Get(cfg.Strategy)
+
+    ctx, stop := os_signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+    as, err := b.List(ctx, cfg)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+
+    if *asJSON {
+        if as == nil { as = []backend.Artifact{} }
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        if err := enc.Encode(as); err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+        return exitOK
+    }
+    if len(as) == 0 { fmt.Fprintf(os.Stderr, "no %s backups on %s\n", cfg.Strategy, cfg.RemoteHost); return exitOK }
+    printArtifacts(as)
+    return exitOK
+}
+
+func cmdVerify(args []string) int {
+    fs, cfgFile := newFlagSet("verify [backup]")
+    job := jobFlag(fs)
+    strategy := fs.String("strategy", "", "override the configured strategy")
+    decode := fs.Bool("decode", false, "also decrypt and decompress, checking the raw checksum (reads the whole backup back)")
+    all := fs.Bool("all", false, "verify every backup on the remote")
+    if err := fs.Parse(args); err != nil { return exitUsage }
+    if fs.NArg() > 1 || (*all && fs.NArg() > 0) { fmt.Fprintln(os.Stderr, "octobackup: give one backup name, or --all"); return exitUsage }
+
+    cfg, err := loadHeadlessConfig(*cfgFile, *job, *strategy)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+    b, _ := backend.Get(cfg.Strategy)
+
+    ctx, stop := os_signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    var as []backend.Artifact
+    if *all {
+        if as, err = b.List(ctx, cfg); err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+    } else {
+        a, err := backend.Resolve(ctx, cfg, fs.Arg(0))
+        if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+        as = []backend.Artifact{a}
+    }
+    sink := backend.Sink{
+        Stdout:   lineWriter(os.Stdout),
+        Stderr:   lineWriter(os.Stderr),
+        Info:     lineWriter(os.Stderr),
+        Progress: progressWriter(os.Stderr),
+    }
+    failed := 0
+    for _, a := range as {
+        fmt.Fprintf(os.Stderr, "Verifying %s…\n", a.Path)
+        err := backend.Verify(ctx, cfg, a, backend.VerifyOptions{Decode: *decode}, sink)
+        if ctx.Err() != nil { fmt.Fprintln(os.Stderr, "octobackup: interrupted; verify stopped"); return exitInterrupted }
+        if err != nil { failed++; fmt.Fprintf(os.Stderr, "✗ %s: %v\n", a.Name, err) }
+    }
+    if failed > 0 { fmt.Fprintf(os.Stderr, "octobackup: %d of %d backup(s) failed verification\n", failed, len(as)); return exitFailure }
+    fmt.Fprintf(os.Stderr, "✔ %d backup(s) verified\n", len(as))
+    return exitOK
+}
+
+// printArtifacts prints one line per backup, oldest first.
+func printArtifacts(as []backend.Artifact) {
+    for _, a := range as {
+        fmt.Printf("%-32s  %s  %10s  %s\n", a.Name, a.Time.Format("2006-01-02 15:04"), humanBytes(a.Size), a.Path)
+    }
+}
+
+// --------------------------- TUI ---------------------------
+
+type deleteDoneMsg struct{ a backend.Artifact; err error }
+
+// backupsKey handles the Backups page's action keys; the bool reports
+// whether the key was consumed. Enter (restore) goes through Update.
+func (m model) backupsKey(key string) (model, tea.Cmd, bool) {
+    it, ok := m.backupList.SelectedItem().(artifactItem)
+    if key != "x" { m.confirmDelete = "" }
+    switch key {
+    case "v", "V":
+        if !ok { return m, nil, true }
+        m.restore.Artifact = it.a
+        m.runKind = "Verify"
+        mm, cmd := m.beginRun(m.runVerify(backend.VerifyOptions{Decode: key == "V"}))
+        return mm.(model), cmd, true
+    case "x":
+        if !ok { return m, nil, true }
+        if m.confirmDelete != it.a.Path {
+            m.confirmDelete = it.a.Path
+            return m, nil, true
+        }
+        m.confirmDelete = ""
+        m.backupList.Title = "Deleting " + it.a.Name + "…"
+        return m, deleteArtifact(m.cfg, it.a), true
+    }
+    return m, nil, false
+}
+
+// deleteArtifact removes a from the remote with the backend's Deleter.
+func deleteArtifact(cfg config.Config, a backend.Artifact) tea.Cmd {
+    return func() tea.Msg {
+        b, err := backend.Get(cfg.Strategy)
+        if err != nil { return deleteDoneMsg{a: a, err: err} }
+        d, ok := b.(backend.Deleter)
+        if !ok { return deleteDoneMsg{a: a, err: fmt.Errorf("%s backups cannot be deleted", cfg.Strategy)} }
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+        defer cancel()
+        return deleteDoneMsg{a: a, err: d.Delete(ctx, cfg, a)}
+    }
+}
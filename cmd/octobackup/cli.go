@@ -0,0 +1,407 @@
+// File: cmd/octobackup/cli.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Headless subcommands so cron, systemd and CI can drive backups without a
+//   TTY. They load the same Config as the TUI, run the same preflight and
+//   strategy logic, print logs to stdout/stderr and return exit codes.
+//
+// Usage:
+//   octobackup                      start the TUI (default)
+//   octobackup run [job]            preflight, then stream a backup (every job when none is named)
+//   octobackup preflight [job]      run preflight checks only
+//   octobackup list [flags]         list the backups on the remote
+//   octobackup verify [backup]      check a backup against its manifest sums
+//   octobackup scrub [backup]       re-hash an rsync backup against its file index
+//   octobackup file-history <path>  when a file changed across rsync snapshots
+//   octobackup restore [flags]      pick a backup and stream it back
+//   octobackup prune [flags]        delete backups the retention policy drops
+//   octobackup history [--json]     list recorded runs from the local catalog
+//   octobackup daemon [flags]       run scheduled backups (systemd service)
+//   octobackup install-timer [job]  install systemd units for a job (--user)
+//   octobackup uninstall-timer [job] remove them again
+//   octobackup config show [flags]  print the config (or --job's settings) as YAML
+//
+// Commands on one job's backups take --job; it may be left out when the
+// config has a single job.
+//
+// Exit codes:
+//   0 success • 1 backup/restore failed • 2 usage error • 3 config error
+//   4 preflight failed • 130 interrupted (SIGINT/SIGTERM)
+
+package main
+
+import (
+    context "context"
+    errors "errors"
+    flag "flag"
+    fmt "fmt"
+    io "io"
+    os "os"
+    os_signal "os/signal"
+    strings "strings"
+    syscall "syscall"
+    time "time"
+
+    "gopkg.in/yaml.v3"
+
+    "cloudcurio.cc/octobackup/internal/backend"
+    "cloudcurio.cc/octobackup/internal/config"
+)
+
+const (
+    exitOK          = 0
+    exitFailure     = 1
+    exitUsage       = 2
+    exitConfig      = 3
+    exitPreflight   = 4
+    exitInterrupted = 130
+)
+
+const cliUsage = `usage: octobackup [command] [flags]
+
+Commands:
+  tui            start the interactive TUI (default)
+  run [job]      run preflight checks, then the job's backup (all jobs if none named)
+  preflight [job]
+                 run preflight checks only
+  list           list the backups on the remote (--json for scripts)
+  verify         check a backup against its checksums (--decode, --all)
+  scrub          re-hash an rsync backup against its file index (--all)
+  file-history   show a file in every indexed rsync backup
+  restore        restore a backup from the remote
+  prune          apply the retention policy (--dry-run to preview)
+  history        list recorded runs (--json for scripts)
+  daemon         run backups on the configured schedule
+  install-timer  write and enable a systemd timer for a job (--print to preview)
+  uninstall-timer
+                 disable and remove a job's systemd timer
+  config show    print the config as YAML (--job: one job's effective settings)
+  help           show this help
+
+Commands on a job's backups take --job when the config has several jobs.
+Run "octobackup <command> -h" for command flags.
+`
+
+// runCLI dispatches a headless subcommand and returns the process exit code.
+func runCLI(args []string) int {
+    switch args[0] {
+    case "tui":
+        fs, cfgFile := newFlagSet("tui")
+        if err := fs.Parse(args[1:]); err != nil { return exitUsage }
+        return runTUI(*cfgFile)
+    case "run":
+        return cmdRun(args[1:])
+    case "preflight":
+        return cmdPreflight(args[1:])
+    case "list":
+        return cmdList(args[1:])
+    case "verify":
+        return cmdVerify(args[1:])
+    case "scrub":
+        return cmdScrub(args[1:])
+    case "file-history":
+        return cmdFileHistory(args[1:])
+    case "restore":
+        return cmdRestore(args[1:])
+    case "prune":
+        return cmdPrune(args[1:])
+    case "history":
+        return cmdHistory(args[1:])
+    case "daemon":
+        return cmdDaemon(args[1:])
+    case "install-timer":
+        return cmdInstallTimer(args[1:])
+    case "uninstall-timer":
+        return cmdUninstallTimer(args[1:])
+    case "config":
+        if len(args) < 2 || args[1] != "show" {
+            fmt.Fprintln(os.Stderr, "usage: octobackup config show [--config file]")
+            return exitUsage
+        }
+        return cmdConfigShow(args[2:])
+    case "help", "-h", "--help":
+        fmt.Print(cliUsage)
+        return exitOK
+    }
+    fmt.Fprintf(os.Stderr, "octobackup: unknown command %q\n\n%s", args[0], cliUsage)
+    return exitUsage
+}
+
+// newFlagSet returns a flag set carrying the shared --config flag.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+    fs := flag.NewFlagSet("octobackup "+name, flag.ContinueOnError)
+    cfgFile := fs.String("config", config.Path(), "config file")
+    return fs, cfgFile
+}
+
+// parseJobArgs parses flags on either side of a job name, so both
+// "run --config f home" and "run home --config f" work.
+func parseJobArgs(fs *flag.FlagSet, args []string) error {
+    var pos []string
+    for {
+        if err := fs.Parse(args); err != nil { return err }
+        if fs.NArg() == 0 { break }
+        pos, args = append(pos, fs.Arg(0)), fs.Args()[1:]
+    }
+    return fs.Parse(append([]string{"--"}, pos...))
+}
+
+// jobFlag adds --job for commands that act on one job of the config.
+func jobFlag(fs *flag.FlagSet) *string {
+    return fs.String("job", "", "job to act on (default: the only job)")
+}
+
+// loadHeadlessFile loads the config file for a headless command. Unlike the
+// TUI, a missing or broken file is an error: defaults point at someone
+// else's host.
+func loadHeadlessFile(p string) (config.File, error) {
+    f, err := config.LoadFile(p)
+    if err != nil { return f, fmt.Errorf("load %s: %w", p, err) }
+    return f, nil
+}
+
+// loadHeadlessConfig loads one job's config: the named job, or the only one
+// when job is "", with its strategy optionally overridden.
+func loadHeadlessConfig(p string, job string, strategy string) (config.Config, error) {
+    f, err := loadHeadlessFile(p)
+    if err != nil { return config.Config{}, err }
+    j, err := f.Job(job)
+    if err != nil { return config.Config{}, err }
+    if strategy != "" { j.Strategy = config.Strategy(strategy) }
+    if _, err := backend.Get(j.Strategy); err != nil { return j.Config, fmt.Errorf("job %s: %w", j.Name, err) }
+    return j.Config, nil
+}
+
+// headlessJobs resolves the optional [job] argument of run and preflight:
+// that job, or every job in file order.
+func headlessJobs(p string, args []string, strategy string) ([]config.Job, error) {
+    if len(args) > 1 { return nil, fmt.Errorf("want at most one job name") }
+    f, err := loadHeadlessFile(p)
+    if err != nil { return nil, err }
+    jobs := f.Jobs
+    if len(args) == 1 {
+        j, err := f.Job(args[0])
+        if err != nil { return nil, err }
+        jobs = []config.Job{j}
+    }
+    if strategy != "" && len(jobs) > 1 { return nil, fmt.Errorf("--strategy needs a job name when the config has several jobs") }
+    for i := range jobs {
+        if strategy != "" { jobs[i].Strategy = config.Strategy(strategy) }
+        if _, err := backend.Get(jobs[i].Strategy); err != nil { return nil, fmt.Errorf("job %s: %w", jobs[i].Name, err) }
+    }
+    return jobs, nil
+}
+
+func cmdRun(args []string) int {
+    fs, cfgFile := newFlagSet("run [job]")
+    strategy := fs.String("strategy", "", "override the configured strategy")
+    skipPreflight := fs.Bool("skip-preflight", false, "do not run preflight checks first")
+    if err := parseJobArgs(fs, args); err != nil { return exitUsage }
+
+    jobs, err := headlessJobs(*cfgFile, fs.Args(), *strategy)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+
+    ctx, stop := os_signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    if len(jobs) == 1 { return runHeadlessJob(ctx, jobs[0], *skipPreflight) }
+    failed := 0
+    for _, j := range jobs {
+        fmt.Fprintf(os.Stderr, "== job %s (%s) ==\n", j.Name, j.Strategy)
+        switch runHeadlessJob(ctx, j, *skipPreflight) {
+        case exitOK:
+        case exitInterrupted:
+            return exitInterrupted
+        default:
+            failed++
+        }
+    }
+    if failed > 0 { fmt.Fprintf(os.Stderr, "octobackup: %d of %d jobs failed\n", failed, len(jobs)); return exitFailure }
+    return exitOK
+}
+
+// runHeadlessJob runs one job headless: preflight, then the backup under the job's
+// lock, recorded in the catalog.
+func runHeadlessJob(ctx context.Context, j config.Job, skipPreflight bool) int {
+    cfg := j.Config
+    if !skipPreflight {
+        ok, report := backend.Preflight(ctx, cfg)
+        fmt.Fprint(os.Stderr, report)
+        if !ok { fmt.Fprintln(os.Stderr, "octobackup: preflight failed"); return exitPreflight }
+    }
+
+    // never alongside a scheduled run of the same job
+    release, err := lockJob(j.Name)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+    defer release()
+
+    b, _ := backend.Get(cfg.Strategy)
+    sink, finish := recordRun(j.Name, cfg, backend.Sink{
+        Stdout:   lineWriter(os.Stdout),
+        Stderr:   lineWriter(os.Stderr),
+        Info:     lineWriter(os.Stderr),
+        Progress: progressWriter(os.Stderr),
+    })
+    err = b.Run(ctx, cfg, sink)
+    finish(err)
+    switch {
+    case ctx.Err() != nil:
+        fmt.Fprintln(os.Stderr, "octobackup: interrupted; run aborted")
+        return exitInterrupted
+    case err != nil:
+        fmt.Fprintln(os.Stderr, "octobackup: backup failed:", err)
+        return exitFailure
+    }
+    fmt.Fprintln(os.Stderr, "✔ Backup complete")
+    return exitOK
+}
+
+func cmdPreflight(args []string) int {
+    fs, cfgFile := newFlagSet("preflight [job]")
+    strategy := fs.String("strategy", "", "override the configured strategy")
+    if err := parseJobArgs(fs, args); err != nil { return exitUsage }
+
+    jobs, err := headlessJobs(*cfgFile, fs.Args(), *strategy)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+
+    code := exitOK
+    for _, j := range jobs {
+        if len(jobs) > 1 { fmt.Printf("== job %s (%s) ==\n", j.Name, j.Strategy) }
+        ok, report := backend.Preflight(context.Background(), j.Config)
+        fmt.Print(report)
+        if !ok { code = exitPreflight }
+    }
+    return code
+}
+
+func cmdRestore(args []string) int {
+    fs, cfgFile := newFlagSet("restore")
+    job := jobFlag(fs)
+    strategy := fs.String("strategy", "", "override the configured strategy")
+    name := fs.String("backup", "latest", "backup name to restore (see --list)")
+    target := fs.String("target", "", "disk, directory or dataset to restore into")
+    listOnly := fs.Bool("list", false, "list available backups and exit")
+    yes := fs.Bool("yes", false, "confirm overwriting the target")
+    skipPreflight := fs.Bool("skip-preflight", false, "do not run restore safety checks first")
+    if err := fs.Parse(args); err != nil { return exitUsage }
+
+    cfg, err := loadHeadlessConfig(*cfgFile, *job, *strategy)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+    b, _ := backend.Get(cfg.Strategy)
+
+    ctx, stop := os_signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    if *listOnly {
+        as, err := b.List(ctx, cfg)
+        if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+        printArtifacts(as)
+        return exitOK
+    }
+    if *target == "" { fmt.Fprintln(os.Stderr, "octobackup: --target is required"); return exitUsage }
+
+    a, err := backend.Resolve(ctx, cfg, *name)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+    opts := backend.RestoreOptions{Artifact: a, Target: *target}
+
+    if !*skipPreflight {
+        ok, report := backend.PreflightRestore(ctx, cfg, opts)
+        fmt.Fprint(os.Stderr, report)
+        if !ok { fmt.Fprintln(os.Stderr, "octobackup: restore preflight failed"); return exitPreflight }
+    }
+    if !*yes {
+        fmt.Fprintf(os.Stderr, "octobackup: would restore %s into %s; re-run with --yes to proceed\n", a.Path, *target)
+        return exitUsage
+    }
+
+    sink := backend.Sink{
+        Stdout:   lineWriter(os.Stdout),
+        Stderr:   lineWriter(os.Stderr),
+        Info:     lineWriter(os.Stderr),
+        Progress: progressWriter(os.Stderr),
+    }
+    err = b.Restore(ctx, cfg, opts, sink)
+    switch {
+    case ctx.Err() != nil:
+        fmt.Fprintln(os.Stderr, "octobackup: interrupted; run aborted")
+        return exitInterrupted
+    case err != nil:
+        fmt.Fprintln(os.Stderr, "octobackup: restore failed:", err)
+        return exitFailure
+    }
+    fmt.Fprintln(os.Stderr, "✔ Restore complete")
+    return exitOK
+}
+
+func cmdPrune(args []string) int {
+    fs, cfgFile := newFlagSet("prune")
+    job := jobFlag(fs)
+    strategy := fs.String("strategy", "", "override the configured strategy")
+    dryRun := fs.Bool("dry-run", false, "only show what would be deleted")
+    if err := fs.Parse(args); err != nil { return exitUsage }
+
+    cfg, err := loadHeadlessConfig(*cfgFile, *job, *strategy)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+    if cfg.Retention.IsZero() { fmt.Fprintln(os.Stderr, "octobackup: no retention policy in", *cfgFile); return exitConfig }
+
+    ctx, stop := os_signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    err = backend.Prune(ctx, cfg, *dryRun, os.Stdout)
+    switch {
+    case ctx.Err() != nil:
+        fmt.Fprintln(os.Stderr, "octobackup: interrupted; prune stopped")
+        return exitInterrupted
+    case err != nil:
+        fmt.Fprintln(os.Stderr, "octobackup: prune failed:", err)
+        return exitFailure
+    }
+    if *dryRun { fmt.Fprintln(os.Stderr, "(dry run; nothing deleted)") }
+    return exitOK
+}
+
+func cmdConfigShow(args []string) int {
+    fs, cfgFile := newFlagSet("config show")
+    job := fs.String("job", "", "print this job's effective settings instead of the file")
+    if err := fs.Parse(args); err != nil { return exitUsage }
+
+    f, err := config.LoadFile(*cfgFile)
+    if err != nil {
+        if !errors.Is(err, os.ErrNotExist) { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+        fmt.Fprintf(os.Stderr, "# %s not found; showing defaults\n", *cfgFile)
+    } else if f.Migrated {
+        fmt.Fprintf(os.Stderr, "# %s is a single-job file; shown as the jobs list it is saved as\n", *cfgFile)
+    }
+    var b []byte
+    if *job != "" {
+        var j config.Job
+        if j, err = f.Job(*job); err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+        b, err = yaml.Marshal(j.Config)
+    } else {
+        b, err = f.Marshal()
+    }
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+    fmt.Print(string(b))
+    return exitOK
+}
+
+// progressInterval throttles headless progress lines so journald and CI logs
+// stay readable.
+const progressInterval = 10 * time.Second
+
+// progressWriter prints a progress line to w at most every progressInterval.
+func progressWriter(w io.Writer) func(backend.Progress) {
+    var last time.Time
+    return func(p backend.Progress) {
+        if time.Since(last) < progressInterval { return }
+        last = time.Now()
+        fmt.Fprintln(w, "progress:", progressLine(p))
+    }
+}
+
+// lineWriter prints one log line per call to w.
+func lineWriter(w io.Writer) func(string) {
+    return func(line string) { fmt.Fprintln(w, strings.TrimRight(line, "\r")) }
+}
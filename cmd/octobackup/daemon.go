@@ -0,0 +1,226 @@
+// File: cmd/octobackup/daemon.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   octobackup daemon: a long-running scheduler for the systemd unit. Each
+//   job with a schedule runs in the background when it comes due, delayed by
+//   a random jitter so a fleet does not hit the backup host at once.
+//
+//   Missed runs are caught up anacron-style: the last start of every job is
+//   kept in the state dir, and a job whose next slot after that start has
+//   already passed (the machine was off or asleep) runs once, right away.
+//   A job never run before waits for its first slot. Due times are checked
+//   against the wall clock every 30s, so suspend does not stretch them.
+//
+//   Runs of one job never overlap: a slot that comes due while the previous
+//   run is still going is skipped, and a per-job lock file also keeps a
+//   manual "octobackup run" from starting alongside a scheduled one.
+
+package main
+
+import (
+    context "context"
+    json "encoding/json"
+    errors "errors"
+    fmt "fmt"
+    rand "math/rand"
+    os "os"
+    os_signal "os/signal"
+    path_file "path/filepath"
+    strings "strings"
+    syscall "syscall"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/backend"
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/schedule"
+)
+
+// daemonTick is how often the daemon compares due times to the clock.
+const daemonTick = 30 * time.Second
+
+// daemonJob is one scheduled backup and its timing.
+type daemonJob struct {
+    name    string
+    cfg     config.Config
+    sched   *schedule.Schedule
+    jitter  time.Duration
+    next    time.Time
+    running bool
+}
+
+// daemonJobs builds the scheduled jobs of f; jobs without a schedule are
+// left to manual runs.
+func daemonJobs(f config.File) ([]*daemonJob, error) {
+    var jobs []*daemonJob
+    for _, fj := range f.Jobs {
+        cfg := fj.Config
+        if cfg.Schedule == "" { continue }
+        if _, err := backend.Get(cfg.Strategy); err != nil { return nil, fmt.Errorf("job %s: %w", fj.Name, err) }
+        sc, err := schedule.Parse(cfg.Schedule)
+        if err != nil { return nil, fmt.Errorf("job %s: %w", fj.Name, err) }
+        j := &daemonJob{name: fj.Name, cfg: cfg, sched: sc}
+        if cfg.ScheduleJitter != "" {
+            if j.jitter, err = time.ParseDuration(cfg.ScheduleJitter); err != nil || j.jitter < 0 {
+                return nil, fmt.Errorf("job %s: schedule_jitter %q: want a duration such as 10m", fj.Name, cfg.ScheduleJitter)
+            }
+        }
+        jobs = append(jobs, j)
+    }
+    if len(jobs) == 0 { return nil, fmt.Errorf("no job has a schedule (schedule: daily, \"0 3 * * *\", …)") }
+    return jobs, nil
+}
+
+// slot is the schedule time t plus this job's random jitter.
+func (j *daemonJob) slot(t time.Time) time.Time {
+    if j.jitter <= 0 { return t }
+    return t.Add(time.Duration(rand.Int63n(int64(j.jitter))))
+}
+
+// start sets the first due time from the job's last recorded start.
+func (j *daemonJob) start(now, last time.Time) string {
+    if last.IsZero() {
+        j.next = j.slot(j.sched.Next(now))
+        return fmt.Sprintf("first run %s", j.next.Format(time.DateTime))
+    }
+    if missed := j.sched.Next(last); !missed.After(now) {
+        j.next = j.slot(now)
+        return fmt.Sprintf("missed %s; catching up at %s", missed.Format(time.DateTime), j.next.Format(time.DateTime))
+    }
+    j.next = j.slot(j.sched.Next(last))
+    return fmt.Sprintf("next run %s", j.next.Format(time.DateTime))
+}
+
+func cmdDaemon(args []string) int {
+    fs, cfgFile := newFlagSet("daemon")
+    if err := fs.Parse(args); err != nil { return exitUsage }
+
+    f, err := loadHeadlessFile(*cfgFile)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+    jobs, err := daemonJobs(f)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+
+    ctx, stop := os_signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    state := loadDaemonState()
+    now := time.Now()
+    for _, j := range jobs {
+        fmt.Fprintf(os.Stderr, "[%s] %s: %s\n", j.name, j.sched, j.start(now, state[j.name]))
+    }
+
+    type result struct {
+        job     *daemonJob
+        started time.Time
+        err     error
+    }
+    done := make(chan result)
+    running := 0
+    tick := time.NewTicker(daemonTick)
+    defer tick.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            fmt.Fprintf(os.Stderr, "octobackup: stopping; waiting for %d running job(s)\n", running)
+            for ; running > 0; running-- { <-done }
+            return exitOK
+        case r := <-done:
+            running--
+            r.job.running = false
+            state[r.job.name] = r.started
+            if err := saveDaemonState(state); err != nil { fmt.Fprintln(os.Stderr, "octobackup: save state:", err) }
+            if r.err != nil { fmt.Fprintf(os.Stderr, "[%s] ✗ failed: %v\n", r.job.name, r.err) } else { fmt.Fprintf(os.Stderr, "[%s] ✔ complete\n", r.job.name) }
+            fmt.Fprintf(os.Stderr, "[%s] next run %s\n", r.job.name, r.job.next.Format(time.DateTime))
+        case <-tick.C:
+        }
+
+        now := time.Now()
+        for _, j := range jobs {
+            if now.Before(j.next) { continue }
+            if j.running {
+                fmt.Fprintf(os.Stderr, "[%s] still running; skipping the run due %s\n", j.name, j.next.Format(time.DateTime))
+                j.next = j.slot(j.sched.Next(now))
+                continue
+            }
+            j.running = true
+            j.next = j.slot(j.sched.Next(now))
+            running++
+            go func(j *daemonJob, started time.Time) {
+                done <- result{job: j, started: started, err: runScheduled(ctx, j.name, j.cfg, "["+j.name+"] ")}
+            }(j, now)
+        }
+    }
+}
+
+// runScheduled runs one backup headless under the job's lock: preflight, then the
+// strategy, logging to stderr with prefix and recording it in the catalog.
+func runScheduled(ctx context.Context, name string, cfg config.Config, prefix string) error {
+    release, err := lockJob(name)
+    if err != nil { return err }
+    defer release()
+
+    log := prefixWriter(prefix)
+    sink, finish := recordRun(name, cfg, backend.Sink{
+        Stdout:   log,
+        Stderr:   log,
+        Info:     log,
+        Progress: progressWriter(os.Stderr),
+    })
+    sink.Info(fmt.Sprintf("starting %s backup", cfg.Strategy))
+    err = scheduledBackup(ctx, cfg, sink)
+    finish(err)
+    return err
+}
+
+// scheduledBackup runs preflight, recording its report, then the backup.
+func scheduledBackup(ctx context.Context, cfg config.Config, sink backend.Sink) error {
+    ok, report := backend.Preflight(ctx, cfg)
+    if !ok {
+        for _, line := range strings.Split(strings.TrimRight(report, "\n"), "\n") { sink.Info(line) }
+        return fmt.Errorf("preflight failed")
+    }
+    b, err := backend.Get(cfg.Strategy)
+    if err != nil { return err }
+    return b.Run(ctx, cfg, sink)
+}
+
+// prefixWriter prints one prefixed log line per call to stderr.
+func prefixWriter(prefix string) func(string) {
+    w := lineWriter(os.Stderr)
+    return func(line string) { w(prefix + line) }
+}
+
+// --------------------------- STATE ---------------------------
+
+// daemonStatePath holds each job's last start time.
+func daemonStatePath() string { return path_file.Join(config.StateDir(), "daemon.json") }
+
+func loadDaemonState() map[string]time.Time {
+    state := map[string]time.Time{}
+    if b, err := os.ReadFile(daemonStatePath()); err == nil { _ = json.Unmarshal(b, &state) }
+    return state
+}
+
+// saveDaemonState writes the state through a rename so a crash never
+// leaves it half written.
+func saveDaemonState(state map[string]time.Time) error {
+    b, err := json.MarshalIndent(state, "", "  ")
+    if err != nil { return err }
+    tmp := daemonStatePath() + ".tmp"
+    if err := os.WriteFile(tmp, b, 0o600); err != nil { return err }
+    return os.Rename(tmp, daemonStatePath())
+}
+
+// lockJob takes the job's lock file in the state dir, failing at once when
+// another process holds it. The lock dies with the process.
+func lockJob(name string) (release func(), err error) {
+    f, err := os.OpenFile(path_file.Join(config.StateDir(), "job-"+name+".lock"), os.O_CREATE|os.O_RDWR, 0o600)
+    if err != nil { return nil, err }
+    if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+        f.Close()
+        if errors.Is(err, syscall.EWOULDBLOCK) { return nil, fmt.Errorf("job %s is already running", name) }
+        return nil, fmt.Errorf("lock job %s: %w", name, err)
+    }
+    return func() { f.Close() }, nil
+}
@@ -0,0 +1,178 @@
+// File: cmd/octobackup/history.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Run history. recordRun puts every backup, whether started by run, the
+//   daemon or the TUI, into the local catalog (internal/catalog) by wrapping
+//   the run's Sink; octobackup history prints the catalog as a table or
+//   JSON, and the TUI History page (h on the welcome screen) browses it.
+
+package main
+
+import (
+    bufio "bufio"
+    context "context"
+    json "encoding/json"
+    errors "errors"
+    fmt "fmt"
+    os "os"
+    strings "strings"
+
+    tea "github.com/charmbracelet/bubbletea"
+    "github.com/charmbracelet/bubbles/list"
+
+    "cloudcurio.cc/octobackup/internal/backend"
+    "cloudcurio.cc/octobackup/internal/catalog"
+    "cloudcurio.cc/octobackup/internal/config"
+)
+
+// recordRun starts a catalog entry for a backup of cfg as job and returns
+// sink wrapped to tee log lines, bytes and artifacts into it, plus the func
+// that records the outcome. A catalog that cannot be written is reported on
+// sink but never fails the backup.
+func recordRun(job string, cfg config.Config, sink backend.Sink) (backend.Sink, func(error)) {
+    run, err := catalog.Begin(job, cfg)
+    if err != nil {
+        if sink.Info != nil { sink.Info("catalog: " + err.Error() + " (run not recorded)") }
+        return sink, func(error) {}
+    }
+    tee := func(fn func(string)) func(string) {
+        return func(line string) {
+            run.Line(line)
+            if fn != nil { fn(line) }
+        }
+    }
+    progress, artifact := sink.Progress, sink.Artifact
+    wrapped := backend.Sink{
+        Stdout: tee(sink.Stdout),
+        Stderr: tee(sink.Stderr),
+        Info:   tee(sink.Info),
+        Progress: func(p backend.Progress) {
+            run.Bytes(p.Bytes)
+            if progress != nil { progress(p) }
+        },
+        Artifact: func(path string) {
+            run.Artifact(path)
+            if artifact != nil { artifact(path) }
+        },
+    }
+    return wrapped, func(err error) {
+        status := catalog.OK
+        switch {
+        case errors.Is(err, backend.ErrAborted) || errors.Is(err, context.Canceled):
+            status = catalog.Aborted
+        case err != nil:
+            status = catalog.Failed
+        }
+        if _, cerr := run.Finish(status, err); cerr != nil && sink.Info != nil { sink.Info("catalog: " + cerr.Error()) }
+    }
+}
+
+func cmdHistory(args []string) int {
+    fs, _ := newFlagSet("history")
+    asJSON := fs.Bool("json", false, "print the records as a JSON array")
+    job := fs.String("job", "", "only this job's runs")
+    limit := fs.Int("n", 20, "show the last n runs (0 = all)")
+    if err := fs.Parse(args); err != nil { return exitUsage }
+
+    recs, err := catalog.List()
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+    recs = filterHistory(recs, *job, *limit)
+
+    if *asJSON {
+        if recs == nil { recs = []catalog.Record{} }
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetIndent("", "  ")
+        if err := enc.Encode(recs); err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+        return exitOK
+    }
+    if len(recs) == 0 { fmt.Fprintln(os.Stderr, "no runs recorded in", catalog.Path()); return exitOK }
+    fmt.Printf("%-16s  %-10s  %-10s  %-11s  %8s  %10s  %s\n", "START", "JOB", "STRATEGY", "STATUS", "TIME", "BYTES", "ARTIFACT")
+    for _, r := range recs {
+        fmt.Printf("%-16s  %-10s  %-10s  %-11s  %8s  %10s  %s\n", r.Start.Format("2006-01-02 15:04"), r.Job, r.Strategy, r.Status, r.Duration(), humanBytes(r.Bytes), historyArtifacts(r))
+    }
+    return exitOK
+}
+
+// filterHistory keeps job's runs (all when job is ""), the last limit of
+// them when limit > 0.
+func filterHistory(recs []catalog.Record, job string, limit int) []catalog.Record {
+    var out []catalog.Record
+    for _, r := range recs {
+        if job == "" || r.Job == job { out = append(out, r) }
+    }
+    if limit > 0 && len(out) > limit { out = out[len(out)-limit:] }
+    return out
+}
+
+// historyArtifacts is a one-line summary of what a run produced.
+func historyArtifacts(r catalog.Record) string {
+    switch {
+    case len(r.Artifacts) > 0:
+        return strings.Join(r.Artifacts, ", ")
+    case r.Error != "":
+        return r.Error
+    }
+    return "-"
+}
+
+// --------------------------- TUI ---------------------------
+
+// historyItem shows a run on the History page.
+type historyItem struct{ r catalog.Record }
+func (i historyItem) Title() string {
+    return fmt.Sprintf("%s  %s  %s", i.r.Start.Format("2006-01-02 15:04"), i.r.Job, historyStatus(i.r.Status))
+}
+func (i historyItem) Description() string {
+    return fmt.Sprintf("%s • %s • %s • %s", i.r.Strategy, i.r.Duration(), humanBytes(i.r.Bytes), historyArtifacts(i.r))
+}
+func (i historyItem) FilterValue() string { return i.r.Job + " " + string(i.r.Strategy) + " " + string(i.r.Status) }
+
+func historyStatus(s catalog.Status) string {
+    switch s {
+    case catalog.OK:
+        return "✔ ok"
+    case catalog.Running:
+        return "… running"
+    }
+    return warnStyle.Render("✗ " + string(s))
+}
+
+type (
+    historyMsg    struct{ recs []catalog.Record; err error }
+    historyLogMsg struct{ lines []string; err error }
+)
+
+func (m model) loadHistory() tea.Cmd {
+    return func() tea.Msg {
+        recs, err := catalog.List()
+        return historyMsg{recs: recs, err: err}
+    }
+}
+
+// setHistory fills the History list, newest first.
+func (m *model) setHistory(msg historyMsg) tea.Cmd {
+    if msg.err != nil {
+        m.historyList.Title = warnStyle.Render("Reading the catalog failed: " + msg.err.Error())
+        return nil
+    }
+    items := make([]list.Item, 0, len(msg.recs))
+    for i := len(msg.recs) - 1; i >= 0; i-- { items = append(items, historyItem{msg.recs[i]}) }
+    m.historyList.Title = fmt.Sprintf("Run history (%d runs)", len(items))
+    return m.historyList.SetItems(items)
+}
+
+// loadRunLog reads a run's log file for the log view.
+func loadRunLog(path string) tea.Cmd {
+    return func() tea.Msg {
+        f, err := os.Open(path)
+        if err != nil { return historyLogMsg{err: err} }
+        defer f.Close()
+        var lines []string
+        sc := bufio.NewScanner(f)
+        sc.Buffer(make([]byte, 64*1024), 1024*1024)
+        for sc.Scan() { lines = append(lines, sc.Text()) }
+        return historyLogMsg{lines: lines, err: sc.Err()}
+    }
+}
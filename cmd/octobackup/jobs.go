@@ -0,0 +1,111 @@
+// File: cmd/octobackup/jobs.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-17
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   The TUI job picker (j on the welcome screen). It lists the config's jobs
+//   with their strategy, remote and schedule; Enter makes one the job the
+//   strategy picker, config form, Backups page and runs work on, and n adds
+//   a job that starts from the shared defaults. Submitting the config form
+//   saves that job alone back into the file, leaving the others as they are.
+
+package main
+
+import (
+    fmt "fmt"
+    strings "strings"
+
+    tea "github.com/charmbracelet/bubbletea"
+    "github.com/charmbracelet/bubbles/list"
+    "github.com/charmbracelet/bubbles/textinput"
+
+    "cloudcurio.cc/octobackup/internal/config"
+)
+
+// jobItem shows a job in the picker.
+type jobItem struct{ j config.Job }
+func (i jobItem) Title() string { return i.j.Name }
+func (i jobItem) Description() string {
+    sched := i.j.Schedule
+    if sched == "" { sched = "manual" }
+    return fmt.Sprintf("%s • %s@%s:%s • %s", i.j.Strategy, i.j.RemoteUser, i.j.RemoteHost, i.j.RemotePath, sched)
+}
+func (i jobItem) FilterValue() string { return i.j.Name + " " + string(i.j.Strategy) }
+
+// selectJob makes j the job the TUI works on and loads it into the form.
+func (m *model) selectJob(j config.Job) {
+    m.job, m.cfg = j.Name, j.Config
+    cfg := j.Config
+    for i, v := range []string{
+        cfg.RemoteUser, cfg.RemoteHost, fmt.Sprintf("%d", cfg.SSHPort), cfg.RemotePath, cfg.Compression,
+        fmt.Sprintf("%d", cfg.BandwidthKbps), cfg.SourceDisk, cfg.BorgRepo, cfg.BorgPassEnv,
+    } {
+        m.inputs[i].SetValue(v)
+    }
+}
+
+// loadJobs fills the picker from the file, the current job selected.
+func (m *model) loadJobs() tea.Cmd {
+    items := make([]list.Item, 0, len(m.file.Jobs))
+    sel := 0
+    for i, j := range m.file.Jobs {
+        if j.Name == m.job { sel = i }
+        items = append(items, jobItem{j})
+    }
+    m.jobList.Title = fmt.Sprintf("%d jobs in %s", len(items), m.cfgFile)
+    cmd := m.jobList.SetItems(items)
+    m.jobList.Select(sel)
+    return cmd
+}
+
+// jobsKey handles the picker's keys; the bool reports whether the key was
+// consumed.
+func (m model) jobsKey(key string) (model, tea.Cmd, bool) {
+    switch key {
+    case "enter":
+        it, ok := m.jobList.SelectedItem().(jobItem)
+        if !ok { return m, nil, true }
+        m.selectJob(it.j)
+        m.page = pageIntro
+        return m, nil, true
+    case "n":
+        m.jobName.SetValue("")
+        m.jobName.Placeholder = "job name (e.g., home, disk)"
+        m.jobName.Focus()
+        m.page = pageNewJob
+        return m, textinput.Blink, true
+    }
+    return m, nil, false
+}
+
+// addJob adds a job named by the name field, starting from the defaults, and
+// goes on to pick its strategy. It is saved with the config form.
+func (m model) addJob() (model, tea.Cmd) {
+    name := strings.TrimSpace(m.jobName.Value())
+    if _, err := m.file.Job(name); config.CheckJobName(name) != nil || err == nil {
+        m.jobName.Placeholder = "pick a new name without spaces, slashes or @"
+        m.jobName.SetValue("")
+        return m, nil
+    }
+    j := config.Job{Name: name, Config: m.file.Defaults}
+    m.file.SetJob(j)
+    m.selectJob(j)
+    m.page = pageSelect
+    return m, nil
+}
+
+// saveJob writes the current job back into the config file.
+func (m *model) saveJob() error {
+    m.file.SetJob(config.Job{Name: m.job, Config: m.cfg})
+    if err := config.SaveFile(m.cfgFile, m.file); err != nil { return err }
+    m.file.Migrated = false
+    return nil
+}
+
+// jobIndex is the current job's position in the file.
+func (m model) jobIndex() int {
+    for i, j := range m.file.Jobs {
+        if j.Name == m.job { return i }
+    }
+    return 0
+}
@@ -9,59 +9,80 @@
 //   encrypted deduplicated, and ZFS/Btrfs snapshot streaming when available),
 //   with preflight checks, live logs, and a neon CloudCurio theme.
 //
-//   This is a single-file app for easy drop-in usage. It features:
+//   The TUI lives in this file, headless subcommands in cli.go and the
+//   strategies in internal/backend. It features:
+//     • Job picker: named jobs with shared defaults, each saved on its own
 //     • Strategy picker (dd|rsync|borg|zfs|btrfs)
 //     • Config form (remote, port, path, compression, bandwidth, excludes)
 //     • Preflight validator (tools, disk selection, SSH reachability)
-//     • Live run view (spinner/progress + streaming command logs)
-//     • Saves/loads config to ~/.config/cloudcurio/octobackup.yaml
+//     • Live run view (percent, bytes, throughput, ETA + streaming command logs)
+//     • Pause (p), resume (r) and cancel (c c) a running job; q cancels & quits
+//     • Backups page: the remote's backups with restore, verify and delete
+//     • Restore wizard (pick a remote backup, pick a target, safety checks)
+//     • Run history from the local catalog, with each run's log
+//     • Saves/loads jobs in ~/.config/cloudcurio/octobackup.yaml
 //
 // Inputs:
-//   Interactive via TUI.
+//   Interactive via TUI, or headless: octobackup run|preflight|list|verify|scrub|file-history|restore|prune|history|daemon|install-timer|config show.
 // Outputs:
 //   Streams backups over SSH to your homelab path and prints run logs.
 //
 // Build:
-//   $ mkdir -p cmd/octobackup && put this file there as main.go
-//   $ cd cmd/octobackup
-//   $ go mod init cloudcurio.cc/octobackup
-//   $ go get github.com/charmbracelet/bubbles@v0.18.0 \
-//          github.com/charmbracelet/bubbletea@v0.26.7 \
-//          github.com/charmbracelet/lipgloss@v0.10.0 \
-//          gopkg.in/yaml.v3@v3.0.1
-//   $ go build -o octobackup
-//   $ ./octobackup
+//   $ go build -o octobackup ./cmd/octobackup   (or scripts/build.sh)
+//   $ ./octobackup            # TUI
+//   $ ./octobackup run        # headless (cron/systemd/CI); run <job> for one job
 //
 // Notes:
-//   • Requires Go 1.21+.
-//   • The app will try to use: ssh, rsync, dd, gzip/pigz, pv, lsblk, borg, zfs, btrfs.
+//   • Requires Go 1.22+.
+//   • The app will try to use: ssh, rsync, dd, gzip/pigz, lsblk, borg, zfs, btrfs.
 //   • Safe by default: you must pick the correct source disk (for raw dd) and confirm.
 //
-// Restore (quick hints):
-//   • dd image:  ssh homelab 'cat image.gz' | gunzip | sudo dd of=/dev/sdX bs=64K status=progress
-//   • rsync dir: rsync -aAXHv remote:/backups/host/ /mnt/target/
-//   • borg:      borg mount repo::snapshot /mnt && copy; or borg extract repo::snapshot
-//   • zfs/btrfs: receive snapshot and promote/clone as needed.
+// Restore:
+//   TUI: press b on the welcome screen. Headless:
+//   $ octobackup list
+//   $ octobackup restore --backup latest --target /dev/sdX --yes
+//   See packaging/examples/RESTORE.md for what each strategy runs.
 //
 // Security:
 //   • SSH only; can specify alternate port. Optional Borg encryption.
 //   • Never stores secrets in plaintext; config omits passwords.
 //
 // Mod Log:
+//   0.27.0 2026-10-17 Source lists with per-source excludes, one_file_system and mount selection for rsync/borg.
+//   0.26.0 2026-10-17 Named jobs with shared defaults; job picker, run <job>, per-job daemon and timers.
+//   0.25.0 2026-10-17 Sparse dd images (dd_sparse): zero runs and filesystem free space are not sent.
+//   0.24.0 2026-10-17 Block-level incremental dd images: block maps, deltas, chained restore and prune.
+//   0.23.0 2026-10-16 Resumable chunked dd images (dd_chunk_size); restore and verify per chunk.
+//   0.22.0 2026-10-16 Optional per-file index of rsync backups; octobackup scrub and file-history.
+//   0.21.0 2026-10-16 sha256/BLAKE3 sums of raw and stored streams in manifests; octobackup verify.
+//   0.20.0 2026-10-16 Backups page and list command; verify and delete from the TUI.
+//   0.19.0 2026-10-16 Local run catalog in ~/.local/state/cloudcurio; history command and TUI page.
+//   0.18.0 2026-10-16 install-timer/uninstall-timer: generated systemd service+timer units with credentials.
+//   0.17.0 2026-10-16 octobackup daemon: cron/calendar schedules, catch-up, jitter, no overlapping runs.
+//   0.16.0 2026-10-16 Rsync snapshots mode: dated --link-dest directories and an atomic latest symlink.
+//   0.15.0 2026-10-16 Btrfs send -p from the confirmed parent; snapshot dir; several subvolumes; stale cleanup.
+//   0.14.0 2026-10-16 ZFS incremental replication from the newest common snapshot; recv -s resume.
+//   0.13.0 2026-10-16 Retention policy (keep last/daily/weekly/monthly/yearly) and prune with --dry-run.
+//   0.12.0 2026-10-16 Atomic remote writes: .partial + fsync + rename; timestamped images; completion markers.
+//   0.11.0 2026-10-16 Client-side age/age-passphrase/AES-GCM encryption of dd images and zfs/btrfs streams.
+//   0.10.0 2026-10-16 In-process zstd/lz4/xz/gzip for dd images; codec-named files; manifest sidecars.
+//   0.9.0 2026-10-16  Shell-free stage pipelines; remote arguments quoted; failures name the stage.
+//   0.8.0 2026-10-16  Pause/resume/cancel runs by process group; cleanup of partial backups.
+//   0.7.0 2026-10-16  Run output streams into the TUI over a channel subscription; bounded log buffer.
+//   0.6.0 2026-10-16  Real progress from byte counting, rsync progress2 and borg log-json.
+//   0.5.0 2026-10-16  Restore wizard and headless restore for every strategy.
+//   0.4.0 2026-10-16  Strategies moved to pluggable internal/backend; config to internal/config.
+//   0.3.0 2026-10-16  Headless run/preflight/config show subcommands with exit codes.
 //   0.2.0 2025-10-01  Repo-packaged; headless flags; sample systemd; docs.
 //   0.1.0 2025-09-30  Initial release.
 
 package main
 
 import (
-    bufio "bufio"
-    bytes "bytes"
     context "context"
+    errors "errors"
     fmt "fmt"
-    io "io"
     os "os"
-    os_exec "os/exec"
-    path_file "path/filepath"
     strings "strings"
     time "time"
 
@@ -71,7 +92,10 @@ import (
     "github.com/charmbracelet/bubbles/spinner"
     "github.com/charmbracelet/bubbles/textinput"
     "github.com/charmbracelet/lipgloss"
-    "gopkg.in/yaml.v3"
+
+    "cloudcurio.cc/octobackup/internal/backend"
+    "cloudcurio.cc/octobackup/internal/catalog"
+    "cloudcurio.cc/octobackup/internal/config"
 )
 
 // --------------------------- THEME (Lip Gloss) ---------------------------
@@ -102,95 +126,8 @@ const asciiLogo = `
          CloudCurio • OctoBackup — Neon Octopus Edition
 `
 
-// --------------------------- CONFIG ---------------------------
-
-type Strategy string
-
-const (
-    StratDD    Strategy = "raw-dd"
-    StratRsync Strategy = "rsync"
-    StratBorg  Strategy = "borg"
-    StratZFS   Strategy = "zfs-send"
-    StratBtrfs Strategy = "btrfs-send"
-)
-
-type Config struct {
-    RemoteUser    string   `yaml:"remote_user"`
-    RemoteHost    string   `yaml:"remote_host"`
-    SSHPort       int      `yaml:"ssh_port"`
-    RemotePath    string   `yaml:"remote_path"`
-    Strategy      Strategy `yaml:"strategy"`
-    SourceDisk    string   `yaml:"source_disk"` // for dd/zfs roots; empty for rsync/borg
-    Compression   string   `yaml:"compression"` // gzip|pigz|none
-    BandwidthKbps int      `yaml:"bandwidth_kbps"` // 0 = unlimited
-    Excludes      []string `yaml:"excludes"` // for rsync
-    BorgRepo      string   `yaml:"borg_repo"` // ssh://user@host:/path/repo
-    BorgPassEnv   string   `yaml:"borg_pass_env"` // env var name holding passphrase
-}
-
-func defaultConfig() Config {
-    return Config{
-        RemoteUser:    "cbwinslow",
-        RemoteHost:    "cbwdellr720.cloudcurio.cc",
-        SSHPort:       22,
-        RemotePath:    "/backups/$(hostname)",
-        Strategy:      StratRsync,
-        Compression:   "pigz",
-        BandwidthKbps: 0,
-        Excludes: []string{
-            "/dev/*", "/proc/*", "/sys/*", "/tmp/*", "/run/*", "/mnt/*", "/media/*", "/lost+found",
-        },
-        BorgRepo:    "ssh://cbwinslow@cbwdellr720.cloudcurio.cc:/backups/borg/$(hostname)",
-        BorgPassEnv: "BORG_PASSPHRASE",
-    }
-}
-
-func configPath() string {
-    cfgDir := path_file.Join(os.Getenv("HOME"), ".config", "cloudcurio")
-    _ = os.MkdirAll(cfgDir, 0o700)
-    return path_file.Join(cfgDir, "octobackup.yaml")
-}
-
-func loadConfig() (Config, error) {
-    p := configPath()
-    b, err := os.ReadFile(p)
-    if err != nil {
-        return defaultConfig(), err
-    }
-    var c Config
-    if err := yaml.Unmarshal(b, &c); err != nil {
-        return defaultConfig(), err
-    }
-    return c, nil
-}
-
-func saveConfig(c Config) error {
-    b, err := yaml.Marshal(c)
-    if err != nil { return err }
-    return os.WriteFile(configPath(), b, 0o600)
-}
-
 // --------------------------- UTIL ---------------------------
 
-func have(cmd string) bool {
-    _, err := os_exec.LookPath(cmd)
-    return err == nil
-}
-
-func runCmd(ctx context.Context, name string, args ...string) (*os_exec.Cmd, io.ReadCloser, io.ReadCloser, error) {
-    cmd := os_exec.CommandContext(ctx, name, args...)
-    stdout, err := cmd.StdoutPipe()
-    if err != nil { return nil, nil, nil, err }
-    stderr, err := cmd.StderrPipe()
-    if err != nil { return nil, nil, nil, err }
-    if err := cmd.Start(); err != nil { return nil, nil, nil, err }
-    return cmd, stdout, stderr, nil
-}
-
-func sshTarget(c Config) string {
-    return fmt.Sprintf("-p% d %s@%s", c.SSHPort, c.RemoteUser, c.RemoteHost)
-}
-
 func renderKeyVal(k, v string) string {
     return lipgloss.JoinHorizontal(lipgloss.Top,
         labelStyle.Render(k+":"),
@@ -199,6 +136,26 @@ func renderKeyVal(k, v string) string {
     )
 }
 
+// humanBytes formats n with binary units; 0 renders as "-" (unknown).
+func humanBytes(n int64) string {
+    if n <= 0 { return "-" }
+    const unit = 1024
+    if n < unit { return fmt.Sprintf("%d B", n) }
+    div, exp := int64(unit), 0
+    for v := n / unit; v >= unit; v /= unit { div *= unit; exp++ }
+    return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// progressLine renders "bytes / total • rate • ETA" for a transfer.
+func progressLine(p backend.Progress) string {
+    if p.Bytes == 0 && p.Total == 0 { return "waiting for data…" }
+    parts := []string{humanBytes(p.Bytes)}
+    if p.Total > 0 { parts[0] += " / " + humanBytes(p.Total) + fmt.Sprintf(" (%.0f%%)", p.Percent()*100) }
+    if p.Rate > 0 { parts = append(parts, humanBytes(int64(p.Rate))+"/s") }
+    if eta := p.ETA(); eta > 0 { parts = append(parts, "ETA "+eta.String()) }
+    return strings.Join(parts, " • ")
+}
+
 // --------------------------- TUI MODEL ---------------------------
 
 type page int
@@ -209,20 +166,37 @@ const (
     pageConfig
     pagePreflight
     pageRun
+    pageBackups
+    pageRestoreTarget
+    pageRestorePreflight
+    pageHistory
+    pageHistoryLog
+    pageJobs
+    pageNewJob
 )
 
 type item string
 func (i item) FilterValue() string { return string(i) }
 
+// artifactItem shows a remote backup on the Backups page.
+type artifactItem struct{ a backend.Artifact }
+func (i artifactItem) Title() string       { return i.a.Name }
+func (i artifactItem) Description() string { return i.a.Time.Format("2006-01-02 15:04") + " • " + humanBytes(i.a.Size) + " • " + i.a.Path }
+func (i artifactItem) FilterValue() string { return i.a.Group + " " + i.a.Name }
+
 // messages
 type (
     preflightDoneMsg struct{ ok bool; report string; err error }
-    runLogMsg        struct{ line string }
-    runDoneMsg       struct{ err error }
+    artifactsMsg     struct{ items []backend.Artifact; err error }
 )
 
 type model struct {
-    cfg         Config
+    cfg         config.Config  // the current job's settings
+    file        config.File    // every job, saved back with the current one
+    cfgFile     string
+    job         string
+    jobList     list.Model
+    jobName     textinput.Model
     width       int
     height      int
     page        page
@@ -234,19 +208,29 @@ type model struct {
     inputs      []*textinput.Model
     focusIndex  int
 
-    logLines    []string
-    cancel      context.CancelFunc
-    startTime   time.Time
+    backupList  list.Model
+    target      textinput.Model
+    restore     backend.RestoreOptions // also the backup being verified
+    runKind     string // "Backup", "Restore" or "Verify": what the run page shows
+    confirmDelete string // path of the backup x was pressed on once
+
+    historyList list.Model
+    historyRec  catalog.Record // run whose log is shown
+
+    logs        logBuffer
+    stats       backend.Progress
+    preflightOK bool
+    running     bool
+    state       runState
+    run         *runHandle // current run, nil when idle
+    confirmCancel bool
+    quitAfterRun  bool
 }
 
-func newModel(cfg Config) model {
-    items := []list.Item{
-        item("Raw disk stream (dd → ssh)"),
-        item("Rsync file-level (fast/smart)"),
-        item("Borg encrypted (dedup/incremental)"),
-        item("ZFS snapshot send/recv"),
-        item("Btrfs snapshot send/recv"),
-    }
+func newModel(f config.File, cfgFile string) model {
+    cfg := f.Jobs[0].Config
+    var items []list.Item
+    for _, b := range backend.All() { items = append(items, item(b.Describe())) }
     lst := list.New(items, list.NewDefaultDelegate(), 0, 0)
     lst.Title = "Choose a backup strategy"
     sp := spinner.New()
@@ -266,14 +250,24 @@ func newModel(cfg Config) model {
         mk("remote host", cfg.RemoteHost),
         mk("ssh port", fmt.Sprintf("%d", cfg.SSHPort)),
         mk("remote path", cfg.RemotePath),
-        mk("compression (pigz|gzip|none)", cfg.Compression),
+        mk("compression (zstd|lz4|xz|gzip|none)", cfg.Compression),
         mk("bandwidth kbps (0=unlimited)", fmt.Sprintf("%d", cfg.BandwidthKbps)),
         mk("source disk (e.g., /dev/sda)", cfg.SourceDisk),
         mk("borg repo (ssh://…)", cfg.BorgRepo),
         mk("borg pass env (e.g., BORG_PASSPHRASE)", cfg.BorgPassEnv),
     }
 
-    return model{cfg: cfg, list: lst, spinner: sp, progress: pr, inputs: inputs, page: pageIntro}
+    rl := list.New(nil, list.NewDefaultDelegate(), 0, 0)
+    rl.Title = "Backups"
+    tgt := textinput.New()
+    tgt.Prompt = "➤ "
+    hl := list.New(nil, list.NewDefaultDelegate(), 0, 0)
+    hl.Title = "Run history"
+    jl := list.New(nil, list.NewDefaultDelegate(), 0, 0)
+    jn := textinput.New()
+    jn.Prompt = "➤ "
+
+    return model{cfg: cfg, file: f, cfgFile: cfgFile, job: f.Jobs[0].Name, jobList: jl, jobName: jn, list: lst, backupList: rl, target: tgt, historyList: hl, spinner: sp, progress: pr, inputs: inputs, page: pageIntro, logs: newLogBuffer(maxLogLines)}
 }
 
 func (m model) Init() tea.Cmd { return nil }
@@ -283,24 +277,92 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
     case tea.WindowSizeMsg:
         m.width, m.height = msg.Width, msg.Height
         m.list.SetSize(m.width-8, m.height-12)
+        m.backupList.SetSize(m.width-8, m.height-12)
+        m.historyList.SetSize(m.width-8, m.height-12)
+        m.jobList.SetSize(m.width-8, m.height-12)
         return m, nil
     case tea.KeyMsg:
+        if m.page == pageRun && m.run != nil {
+            if mm, cmd, ok := m.runKey(msg.String()); ok { return mm, cmd }
+        }
+        if m.page == pageBackups {
+            if m.backupList.SettingFilter() { break }
+            if mm, cmd, ok := m.backupsKey(msg.String()); ok { return mm, cmd }
+        }
+        if m.page == pageJobs {
+            if m.jobList.SettingFilter() { break }
+            if mm, cmd, ok := m.jobsKey(msg.String()); ok { return mm, cmd }
+        }
         switch msg.String() {
-        case "ctrl+c", "q":
+        case "ctrl+c":
             return m, tea.Quit
+        case "q":
+            // q is a letter while typing into a field
+            if m.page != pageConfig && m.page != pageRestoreTarget && m.page != pageNewJob { return m, tea.Quit }
+        case "r", "b":
+            if m.page == pageIntro {
+                m.page = pageBackups
+                cmd := m.loadArtifacts()
+                return m, cmd
+            }
+        case "j":
+            if m.page == pageIntro {
+                m.page = pageJobs
+                return m, m.loadJobs()
+            }
+        case "h":
+            if m.page == pageIntro {
+                m.page = pageHistory
+                m.historyList.Title = "Loading run history…"
+                return m, m.loadHistory()
+            }
+        case "esc":
+            switch m.page {
+            case pageRun:
+                if m.run != nil { return m, nil }
+                m.page = pageIntro
+                if m.runKind == "Verify" { m.page = pageBackups }
+                return m, nil
+            case pageBackups:
+                if m.backupList.FilterState() == list.Unfiltered {
+                    m.page = pageIntro
+                    return m, nil
+                }
+            case pageHistory:
+                if m.historyList.FilterState() == list.Unfiltered {
+                    m.page = pageIntro
+                    return m, nil
+                }
+            case pageHistoryLog:
+                m.page = pageHistory
+                return m, nil
+            case pageJobs:
+                if m.jobList.FilterState() == list.Unfiltered {
+                    m.page = pageIntro
+                    return m, nil
+                }
+            case pageNewJob:
+                m.page = pageJobs
+                return m, nil
+            case pageRestoreTarget:
+                m.page = pageBackups
+                return m, nil
+            case pagePreflight:
+                if m.running { return m, nil }
+                m.page = pageConfig
+                return m, nil
+            case pageRestorePreflight:
+                m.page = pageRestoreTarget
+                return m, nil
+            }
         case "enter":
             switch m.page {
             case pageIntro:
                 m.page = pageSelect
                 return m, nil
             case pageSelect:
-                i := m.list.Index()
-                switch i {
-                case 0: m.cfg.Strategy = StratDD
-                case 1: m.cfg.Strategy = StratRsync
-                case 2: m.cfg.Strategy = StratBorg
-                case 3: m.cfg.Strategy = StratZFS
-                case 4: m.cfg.Strategy = StratBtrfs
+                if i := m.list.Index(); i >= 0 && i < len(backend.All()) {
+                    m.cfg.Strategy = backend.All()[i].Strategy()
                 }
                 m.page = pageConfig
                 return m, nil
@@ -315,15 +377,45 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                 m.cfg.SourceDisk = m.inputs[6].Value()
                 m.cfg.BorgRepo = m.inputs[7].Value()
                 m.cfg.BorgPassEnv = m.inputs[8].Value()
-                _ = saveConfig(m.cfg)
+                if err := m.saveJob(); err != nil { m.logs.Append(warnStyle.Render("Saving " + m.cfgFile + " failed: " + err.Error())) }
                 m.page = pagePreflight
+                m.preflightOK = false
                 return m, m.doPreflight()
             case pagePreflight:
-                m.page = pageRun
-                m.logLines = nil
-                m.progress.SetPercent(0)
-                m.spinner.Start()
-                return m, m.runBackup()
+                // as for restore: only start once the checks passed
+                if !m.preflightOK || m.running { return m, nil }
+                m.runKind = "Backup"
+                return m.beginRun(m.runBackup())
+            case pageBackups:
+                it, ok := m.backupList.SelectedItem().(artifactItem)
+                if !ok { return m, nil }
+                m.restore.Artifact = it.a
+                m.target.Placeholder = restoreTargetHint(m.cfg.Strategy)
+                m.target.Focus()
+                m.page = pageRestoreTarget
+                return m, textinput.Blink
+            case pageRestoreTarget:
+                m.restore.Target = strings.TrimSpace(m.target.Value())
+                if m.restore.Target == "" { return m, nil }
+                m.page = pageRestorePreflight
+                m.logs.Reset()
+                m.preflightOK = false
+                return m, m.doRestorePreflight()
+            case pageRestorePreflight:
+                // destructive: only proceed once the safety checks passed
+                if !m.preflightOK || m.running { return m, nil }
+                m.runKind = "Restore"
+                return m.beginRun(m.runRestore())
+            case pageNewJob:
+                mm, cmd := m.addJob()
+                return mm, cmd
+            case pageHistory:
+                it, ok := m.historyList.SelectedItem().(historyItem)
+                if !ok { return m, nil }
+                m.historyRec = it.r
+                m.logs.Reset()
+                m.page = pageHistoryLog
+                return m, loadRunLog(it.r.Log)
             }
         case "tab":
             if m.page == pageConfig {
@@ -332,28 +424,62 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
                 m.inputs[m.focusIndex].Focus()
             }
         }
+    case artifactsMsg:
+        if msg.err != nil {
+            m.backupList.Title = warnStyle.Render("Listing backups failed: " + msg.err.Error())
+            return m, nil
+        }
+        items := make([]list.Item, 0, len(msg.items))
+        // newest first
+        for i := len(msg.items) - 1; i >= 0; i-- { items = append(items, artifactItem{msg.items[i]}) }
+        m.backupList.Title = fmt.Sprintf("%d %s backups of job %s on %s", len(items), m.cfg.Strategy, m.job, m.cfg.RemoteHost)
+        return m, m.backupList.SetItems(items)
+    case deleteDoneMsg:
+        if msg.err != nil {
+            m.backupList.Title = warnStyle.Render("Deleting " + msg.a.Name + " failed: " + msg.err.Error())
+            return m, nil
+        }
+        cmd := m.loadArtifacts()
+        return m, cmd
+    case historyMsg:
+        return m, m.setHistory(msg)
+    case historyLogMsg:
+        if msg.err != nil { m.logs.Append(warnStyle.Render("Reading the log failed: " + msg.err.Error())) }
+        m.logs.Append(msg.lines...)
+        return m, nil
     case preflightDoneMsg:
-        m.logLines = append(m.logLines, strings.Split(msg.report, "\n")...)
+        m.preflightOK = msg.ok && msg.err == nil
+        m.logs.Append(strings.Split(msg.report, "\n")...)
         if !msg.ok || msg.err != nil {
-            m.logLines = append(m.logLines, warnStyle.Render(fmt.Sprintf("Preflight failed: %v", msg.err)))
+            m.logs.Append(warnStyle.Render(fmt.Sprintf("Preflight failed: %v", msg.err)))
         }
         return m, nil
     case runLogMsg:
-        m.logLines = append(m.logLines, msg.line)
-        // naive progress tick
-        p := m.progress.Percent() + 0.002
-        if p > 0.98 { p = 0.98 }
-        m.progress.SetPercent(p)
-        return m, nil
+        m.logs.Append(msg.lines...)
+        if msg.next != nil { return m.Update(msg.next) }
+        return m, waitForRun(m.run.events)
+    case runProgressMsg:
+        m.stats = msg.p
+        return m, tea.Batch(m.progress.SetPercent(msg.p.Percent()), waitForRun(m.run.events))
+    case runStateMsg:
+        // the model owns paused/cancelling; the run reports start and outcome
+        if msg.state != stateRunning || m.state != stateCancelling { m.state = msg.state }
+        return m, waitForRun(m.run.events)
     case runDoneMsg:
-        m.spinner.Stop()
-        if msg.err != nil {
-            m.logLines = append(m.logLines, warnStyle.Render("Run finished with error: ")+msg.err.Error())
+        m.running = false
+        m.run = nil
+        m.confirmCancel = false
+        if m.quitAfterRun { return m, tea.Quit }
+        var cmd tea.Cmd
+        if errors.Is(msg.err, backend.ErrAborted) {
+            m.logs.Append(warnStyle.Render("✖ " + m.runKind + " aborted"))
+        } else if msg.err != nil {
+            m.logs.Append(warnStyle.Render("Run finished with error: ")+msg.err.Error())
         } else {
-            m.progress.SetPercent(1)
-            m.logLines = append(m.logLines, lipgloss.NewStyle().Foreground(neonTeal).Bold(true).Render("✔ Backup complete"))
+            cmd = m.progress.SetPercent(1)
+            m.logs.Append(lipgloss.NewStyle().Foreground(neonTeal).Bold(true).Render("✔ " + m.runKind + " complete"))
         }
-        return m, nil
+        return m, cmd
     }
 
     // delegate to list/inputs/spinner/progress
@@ -361,13 +487,27 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
     switch m.page {
     case pageSelect:
         m.list, cmd = m.list.Update(msg)
+    case pageBackups:
+        m.backupList, cmd = m.backupList.Update(msg)
+    case pageHistory:
+        m.historyList, cmd = m.historyList.Update(msg)
+    case pageJobs:
+        m.jobList, cmd = m.jobList.Update(msg)
+    case pageNewJob:
+        m.jobName, cmd = m.jobName.Update(msg)
+    case pageRestoreTarget:
+        m.target, cmd = m.target.Update(msg)
     case pageConfig:
         if m.focusIndex < len(m.inputs) {
             *m.inputs[m.focusIndex], cmd = m.inputs[m.focusIndex].Update(msg)
         }
     case pageRun:
-        m.spinner, _ = m.spinner.Update(msg)
-        m.progress, _ = m.progress.Update(msg)
+        if _, ok := msg.(spinner.TickMsg); ok && !m.running { return m, nil }
+        var pcmd tea.Cmd
+        m.spinner, cmd = m.spinner.Update(msg)
+        pm, pcmd := m.progress.Update(msg)
+        m.progress = pm.(progress.Model)
+        cmd = tea.Batch(cmd, pcmd)
     }
     return m, cmd
 }
@@ -380,28 +520,84 @@ func (m model) View() string {
         b.WriteString(borderStyle.Render(
             sectionTitle.Render("Welcome to OctoBackup")+"\n"+
             "Stream your Linux backups directly to your homelab over SSH.\n\n"+
-            helpStyle.Render("Press Enter to choose a backup strategy • q to quit")))
+            renderKeyVal("job", fmt.Sprintf("%s (%s, %d of %d)", m.job, m.cfg.Strategy, m.jobIndex()+1, len(m.file.Jobs)))+"\n\n"+
+            helpStyle.Render("Enter: choose a backup strategy • j: jobs • b: backups & restore • h: history • q: quit")))
         return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, b.String())
     case pageSelect:
         return borderStyle.Render(m.list.View()) + "\n" + helpStyle.Render("Enter: select • q: quit")
+    case pageJobs:
+        return borderStyle.Render(m.jobList.View()) + "\n" + helpStyle.Render("Enter: work on this job • n: new job • /: filter • Esc: back • q: quit")
+    case pageNewJob:
+        rows := []string{
+            sectionTitle.Render("New job"),
+            helpStyle.Render("Starts from the shared defaults; choose its strategy and settings next."),
+            renderKeyVal("name", m.jobName.View()),
+            "\n" + helpStyle.Render("Enter: create • Esc: back"),
+        }
+        return borderStyle.Render(strings.Join(rows, "\n"))
     case pageConfig:
         rows := []string{
             sectionTitle.Render("Connection & Options"),
+            renderKeyVal("job", m.job),
             renderKeyVal("strategy", string(m.cfg.Strategy)),
         }
         labels := []string{"user","host","port","remote path","compression","bandwidth","source disk","borg repo","borg passenv"}
         for i, ti := range m.inputs {
             rows = append(rows, renderKeyVal(labels[i], ti.View()))
         }
-        rows = append(rows, "\n"+helpStyle.Render("Tab: next field • Enter: save & preflight"))
+        rows = append(rows, "\n"+helpStyle.Render("Tab: next field • Enter: save job & preflight"))
         return borderStyle.Render(strings.Join(rows, "\n"))
     case pagePreflight:
-        return borderStyle.Render(sectionTitle.Render("Running preflight checks…")+"\n"+strings.Join(m.logLines, "\n"))
+        help := "Esc: back"
+        if m.preflightOK { help = "Enter: start backup • Esc: back" }
+        return borderStyle.Render(sectionTitle.Render("Running preflight checks…")+"\n"+strings.Join(m.logs.Tail(0), "\n")+"\n"+helpStyle.Render(help))
+    case pageBackups:
+        help := helpStyle.Render("Enter: restore • v: verify • V: verify & decode • x: delete • /: filter • Esc: back • q: quit")
+        if m.confirmDelete != "" { help = warnStyle.Render("x again: delete "+m.confirmDelete+" from "+m.cfg.RemoteHost) + helpStyle.Render(" • any other key: keep it") }
+        return borderStyle.Render(m.backupList.View()) + "\n" + help
+    case pageHistory:
+        return borderStyle.Render(m.historyList.View()) + "\n" + helpStyle.Render("Enter: show log • /: filter • Esc: back • q: quit")
+    case pageHistoryLog:
+        r := m.historyRec
+        rows := []string{
+            sectionTitle.Render("Run " + r.ID),
+            renderKeyVal("status", historyStatus(r.Status)),
+            renderKeyVal("strategy", string(r.Strategy)+" → "+r.Remote),
+            renderKeyVal("time", r.Start.Format("2006-01-02 15:04:05")+" • "+r.Duration().String()+" • "+humanBytes(r.Bytes)),
+            renderKeyVal("artifacts", historyArtifacts(r)),
+            renderKeyVal("log", r.Log),
+            "",
+        }
+        rows = append(rows, m.logs.Tail(m.height-16)...)
+        return borderStyle.Render(strings.Join(rows, "\n")) + "\n" + helpStyle.Render("Esc: back • q: quit")
+    case pageRestoreTarget:
+        rows := []string{
+            sectionTitle.Render("Restore target"),
+            renderKeyVal("strategy", string(m.cfg.Strategy)),
+            renderKeyVal("backup", m.restore.Artifact.Path),
+            renderKeyVal("target", m.target.View()),
+            "\n" + helpStyle.Render("Enter: run safety checks • Esc: back"),
+        }
+        return borderStyle.Render(strings.Join(rows, "\n"))
+    case pageRestorePreflight:
+        help := "Esc: back"
+        if m.preflightOK { help = warnStyle.Render("Enter: overwrite "+m.restore.Target+" and restore") + helpStyle.Render(" • Esc: back") }
+        return borderStyle.Render(sectionTitle.Render("Restore safety checks…")+"\n"+strings.Join(m.logs.Tail(0), "\n")+"\n"+helpStyle.Render(help))
     case pageRun:
-        logBox := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(neonTeal).Height(m.height-10).Width(m.width-6).Padding(0,1)
-        log := strings.Join(m.logLines, "\n")
-        header := lipgloss.JoinHorizontal(lipgloss.Top, m.spinner.View(), " ", sectionTitle.Render("Streaming backup…"))
-        return borderStyle.Render(header+"\n"+m.progress.View()+"\n"+logBox.Render(log))
+        logBox := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(neonTeal).Height(m.height-12).Width(m.width-6).Padding(0,1)
+        log := strings.Join(m.logs.Tail(m.height-14), "\n")
+        if h := m.logs.Header(); h != "" { log = helpStyle.Render(h) + "\n" + strings.Join(m.logs.Tail(m.height-15), "\n") }
+        title := "Streaming backup…"
+        switch m.runKind {
+        case "Restore":
+            title = "Restoring " + m.restore.Artifact.Name + " → " + m.restore.Target + "…"
+        case "Verify":
+            title = "Verifying " + m.restore.Artifact.Name + "…"
+        }
+        header := lipgloss.JoinHorizontal(lipgloss.Top, m.spinner.View(), " ", sectionTitle.Render(title), " ", helpStyle.Render("["+m.state.String()+"]"))
+        help := "Esc: back • q: quit"
+        if m.run != nil { help = "p: pause • r: resume • c: cancel • q: cancel & quit" }
+        return borderStyle.Render(header+"\n"+m.progress.View()+"\n"+helpStyle.Render(progressLine(m.stats))+"\n"+logBox.Render(log)+"\n"+helpStyle.Render(help))
     }
     return ""
 }
@@ -409,177 +605,87 @@ func (m model) View() string {
 // --------------------------- PREFLIGHT ---------------------------
 
 func (m model) doPreflight() tea.Cmd {
+    cfg := m.cfg
     return func() tea.Msg {
-        var rpt bytes.Buffer
-        ok := true
-
-        fmt.Fprintf(&rpt, "Checking required tools…\n")
-        req := []string{"ssh", "rsync"}
-        switch m.cfg.Strategy {
-        case StratDD:
-            req = append(req, "dd")
-            if m.cfg.Compression == "pigz" { req = append(req, "pigz") } else if m.cfg.Compression == "gzip" { req = append(req, "gzip") }
-            if have("pv") { fmt.Fprintf(&rpt, "• pv present (nice progress)\n") }
-        case StratBorg:
-            req = append(req, "borg")
-        case StratZFS:
-            req = append(req, "zfs")
-        case StratBtrfs:
-            req = append(req, "btrfs")
-        }
-        for _, r := range req {
-            if !have(r) { ok = false; fmt.Fprintf(&rpt, "✗ missing %s\n", r) } else { fmt.Fprintf(&rpt, "✓ %s\n", r) }
-        }
-
-        // SSH reachability
-        fmt.Fprintf(&rpt, "Testing SSH reachability…\n")
-        ctx, cancel := context.WithTimeout(context.Background(), 6*time.Second)
-        defer cancel()
-        cmd := os_exec.CommandContext(ctx, "ssh", fmt.Sprintf("-p% d", m.cfg.SSHPort), fmt.Sprintf("%s@%s", m.cfg.RemoteUser, m.cfg.RemoteHost), "echo", "ok")
-        if out, err := cmd.CombinedOutput(); err != nil || !strings.Contains(string(out), "ok") {
-            ok = false; fmt.Fprintf(&rpt, "✗ ssh check failed: %v\n", err)
-        } else { fmt.Fprintf(&rpt, "✓ ssh ok\n") }
-
-        // Disk list for dd safety
-        if m.cfg.Strategy == StratDD {
-            fmt.Fprintf(&rpt, "Listing disks via lsblk…\n")
-            if have("lsblk") {
-                out, _ := os_exec.Command("lsblk", "-o", "NAME,SIZE,TYPE,MODEL").CombinedOutput()
-                fmt.Fprintf(&rpt, "%s\n", out)
-                if m.cfg.SourceDisk == "" { ok = false; fmt.Fprintf(&rpt, "✗ source disk not set\n") }
-            } else { ok = false; fmt.Fprintf(&rpt, "✗ need lsblk for dd safety\n") }
-        }
-
-        return preflightDoneMsg{ok: ok, report: rpt.String(), err: nil}
+        ok, report := backend.Preflight(context.Background(), cfg)
+        return preflightDoneMsg{ok: ok, report: report, err: nil}
     }
 }
 
-// --------------------------- RUN BACKUP ---------------------------
-
-func (m model) runBackup() tea.Cmd {
-    return func() tea.Msg {
-        ctx, cancel := context.WithCancel(context.Background())
-        m.startTime = time.Now()
-        m.cancel = cancel
-
-        var cmd *os_exec.Cmd
-        var stdout io.ReadCloser
-        var stderr io.ReadCloser
-        var err error
-
-        logLine := func(s string) tea.Cmd { return func() tea.Msg { return runLogMsg{line: s} } }
-        cmds := []tea.Cmd{}
-
-        switch m.cfg.Strategy {
-        case StratDD:
-            // dd if=<disk> | [pv] | [pigz|gzip] | ssh user@host "cat > path/file.img.gz"
-            if m.cfg.SourceDisk == "" { return runDoneMsg{err: fmt.Errorf("source disk not set")} }
-            sshSpec := fmt.Sprintf("%s@%s", m.cfg.RemoteUser, m.cfg.RemoteHost)
-            remote := fmt.Sprintf("-p% d", m.cfg.SSHPort)
-            date := time.Now().Format("2006-01-02")
-            remoteFile := strings.ReplaceAll(m.cfg.RemotePath, "$(hostname)", hostname()) + fmt.Sprintf("/disk-%s.img", date)
-            // ensure remote dir
-            os_exec.Command("ssh", remote, sshSpec, "mkdir", "-p", path_file.Dir(remoteFile)).Run()
-
-            var pipeCmd []string
-            pipeCmd = append(pipeCmd, "dd", fmt.Sprintf("if=%s", m.cfg.SourceDisk), "bs=64K", "status=progress")
-            if have("pv") { pipeCmd = append(pipeCmd, "|", "pv") }
-            if m.cfg.Compression == "pigz" && have("pigz") { pipeCmd = append(pipeCmd, "|", "pigz") }
-            if m.cfg.Compression == "gzip" && have("gzip") { pipeCmd = append(pipeCmd, "|", "gzip") }
-            pipeCmd = append(pipeCmd, "|", "ssh", remote, sshSpec, "cat", ">", remoteFile)
-            cmdStr := strings.Join(pipeCmd, " ")
-            cmd = os_exec.CommandContext(ctx, "bash", "-c", cmdStr)
-            cmds = append(cmds, logLine("Running: "+cmdStr))
-
-        case StratRsync:
-            rsArgs := []string{"-aAXHvz", "--numeric-ids", "--delete-after"}
-            if m.cfg.BandwidthKbps > 0 { rsArgs = append(rsArgs, fmt.Sprintf("--bwlimit=%d", m.cfg.BandwidthKbps)) }
-            for _, ex := range m.cfg.Excludes { rsArgs = append(rsArgs, "--exclude="+ex) }
-            rsArgs = append(rsArgs, "/")
-            remote := fmt.Sprintf("-e ssh -p% d", m.cfg.SSHPort)
-            rdest := fmt.Sprintf("%s@%s:%s/", m.cfg.RemoteUser, m.cfg.RemoteHost, strings.ReplaceAll(m.cfg.RemotePath, "$(hostname)", hostname()))
-            rsArgs = append(rsArgs, "-e", remote, rdest)
-            cmd = os_exec.CommandContext(ctx, "rsync", rsArgs...)
-            cmds = append(cmds, logLine("Running: rsync "+strings.Join(rsArgs, " ")))
-
-        case StratBorg:
-            repo := strings.ReplaceAll(m.cfg.BorgRepo, "$(hostname)", hostname())
-            // ensure repo exists
-            init := os_exec.Command("borg", "init", "--encryption=repokey", repo)
-            _ = init.Run()
-            snap := fmt.Sprintf("%s::%s-%s", repo, hostname(), time.Now().Format("2006-01-02"))
-            args := []string{"create", "--stats", "--progress", snap, "/"}
-            cmd = os_exec.CommandContext(ctx, "borg", args...)
-            cmd.Env = os.Environ()
-            if m.cfg.BorgPassEnv != "":
-                cmd.Env = append(cmd.Env, fmt.Sprintf("BORG_PASSCOMMAND=echo ${%s}", m.cfg.BorgPassEnv))
-            }
-            cmds = append(cmds, logLine("Running: borg "+strings.Join(args, " ")))
-
-        case StratZFS:
-            // zfs snapshot pool/root@ccYYMMDD && zfs send | ssh recv
-            date := time.Now().Format("20060102")
-            snap := fmt.Sprintf("%s@%s", m.cfg.SourceDisk, date) // here SourceDisk holds dataset like pool/root
-            _ = os_exec.Command("zfs", "snapshot", snap).Run()
-            remote := fmt.Sprintf("%s@%s", m.cfg.RemoteUser, m.cfg.RemoteHost)
-            recv := strings.ReplaceAll(m.cfg.RemotePath, "$(hostname)", hostname())
-            cmdStr := fmt.Sprintf("zfs send %s | ssh -p% d %s \"zfs recv %s\"", snap, m.cfg.SSHPort, remote, recv)
-            cmd = os_exec.CommandContext(ctx, "bash", "-c", cmdStr)
-            cmds = append(cmds, logLine("Running: "+cmdStr))
-
-        case StratBtrfs:
-            // btrfs subvolume snapshot -r / /tmp/cc-snap && btrfs send | ssh receive
-            snapDir := fmt.Sprintf("/tmp/cc-snap-%d", time.Now().Unix())
-            _ = os.MkdirAll(snapDir, 0o755)
-            _ = os_exec.Command("btrfs", "subvolume", "snapshot", "-r", "/", snapDir).Run()
-            remote := fmt.Sprintf("%s@%s", m.cfg.RemoteUser, m.cfg.RemoteHost)
-            recv := strings.ReplaceAll(m.cfg.RemotePath, "$(hostname)", hostname())
-            cmdStr := fmt.Sprintf("btrfs send %s | ssh -p% d %s \"btrfs receive %s\"", snapDir, m.cfg.SSHPort, remote, recv)
-            cmd = os_exec.CommandContext(ctx, "bash", "-c", cmdStr)
-            cmds = append(cmds, logLine("Running: "+cmdStr))
-        }
-
-        if cmd == nil { return runDoneMsg{err: fmt.Errorf("no command")} }
-        stdout, stderr, err = startCmdPipes(cmd)
-        if err != nil { return runDoneMsg{err: err} }
+// --------------------------- RUN ---------------------------
+
+// beginRun switches to the run view and subscribes to a started run (see
+// runBackup/runRestore in run.go).
+func (m model) beginRun(run *runHandle, sub tea.Cmd) (tea.Model, tea.Cmd) {
+    m.page = pageRun
+    m.logs.Reset()
+    m.stats = backend.Progress{}
+    m.running = true
+    m.state = stateRunning
+    m.run = run
+    m.confirmCancel, m.quitAfterRun = false, false
+    return m, tea.Batch(m.progress.SetPercent(0), sub, m.spinner.Tick)
+}
 
-        // stream logs
-        go streamReader(stdout, func(line string) { tea.NewProgram(nil).Send(runLogMsg{line: line}) })
-        go streamReader(stderr, func(line string) { tea.NewProgram(nil).Send(runLogMsg{line: line}) })
+// --------------------------- RESTORE ---------------------------
 
-        for _, c := range cmds { _ = c() }
-        if err := cmd.Wait(); err != nil { return runDoneMsg{err: err} }
-        return runDoneMsg{err: nil}
+// restoreTargetHint is the target field placeholder for a strategy.
+func restoreTargetHint(s config.Strategy) string {
+    switch s {
+    case config.StratDD:
+        return "target disk (e.g., /dev/sdb) — will be overwritten"
+    case config.StratZFS:
+        return "new dataset (e.g., pool/restore)"
     }
+    return "target directory (e.g., /mnt/restore)"
 }
 
-func startCmdPipes(cmd *os_exec.Cmd) (io.ReadCloser, io.ReadCloser, error) {
-    stdout, err := cmd.StdoutPipe(); if err != nil { return nil, nil, err }
-    stderr, err := cmd.StderrPipe(); if err != nil { return nil, nil, err }
-    if err := cmd.Start(); err != nil { return nil, nil, err }
-    return stdout, stderr, nil
-}
-
-func streamReader(r io.Reader, fn func(string)) {
-    s := bufio.NewScanner(r)
-    s.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
-    for s.Scan() { fn(s.Text()) }
+// loadArtifacts lists the remote's backups for the Backups page.
+func (m *model) loadArtifacts() tea.Cmd {
+    m.confirmDelete = ""
+    m.backupList.Title = fmt.Sprintf("Loading %s backups from %s…", m.cfg.Strategy, m.cfg.RemoteHost)
+    cfg := m.cfg
+    return func() tea.Msg {
+        b, err := backend.Get(cfg.Strategy)
+        if err != nil { return artifactsMsg{err: err} }
+        ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+        defer cancel()
+        items, err := b.List(ctx, cfg)
+        return artifactsMsg{items: items, err: err}
+    }
 }
 
-func hostname() string {
-    if h, err := os.Hostname(); err == nil { return h }
-    return "host"
+func (m model) doRestorePreflight() tea.Cmd {
+    cfg, opts := m.cfg, m.restore
+    return func() tea.Msg {
+        ok, report := backend.PreflightRestore(context.Background(), cfg, opts)
+        return preflightDoneMsg{ok: ok, report: report, err: nil}
+    }
 }
 
 // --------------------------- MAIN ---------------------------
 
 func main() {
+    if len(os.Args) > 1 {
+        os.Exit(runCLI(os.Args[1:]))
+    }
+    os.Exit(runTUI(config.Path()))
+}
+
+// runTUI starts the interactive Bubble Tea program.
+func runTUI(cfgFile string) int {
     fmt.Print(lipgloss.NewStyle().Background(paletteBg).Foreground(paletteFg))
-    cfg, _ := loadConfig() // fall back to defaults
-    m := newModel(cfg)
+    f, err := config.LoadFile(cfgFile)
+    if err != nil && !errors.Is(err, os.ErrNotExist) {
+        // never overwrite a file we could not read
+        fmt.Fprintf(os.Stderr, "octobackup: load %s: %v\n", cfgFile, err)
+        return exitConfig
+    }
+    m := newModel(f, cfgFile)
     p := tea.NewProgram(m, tea.WithAltScreen())
     if _, err := p.Run(); err != nil {
         fmt.Println("error:", err)
-        os.Exit(1)
+        return exitFailure
     }
+    return exitOK
 }
@@ -0,0 +1,273 @@
+// File: cmd/octobackup/run.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   The TUI run pipeline. A backup or restore runs in its own goroutine and
+//   publishes log lines, progress and state changes on a channel; the model
+//   subscribes with waitForRun, re-arming after every message, so Bubble Tea
+//   never blocks inside a tea.Cmd for the length of a transfer.
+//
+//   Log lines are batched per message and kept in a bounded logBuffer so
+//   multi-hour rsync runs stay responsive.
+//
+//   Every child process of a run belongs to the run's proc.Group: pause and
+//   resume SIGSTOP/SIGCONT all of them; cancel terminates the pipeline, lets
+//   the backend clean up partial files and snapshots, and ends the run as
+//   aborted.
+
+package main
+
+import (
+    context "context"
+    errors "errors"
+    fmt "fmt"
+    strings "strings"
+
+    tea "github.com/charmbracelet/bubbletea"
+
+    "cloudcurio.cc/octobackup/internal/backend"
+    "cloudcurio.cc/octobackup/internal/proc"
+)
+
+const (
+    // runEventBuffer is how many events a run may queue before log lines
+    // apply backpressure to the child process readers.
+    runEventBuffer = 512
+    // maxLogBatch caps how many queued lines one runLogMsg carries.
+    maxLogBatch = 256
+    // maxLogLines is how many log lines the run view keeps.
+    maxLogLines = 2000
+    // maxLogLineLen truncates pathological lines (e.g. binary on stderr).
+    maxLogLineLen = 1024
+)
+
+type runState int
+
+const (
+    stateIdle runState = iota
+    stateRunning
+    statePaused
+    stateCancelling
+    stateDone
+    stateFailed
+    stateAborted
+)
+
+func (s runState) String() string {
+    switch s {
+    case stateRunning:
+        return "running"
+    case statePaused:
+        return "paused"
+    case stateCancelling:
+        return "cancelling"
+    case stateAborted:
+        return "aborted"
+    case stateDone:
+        return "done"
+    case stateFailed:
+        return "failed"
+    }
+    return "idle"
+}
+
+// run messages, delivered through the run's event channel
+type (
+    runLogMsg      struct{ lines []string; next tea.Msg } // next: event that ended the batch
+    runProgressMsg struct{ p backend.Progress }
+    runStateMsg    struct{ state runState }
+    runDoneMsg     struct{ err error }
+)
+
+// runJob is the work a run performs: a backend Run or Restore.
+type runJob func(ctx context.Context, sink backend.Sink) error
+
+// runHandle is the model's grip on a run in progress.
+type runHandle struct {
+    events chan tea.Msg
+    cancel context.CancelFunc
+    group  *proc.Group
+}
+
+// startRun launches job in the background and returns its handle and the
+// first subscription command.
+func startRun(job runJob) (*runHandle, tea.Cmd) {
+    ctx, cancel := context.WithCancel(context.Background())
+    group := proc.NewGroup()
+    ctx = proc.WithGroup(ctx, group)
+    events := make(chan tea.Msg, runEventBuffer)
+    logf := func(line string) { events <- runLogMsg{lines: []string{line}} }
+    sink := backend.Sink{
+        Stdout: logf,
+        Stderr: logf,
+        Info:   logf,
+        Progress: func(p backend.Progress) {
+            // progress is a snapshot; drop it rather than stall the transfer
+            select {
+            case events <- runProgressMsg{p: p}:
+            default:
+            }
+        },
+    }
+    go func() {
+        defer close(events)
+        events <- runStateMsg{state: stateRunning}
+        err := job(ctx, sink)
+        switch {
+        case errors.Is(err, backend.ErrAborted):
+            events <- runStateMsg{state: stateAborted}
+        case err != nil:
+            events <- runStateMsg{state: stateFailed}
+        default:
+            events <- runStateMsg{state: stateDone}
+        }
+        events <- runDoneMsg{err: err}
+        cancel()
+    }()
+    return &runHandle{events: events, cancel: cancel, group: group}, waitForRun(events)
+}
+
+// waitForRun receives the next run event. Consecutive log lines are
+// coalesced into one runLogMsg so a chatty process costs one render per batch.
+func waitForRun(events <-chan tea.Msg) tea.Cmd {
+    return func() tea.Msg {
+        msg, ok := <-events
+        if !ok { return nil }
+        lm, isLog := msg.(runLogMsg)
+        if !isLog { return msg }
+        for len(lm.lines) < maxLogBatch {
+            select {
+            case next, ok := <-events:
+                if !ok { return lm }
+                nl, isLog := next.(runLogMsg)
+                if !isLog {
+                    // keep ordering: the model applies the lines, then next
+                    lm.next = next
+                    return lm
+                }
+                lm.lines = append(lm.lines, nl.lines...)
+            default:
+                return lm
+            }
+        }
+        return lm
+    }
+}
+
+func (m model) runBackup() (*runHandle, tea.Cmd) {
+    cfg, job := m.cfg, m.job
+    return startRun(func(ctx context.Context, sink backend.Sink) error {
+        b, err := backend.Get(cfg.Strategy)
+        if err != nil { return err }
+        // never alongside a daemon or timer run of the same job
+        release, err := lockJob(job)
+        if err != nil { return err }
+        defer release()
+        sink, finish := recordRun(job, cfg, sink)
+        err = b.Run(ctx, cfg, sink)
+        finish(err)
+        return err
+    })
+}
+
+func (m model) runRestore() (*runHandle, tea.Cmd) {
+    cfg, opts := m.cfg, m.restore
+    return startRun(func(ctx context.Context, sink backend.Sink) error {
+        b, err := backend.Get(cfg.Strategy)
+        if err != nil { return err }
+        return b.Restore(ctx, cfg, opts, sink)
+    })
+}
+
+func (m model) runVerify(opts backend.VerifyOptions) (*runHandle, tea.Cmd) {
+    cfg, a := m.cfg, m.restore.Artifact
+    return startRun(func(ctx context.Context, sink backend.Sink) error {
+        return backend.Verify(ctx, cfg, a, opts, sink)
+    })
+}
+
+// runKey handles pause/resume/cancel keys while a run is in progress. The
+// bool reports whether the key was consumed.
+func (m model) runKey(key string) (model, tea.Cmd, bool) {
+    switch key {
+    case "p":
+        if m.state != stateRunning { return m, nil, true }
+        if err := m.run.group.Pause(); err != nil { m.logs.Append(warnStyle.Render("pause: " + err.Error())) }
+        m.state = statePaused
+        m.logs.Append(warnStyle.Render("⏸ Paused — r: resume • c: cancel"))
+        return m, nil, true
+    case "r":
+        if m.state != statePaused { return m, nil, true }
+        if err := m.run.group.Resume(); err != nil { m.logs.Append(warnStyle.Render("resume: " + err.Error())) }
+        m.state = stateRunning
+        m.logs.Append("▶ Resumed")
+        return m, nil, true
+    case "c":
+        if m.state == stateCancelling { return m, nil, true }
+        if !m.confirmCancel {
+            m.confirmCancel = true
+            m.logs.Append(warnStyle.Render("Press c again to cancel and clean up partial backups"))
+            return m, nil, true
+        }
+        m.cancelRun()
+        return m, nil, true
+    case "q", "ctrl+c":
+        // never leave dd/ssh/borg behind: cancel, then quit once cleaned up;
+        // a second ctrl+c quits immediately
+        if m.quitAfterRun && key == "ctrl+c" { return m, tea.Quit, true }
+        m.quitAfterRun = true
+        m.cancelRun()
+        return m, nil, true
+    }
+    m.confirmCancel = false
+    return m, nil, false
+}
+
+// cancelRun terminates the run's process groups; the backend's cleanup runs
+// before runDoneMsg arrives.
+func (m *model) cancelRun() {
+    if m.state == stateCancelling { return }
+    m.state = stateCancelling
+    m.logs.Append(warnStyle.Render("✖ Cancelling — terminating pipeline and cleaning up…"))
+    m.run.cancel()
+}
+
+// --------------------------- LOG BUFFER ---------------------------
+
+// logBuffer keeps the most recent max lines. Appends are amortised O(1):
+// the slice grows to 2×max before the tail is copied back to the front.
+type logBuffer struct {
+    lines   []string
+    max     int
+    dropped int
+}
+
+func newLogBuffer(max int) logBuffer { return logBuffer{max: max} }
+
+func (b *logBuffer) Append(lines ...string) {
+    for _, l := range lines {
+        if len(l) > maxLogLineLen { l = strings.ToValidUTF8(l[:maxLogLineLen], "") + "…" }
+        b.lines = append(b.lines, l)
+    }
+    if b.max > 0 && len(b.lines) >= 2*b.max {
+        n := len(b.lines) - b.max
+        b.dropped += n
+        b.lines = append(b.lines[:0:0], b.lines[n:]...)
+    }
+}
+
+func (b *logBuffer) Reset() { b.lines, b.dropped = nil, 0 }
+
+// Header notes how many lines were dropped, or "" when none were.
+func (b logBuffer) Header() string {
+    if b.dropped == 0 { return "" }
+    return fmt.Sprintf("… %d earlier lines not shown", b.dropped)
+}
+
+// Tail returns the last n lines (all when n <= 0), capped at max.
+func (b logBuffer) Tail(n int) []string {
+    if b.max > 0 && (n <= 0 || n > b.max) { n = b.max }
+    if n <= 0 || n > len(b.lines) { n = len(b.lines) }
+    return b.lines[len(b.lines)-n:]
+}
@@ -0,0 +1,98 @@
+// File: cmd/octobackup/scrub.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Commands over the per-file index of rsync backups (rsync_index: true).
+//   octobackup scrub re-hashes a backup on the backup host and reports files
+//   that changed without their size or mtime changing. octobackup
+//   file-history shows a file's size, mtime and hash in every snapshot and
+//   when it last changed.
+
+package main
+
+import (
+    context "context"
+    fmt "fmt"
+    os "os"
+    os_signal "os/signal"
+    syscall "syscall"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/backend"
+)
+
+func cmdScrub(args []string) int {
+    fs, cfgFile := newFlagSet("scrub [backup]")
+    job := jobFlag(fs)
+    all := fs.Bool("all", false, "scrub every backup on the remote")
+    if err := fs.Parse(args); err != nil { return exitUsage }
+    if fs.NArg() > 1 || (*all && fs.NArg() > 0) { fmt.Fprintln(os.Stderr, "octobackup: give one backup name, or --all"); return exitUsage }
+
+    cfg, err := loadHeadlessConfig(*cfgFile, *job, "")
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+    b, _ := backend.Get(cfg.Strategy)
+
+    ctx, stop := os_signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    var as []backend.Artifact
+    if *all {
+        if as, err = b.List(ctx, cfg); err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+    } else {
+        a, err := backend.Resolve(ctx, cfg, fs.Arg(0))
+        if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+        as = []backend.Artifact{a}
+    }
+    failed := 0
+    for _, a := range as {
+        fmt.Fprintf(os.Stderr, "Scrubbing %s…\n", a.Path)
+        r, err := backend.Scrub(ctx, cfg, a, os.Stdout)
+        if ctx.Err() != nil { fmt.Fprintln(os.Stderr, "octobackup: interrupted; scrub stopped"); return exitInterrupted }
+        switch {
+        case err != nil:
+            failed++
+            fmt.Fprintf(os.Stderr, "✗ %s: %v\n", a.Name, err)
+        case !r.Clean():
+            failed++
+            fmt.Fprintf(os.Stderr, "✗ %s: %s\n", a.Name, r)
+        default:
+            fmt.Fprintf(os.Stderr, "✓ %s: %s\n", a.Name, r)
+        }
+    }
+    if failed > 0 { fmt.Fprintf(os.Stderr, "octobackup: %d of %d backup(s) failed scrub\n", failed, len(as)); return exitFailure }
+    return exitOK
+}
+
+func cmdFileHistory(args []string) int {
+    fs, cfgFile := newFlagSet("file-history <path>")
+    job := jobFlag(fs)
+    if err := fs.Parse(args); err != nil { return exitUsage }
+    if fs.NArg() != 1 { fmt.Fprintln(os.Stderr, "octobackup: give the absolute path of one file, e.g. /etc/fstab"); return exitUsage }
+
+    cfg, err := loadHeadlessConfig(*cfgFile, *job, "")
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+
+    ctx, stop := os_signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
+    vs, err := backend.FileHistory(ctx, cfg, fs.Arg(0))
+    if ctx.Err() != nil { return exitInterrupted }
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+    var last *backend.FileVersion
+    for i, v := range vs {
+        note := ""
+        if v.Changed { note = "changed" }
+        if !v.Present {
+            if v.Changed { note = "removed" }
+            fmt.Printf("%-20s  %-16s  %10s  %-10s  %-12s  %s\n", v.Backup.Name, "-", "-", "-", "-", note)
+            continue
+        }
+        e := v.Entry
+        fmt.Printf("%-20s  %s  %10s  %-10s  %-12.12s  %s\n", v.Backup.Name, time.Unix(e.MTime, 0).Format("2006-01-02 15:04"), humanBytes(e.Size), e.Mode, e.Sum, note)
+        if v.Changed { last = &vs[i] }
+    }
+    if last == nil { fmt.Fprintf(os.Stderr, "%s is in no indexed backup\n", fs.Arg(0)); return exitFailure }
+    fmt.Fprintf(os.Stderr, "%s last changed before %s (mtime %s)\n", fs.Arg(0), last.Backup.Name, time.Unix(last.Entry.MTime, 0).Format("2006-01-02 15:04:05"))
+    return exitOK
+}
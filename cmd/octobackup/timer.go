@@ -0,0 +1,158 @@
+// File: cmd/octobackup/timer.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   octobackup install-timer / uninstall-timer: write a job's systemd
+//   service and timer (see internal/units) into the system or --user unit
+//   directory, reload systemd and enable the timer; or undo all of it.
+//   --print shows the units without installing anything. An alternative to
+//   the long-running daemon for hosts that prefer plain timers.
+
+package main
+
+import (
+    fmt "fmt"
+    os "os"
+    os_exec "os/exec"
+    path_file "path/filepath"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/crypt"
+    "cloudcurio.cc/octobackup/internal/schedule"
+    "cloudcurio.cc/octobackup/internal/units"
+)
+
+// unitDir is where systemd looks for admin (or --user) units.
+func unitDir(user bool) string {
+    if !user { return "/etc/systemd/system" }
+    base := os.Getenv("XDG_CONFIG_HOME")
+    if base == "" { base = path_file.Join(os.Getenv("HOME"), ".config") }
+    return path_file.Join(base, "systemd", "user")
+}
+
+// credentialDir is the default home of LoadCredential= source files.
+func credentialDir(user bool) string {
+    if !user { return "/etc/octobackup/credentials" }
+    return path_file.Join(os.Getenv("HOME"), ".config", "cloudcurio", "credentials")
+}
+
+// systemctl runs systemctl, --user when asked, with output passed through.
+func systemctl(user bool, args ...string) error {
+    if user { args = append([]string{"--user"}, args...) }
+    cmd := os_exec.Command("systemctl", args...)
+    cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+    return cmd.Run()
+}
+
+// jobArg resolves the optional job name argument against f; it may be left
+// out when f has a single job.
+func jobArg(fsArgs []string, f config.File) (config.Job, error) {
+    switch len(fsArgs) {
+    case 0:
+        return f.Job("")
+    case 1:
+        return f.Job(fsArgs[0])
+    }
+    return config.Job{}, fmt.Errorf("want at most one job name")
+}
+
+func cmdInstallTimer(args []string) int {
+    fs, cfgFile := newFlagSet("install-timer [job]")
+    user := fs.Bool("user", false, "install --user units instead of system units")
+    printOnly := fs.Bool("print", false, "print the units and exit")
+    noEnable := fs.Bool("no-enable", false, "write the units but do not enable the timer")
+    credDir := fs.String("credentials", "", "directory holding secret files named after their env vars (default /etc/octobackup/credentials, or ~/.config/cloudcurio/credentials with --user)")
+    nice := fs.Int("nice", 10, "Nice= of the backup service")
+    ioClass := fs.String("io-class", "best-effort", "IOSchedulingClass= (realtime, best-effort, idle)")
+    ioPrio := fs.Int("io-priority", 7, "IOSchedulingPriority= 0-7")
+    if err := parseJobArgs(fs, args); err != nil { return exitUsage }
+
+    f, err := loadHeadlessFile(*cfgFile)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+    j, err := jobArg(fs.Args(), f)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitUsage }
+    job := j.Name
+    opts, err := unitOptions(job, *cfgFile, j.Config, *user, *credDir)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+    opts.Nice, opts.IOClass, opts.IOPriority = *nice, *ioClass, *ioPrio
+    files, err := units.Render(opts)
+    if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitConfig }
+
+    if *printOnly {
+        for _, f := range files { fmt.Printf("# %s\n%s\n", f.Name, f.Content) }
+        return exitOK
+    }
+    dir := unitDir(*user)
+    if err := os.MkdirAll(dir, 0o755); err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+    for _, f := range files {
+        p := path_file.Join(dir, f.Name)
+        if err := os.WriteFile(p, []byte(f.Content), 0o644); err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+        fmt.Fprintln(os.Stderr, "wrote", p)
+    }
+    for _, c := range opts.Credentials {
+        if _, err := os.Stat(c.Path); err != nil { fmt.Fprintf(os.Stderr, "! put the secret for %s in %s (mode 0600) before the first run\n", c.Name, c.Path) }
+    }
+    if err := systemctl(*user, "daemon-reload"); err != nil { fmt.Fprintln(os.Stderr, "octobackup: systemctl daemon-reload:", err); return exitFailure }
+    if *noEnable { return exitOK }
+    timer := units.Name(job) + ".timer"
+    if err := systemctl(*user, "enable", "--now", timer); err != nil { fmt.Fprintln(os.Stderr, "octobackup: enable", timer+":", err); return exitFailure }
+    fmt.Fprintf(os.Stderr, "✔ %s enabled\n", timer)
+    return exitOK
+}
+
+// unitOptions maps a job's config onto unit options: schedule, jitter,
+// failure hook and a credential for every secret env var the job uses.
+func unitOptions(job, cfgFile string, cfg config.Config, user bool, credDir string) (units.Options, error) {
+    o := units.Options{Job: job, User: user, OnFailure: cfg.OnFailure, RunArgs: []string{job}}
+    if cfg.Schedule == "" { return o, fmt.Errorf("job %s has no schedule", job) }
+    sc, err := schedule.Parse(cfg.Schedule)
+    if err != nil { return o, err }
+    o.Calendar = sc.OnCalendar()
+    if cfg.ScheduleJitter != "" {
+        if o.Jitter, err = time.ParseDuration(cfg.ScheduleJitter); err != nil { return o, fmt.Errorf("schedule_jitter: %w", err) }
+    }
+    if o.Binary, err = os.Executable(); err != nil { return o, err }
+    if p, err := path_file.EvalSymlinks(o.Binary); err == nil { o.Binary = p }
+    if o.Config, err = path_file.Abs(cfgFile); err != nil { return o, err }
+
+    if credDir == "" { credDir = credentialDir(user) }
+    var secrets []string
+    if cfg.Strategy == config.StratBorg && cfg.BorgPassEnv != "" { secrets = append(secrets, cfg.BorgPassEnv) }
+    if mode, _ := crypt.ParseMode(cfg.Encryption); mode != crypt.None && cfg.EncryptSecretEnv != "" { secrets = append(secrets, cfg.EncryptSecretEnv) }
+    for _, s := range secrets {
+        o.Credentials = append(o.Credentials, units.Credential{Name: s, Path: path_file.Join(credDir, s)})
+    }
+    return o, nil
+}
+
+func cmdUninstallTimer(args []string) int {
+    fs, cfgFile := newFlagSet("uninstall-timer [job]")
+    user := fs.Bool("user", false, "remove --user units instead of system units")
+    if err := parseJobArgs(fs, args); err != nil { return exitUsage }
+    // a named job's units go even when the job left the config
+    job := fs.Arg(0)
+    if fs.NArg() != 1 {
+        f, _ := config.LoadFile(*cfgFile)
+        j, err := jobArg(fs.Args(), f)
+        if err != nil { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitUsage }
+        job = j.Name
+    }
+
+    timer := units.Name(job) + ".timer"
+    // fails harmlessly when the timer was never enabled
+    _ = systemctl(*user, "disable", "--now", timer)
+    dir := unitDir(*user)
+    for _, name := range []string{timer, units.Name(job) + ".service", units.FailureName(job)} {
+        p := path_file.Join(dir, name)
+        if err := os.Remove(p); err != nil && !os.IsNotExist(err) { fmt.Fprintln(os.Stderr, "octobackup:", err); return exitFailure }
+        fmt.Fprintln(os.Stderr, "removed", p)
+    }
+    // the hook older versions shared between jobs goes with the last job
+    if rest, _ := path_file.Glob(path_file.Join(dir, units.Name("*")+".timer")); len(rest) == 0 {
+        _ = os.Remove(path_file.Join(dir, units.LegacyFailureUnit))
+    }
+    if err := systemctl(*user, "daemon-reload"); err != nil { fmt.Fprintln(os.Stderr, "octobackup: systemctl daemon-reload:", err); return exitFailure }
+    return exitOK
+}
@@ -1,10 +1,42 @@
 module cloudcurio.cc/octobackup
 
-go 1.21
+go 1.22
 
 require (
+    filippo.io/age v1.2.1
     github.com/charmbracelet/bubbles v0.18.0
-    github.com/charmbracelet/bubbletea v0.26.7
+    github.com/charmbracelet/bubbletea v0.26.6
     github.com/charmbracelet/lipgloss v0.10.0
+    github.com/klauspost/compress v1.18.0
+    github.com/pierrec/lz4/v4 v4.1.21
+    github.com/ulikunitz/xz v0.5.12
     gopkg.in/yaml.v3 v3.0.1
+    lukechampine.com/blake3 v1.4.1
+)
+
+require (
+    github.com/atotto/clipboard v0.1.4 // indirect
+    github.com/aymanbagabas/go-osc52/v2 v2.0.1 // indirect
+    github.com/charmbracelet/harmonica v0.2.0 // indirect
+    github.com/charmbracelet/x/ansi v0.1.2 // indirect
+    github.com/charmbracelet/x/input v0.1.0 // indirect
+    github.com/charmbracelet/x/term v0.1.1 // indirect
+    github.com/charmbracelet/x/windows v0.1.0 // indirect
+    github.com/erikgeiser/coninput v0.0.0-20211004153227-1c3628e74d0f // indirect
+    github.com/klauspost/cpuid/v2 v2.0.9 // indirect
+    github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
+    github.com/mattn/go-isatty v0.0.18 // indirect
+    github.com/mattn/go-localereader v0.0.1 // indirect
+    github.com/mattn/go-runewidth v0.0.15 // indirect
+    github.com/muesli/ansi v0.0.0-20230316100256-276c6243b2f6 // indirect
+    github.com/muesli/cancelreader v0.2.2 // indirect
+    github.com/muesli/reflow v0.3.0 // indirect
+    github.com/muesli/termenv v0.15.2 // indirect
+    github.com/rivo/uniseg v0.4.7 // indirect
+    github.com/sahilm/fuzzy v0.1.1-0.20230530133925-c48e322e2a8f // indirect
+    github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
+    golang.org/x/crypto v0.24.0 // indirect
+    golang.org/x/sync v0.7.0 // indirect
+    golang.org/x/sys v0.21.0 // indirect
+    golang.org/x/text v0.16.0 // indirect
 )
@@ -0,0 +1,371 @@
+// File: internal/backend/backend.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Pluggable strategy backends. Each strategy (dd, rsync, borg, zfs, btrfs)
+//   implements Backend and registers itself from an init() in its own file,
+//   so the TUI and CLI never switch on config.Strategy themselves.
+//
+//   A backend turns a Config into a Plan (best-effort setup commands plus the
+//   transfer pipeline); Execute runs a Plan and streams its output into a
+//   Sink, metering bytes for progress. Preflight runs the checks shared by
+//   every strategy and then the backend's own.
+
+package backend
+
+import (
+    bytes "bytes"
+    context "context"
+    errors "errors"
+    fmt "fmt"
+    io "io"
+    os "os"
+    os_exec "os/exec"
+    sort "sort"
+    strings "strings"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/pipeline"
+    "cloudcurio.cc/octobackup/internal/proc"
+)
+
+// Backend is one backup strategy.
+type Backend interface {
+    // Strategy is the config value selecting this backend.
+    Strategy() config.Strategy
+    // Describe is the one-line label shown in the strategy picker.
+    Describe() string
+    // RequiredTools lists the local binaries the strategy needs for cfg.
+    RequiredTools(cfg config.Config) []string
+    // Preflight runs strategy-specific checks, writing a report to rpt.
+    Preflight(ctx context.Context, cfg config.Config, rpt io.Writer) bool
+    // Plan resolves cfg into the commands Run would execute.
+    Plan(cfg config.Config) (Plan, error)
+    // Run performs a backup, streaming output into sink.
+    Run(ctx context.Context, cfg config.Config, sink Sink) error
+    // RestorePreflight runs the safety checks for restoring opts, writing a
+    // report to rpt.
+    RestorePreflight(ctx context.Context, cfg config.Config, opts RestoreOptions, rpt io.Writer) bool
+    // Restore streams an artifact back to a local target.
+    Restore(ctx context.Context, cfg config.Config, opts RestoreOptions, sink Sink) error
+    // List returns the backups that exist on the remote, oldest first.
+    List(ctx context.Context, cfg config.Config) ([]Artifact, error)
+}
+
+// Sink receives the output of a running backup or restore. Stdout/Stderr are
+// called once per line from the child process streams; Info carries
+// OctoBackup's own status lines ("Running: …"); Progress receives metered
+// transfer progress; Artifact is told each backup a run completed (remote
+// path, repo::archive or dataset@snap). Nil funcs discard output.
+type Sink struct {
+    Stdout   func(string)
+    Stderr   func(string)
+    Info     func(string)
+    Progress func(Progress)
+    Artifact func(string)
+}
+
+func (s Sink) info(format string, a ...any) {
+    if s.Info != nil { s.Info(fmt.Sprintf(format, a...)) }
+}
+
+// Plan is what a backend will execute: best-effort setup steps (remote
+// mkdir, snapshot, repo init) whose failures are logged but not fatal, then
+// the transfer itself as a shell-free pipeline.
+//
+// When Meter is n > 0 the bytes flowing out of stage n-1 are counted, so
+// progress is the number of bytes streamed. Otherwise Filter may parse
+// progress out of the output lines; returning false drops the line from the
+// log. Total, evaluated after Prepare, is the expected byte count. Finish
+// runs after a successful transfer with the final progress (e.g. to write
+// the manifest); its error fails the run. Cleanup removes partial remote
+// files and snapshots when the transfer or Finish fails or is cancelled.
+// Artifact, if set, is what a successful run leaves on the remote.
+type Plan struct {
+    Prepare  []pipeline.Stage
+    Stream   *pipeline.Pipeline
+    Meter    int
+    Total    func(ctx context.Context) int64
+    Filter   func(line string, m *Meter) (string, bool)
+    Finish   func(ctx context.Context, done Progress) error
+    Cleanup  []pipeline.Stage
+    Artifact string
+}
+
+// ErrAborted wraps the error of a run whose context was cancelled.
+var ErrAborted = errors.New("aborted")
+
+// cleanupTimeout bounds Plan.Cleanup, which runs after the run's context is
+// already cancelled.
+const cleanupTimeout = 2 * time.Minute
+
+// Artifact is one backup on the remote: an image file, a mirror directory,
+// a borg archive or a snapshot. Artifacts of different Groups (e.g. one per
+// btrfs subvolume) are separate series, retained independently.
+type Artifact struct {
+    Name  string    `json:"name"`
+    Path  string    `json:"path"` // remote path, repo::archive or dataset@snap
+    Size  int64     `json:"size"` // bytes; 0 when unknown
+    Time  time.Time `json:"time"`
+    Group string    `json:"group,omitempty"`
+}
+
+// RestoreOptions selects what to restore and where to.
+type RestoreOptions struct {
+    Artifact Artifact
+    Target   string // disk, directory or dataset, depending on the strategy
+}
+
+// --------------------------- REGISTRY ---------------------------
+
+var (
+    registry = map[config.Strategy]Backend{}
+    order    []config.Strategy
+)
+
+// Register adds a backend; strategies appear in the picker in registration
+// order. Registering the same strategy twice panics.
+func Register(b Backend) {
+    s := b.Strategy()
+    if _, dup := registry[s]; dup { panic("backend: duplicate strategy " + string(s)) }
+    registry[s] = b
+    order = append(order, s)
+}
+
+// Get returns the backend for a strategy.
+func Get(s config.Strategy) (Backend, error) {
+    b, ok := registry[s]
+    if !ok { return nil, fmt.Errorf("unknown strategy %q", s) }
+    return b, nil
+}
+
+// All returns every registered backend in picker order.
+func All() []Backend {
+    out := make([]Backend, 0, len(order))
+    for _, s := range order { out = append(out, registry[s]) }
+    return out
+}
+
+// --------------------------- PREFLIGHT ---------------------------
+
+// Preflight checks tools and SSH reachability for cfg, then runs the
+// backend's own checks. It returns overall success and a printable report.
+func Preflight(ctx context.Context, cfg config.Config) (bool, string) {
+    var rpt bytes.Buffer
+    b, err := Get(cfg.Strategy)
+    if err != nil { fmt.Fprintf(&rpt, "✗ %v\n", err); return false, rpt.String() }
+    ok := preflightCommon(ctx, b, cfg, &rpt)
+    if !b.Preflight(ctx, cfg, &rpt) { ok = false }
+    return ok, rpt.String()
+}
+
+// PreflightRestore runs the same tool and SSH checks as Preflight, then the
+// backend's restore safety checks for opts.
+func PreflightRestore(ctx context.Context, cfg config.Config, opts RestoreOptions) (bool, string) {
+    var rpt bytes.Buffer
+    b, err := Get(cfg.Strategy)
+    if err != nil { fmt.Fprintf(&rpt, "✗ %v\n", err); return false, rpt.String() }
+    ok := preflightCommon(ctx, b, cfg, &rpt)
+    fmt.Fprintf(&rpt, "Checking restore of %s → %s…\n", opts.Artifact.Path, opts.Target)
+    if !b.RestorePreflight(ctx, cfg, opts, &rpt) { ok = false }
+    return ok, rpt.String()
+}
+
+// preflightCommon checks local tools and SSH reachability.
+func preflightCommon(ctx context.Context, b Backend, cfg config.Config, rpt io.Writer) bool {
+    ok := true
+
+    fmt.Fprintf(rpt, "Checking required tools…\n")
+    for _, r := range b.RequiredTools(cfg) {
+        if !Have(r) { ok = false; fmt.Fprintf(rpt, "✗ missing %s\n", r) } else { fmt.Fprintf(rpt, "✓ %s\n", r) }
+    }
+
+    // SSH reachability
+    fmt.Fprintf(rpt, "Testing SSH reachability…\n")
+    sctx, cancel := context.WithTimeout(ctx, 6*time.Second)
+    defer cancel()
+    cmd := os_exec.CommandContext(sctx, "ssh", SSHArgs(cfg, "echo", "ok")...)
+    if out, err := cmd.CombinedOutput(); err != nil || !strings.Contains(string(out), "ok") {
+        ok = false; fmt.Fprintf(rpt, "✗ ssh check failed: %v\n", err)
+    } else { fmt.Fprintf(rpt, "✓ ssh ok\n") }
+    return ok
+}
+
+// --------------------------- EXECUTE ---------------------------
+
+// Execute runs a plan: Prepare steps first (errors logged, not fatal), then
+// the Stream pipeline with its output forwarded into sink line by line. On
+// success the plan's Artifact is reported; on failure the Cleanup steps
+// run, and a cancelled ctx yields an error wrapping ErrAborted.
+func Execute(ctx context.Context, p Plan, sink Sink) error {
+    err := execute(ctx, p, sink)
+    if err == nil {
+        if p.Artifact != "" && sink.Artifact != nil { sink.Artifact(p.Artifact) }
+        return nil
+    }
+    if len(p.Cleanup) > 0 {
+        cctx, cancel := context.WithTimeout(context.Background(), cleanupTimeout)
+        defer cancel()
+        for _, c := range p.Cleanup {
+            sink.info("Cleaning up: %s", c)
+            if out, cerr := c.Command(cctx).CombinedOutput(); cerr != nil {
+                sink.info("  (ignored) %v: %s", cerr, strings.TrimSpace(string(out)))
+            }
+        }
+    }
+    if ctx.Err() != nil { return fmt.Errorf("%w: %v", ErrAborted, err) }
+    return err
+}
+
+func execute(ctx context.Context, p Plan, sink Sink) error {
+    for _, c := range p.Prepare {
+        sink.info("Preparing: %s", c)
+        if out, err := c.Command(ctx).CombinedOutput(); err != nil {
+            sink.info("  (ignored) %v: %s", err, strings.TrimSpace(string(out)))
+        }
+    }
+    if p.Stream == nil || len(p.Stream.Stages) == 0 { return fmt.Errorf("no command") }
+
+    meter := NewMeter(sink.Progress)
+    if p.Total != nil { meter.SetTotal(p.Total(ctx)) }
+    defer meter.Flush()
+    filtered := func(fn func(string)) func(string) {
+        if p.Filter == nil || fn == nil { return fn }
+        return func(line string) { if out, keep := p.Filter(line, meter); keep { fn(out) } }
+    }
+
+    // copy so the plan's own pipeline is left as the backend built it
+    stream := pipeline.New(p.Stream.Stages...)
+    if p.Meter > 0 {
+        stream.Insert(p.Meter, pipeline.Func("meter", func(dst io.Writer, src io.Reader) error {
+            _, err := io.Copy(dst, meter.Reader(src))
+            return err
+        }))
+    }
+    sink.info("Running: %s", stream)
+
+    stderr := filtered(sink.Stderr)
+    opts := pipeline.Options{Stdout: filtered(sink.Stdout)}
+    if stderr != nil {
+        opts.Stderr = func(stage, line string) {
+            // name the stage when several write to the same log
+            if len(stream.Stages) > 1 { line = stage + ": " + line }
+            stderr(line)
+        }
+    }
+    if err := stream.Run(ctx, opts); err != nil { return err }
+    if p.Finish == nil { return nil }
+    return p.Finish(ctx, meter.Progress())
+}
+
+// remoteOutput runs a command on the remote host and returns its stdout.
+func remoteOutput(ctx context.Context, cfg config.Config, args ...string) ([]byte, error) {
+    out, err := proc.Command(ctx, "ssh", SSHArgs(cfg, args...)...).Output()
+    if ee, ok := err.(*os_exec.ExitError); ok {
+        return out, fmt.Errorf("ssh %s: %v: %s", pipeline.Join(args), err, strings.TrimSpace(string(ee.Stderr)))
+    }
+    return out, err
+}
+
+// listRemoteEntries lists dir entries matching a shell glob on the remote,
+// using GNU find for name, size and mtime in one round trip. Manifest and
+// block map sidecars and unfinished .partial files are skipped.
+func listRemoteEntries(ctx context.Context, cfg config.Config, dir, glob string) ([]Artifact, error) {
+    out, err := remoteOutput(ctx, cfg, "find", dir, "-mindepth", "1", "-maxdepth", "1", "-name", glob, "-printf", `%f\t%s\t%T@\n`)
+    if err != nil { return nil, err }
+    var as []Artifact
+    for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+        f := strings.Split(line, "\t")
+        if len(f) != 3 || isManifest(f[0]) || strings.HasSuffix(f[0], blockMapSuffix) || strings.HasSuffix(f[0], partialSuffix) { continue }
+        a := Artifact{Name: f[0], Path: dir + "/" + f[0]}
+        fmt.Sscanf(f[1], "%d", &a.Size)
+        var secs float64
+        fmt.Sscanf(f[2], "%f", &secs)
+        a.Time = time.Unix(int64(secs), 0)
+        as = append(as, a)
+    }
+    return sortArtifacts(as), nil
+}
+
+func sortArtifacts(as []Artifact) []Artifact {
+    sort.SliceStable(as, func(i, j int) bool { return as[i].Time.Before(as[j].Time) })
+    return as
+}
+
+// --------------------------- UTIL ---------------------------
+
+// Have reports whether cmd is on PATH.
+func Have(cmd string) bool {
+    _, err := os_exec.LookPath(cmd)
+    return err == nil
+}
+
+// Hostname is the local host name used in remote paths and archive names.
+func Hostname() string {
+    if h, err := os.Hostname(); err == nil { return h }
+    return "host"
+}
+
+// credentialPath is the file of a systemd credential (LoadCredential=)
+// named name, when running under a unit that loads it.
+func credentialPath(name string) (string, bool) {
+    dir := os.Getenv("CREDENTIALS_DIRECTORY")
+    if dir == "" || name == "" { return "", false }
+    p := dir + "/" + name
+    if _, err := os.Stat(p); err != nil { return "", false }
+    return p, true
+}
+
+// RemoteDir is cfg.RemotePath with $(hostname) expanded.
+func RemoteDir(cfg config.Config) string {
+    return strings.ReplaceAll(cfg.RemotePath, "$(hostname)", Hostname())
+}
+
+// SSHDest is user@host for cfg.
+func SSHDest(cfg config.Config) string {
+    return fmt.Sprintf("%s@%s", cfg.RemoteUser, cfg.RemoteHost)
+}
+
+// SSHArgs returns ssh arguments that run remote on cfg's host. The remote
+// argv is quoted into one command line, so paths and globs reach the remote
+// command verbatim rather than being re-split by the remote shell.
+func SSHArgs(cfg config.Config, remote ...string) []string {
+    args := []string{"-p", fmt.Sprint(cfg.SSHPort), "--", SSHDest(cfg)}
+    if len(remote) > 0 { args = append(args, pipeline.Join(remote)) }
+    return args
+}
+
+// sshStage is a pipeline stage running remote on cfg's host.
+func sshStage(cfg config.Config, remote ...string) pipeline.Stage {
+    return pipeline.Cmd(append([]string{"ssh"}, SSHArgs(cfg, remote...)...)...)
+}
+
+// partialSuffix marks remote files still being written; they are renamed
+// into place by remoteCommit only after the whole pipeline succeeded.
+const partialSuffix = ".partial"
+
+// remoteWriteStage writes its stdin to path on cfg's host and fsyncs it.
+// The path travels as $1, never as shell text.
+func remoteWriteStage(cfg config.Config, path string) pipeline.Stage {
+    return sshStage(cfg, "sh", "-c", `cat > "$1" && { sync "$1" 2>/dev/null || sync; }`, "sh", path)
+}
+
+// remoteCommitScript renames (from, to) pairs in order, refusing to replace
+// any existing destination so two runs never clobber each other's backups.
+const remoteCommitScript = `set -e
+i=0
+for a; do
+    i=$((i+1))
+    if [ $((i%2)) -eq 0 ] && [ -e "$a" ]; then echo "$a already exists" >&2; exit 1; fi
+done
+while [ $# -ge 2 ]; do mv "$1" "$2"; shift 2; done`
+
+// remoteCommit moves staged remote paths into place: pairs of from, to. List
+// the artifact itself last; its appearance is what marks the backup done.
+func remoteCommit(ctx context.Context, cfg config.Config, pairs ...string) error {
+    args := append([]string{"sh", "-c", remoteCommitScript, "sh"}, pairs...)
+    if _, err := remoteOutput(ctx, cfg, args...); err != nil { return fmt.Errorf("commit: %w", err) }
+    return nil
+}
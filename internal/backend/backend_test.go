@@ -0,0 +1,86 @@
+// File: internal/backend/backend_test.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-17
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Tests of the argv the backends plan, without running anything.
+
+package backend
+
+import (
+    regexp "regexp"
+    strings "strings"
+    testing "testing"
+
+    "cloudcurio.cc/octobackup/internal/config"
+)
+
+func testConfig(s config.Strategy) config.Config {
+    return config.Config{
+        RemoteUser: "backup", RemoteHost: "nas.lan", SSHPort: 2222, RemotePath: "/srv/backups/$(hostname)",
+        Strategy: s, Compression: "zstd", Excludes: []string{"/proc/*", "/tmp/*"},
+    }
+}
+
+func plan(t *testing.T, cfg config.Config) Plan {
+    t.Helper()
+    b, err := Get(cfg.Strategy)
+    if err != nil { t.Fatal(err) }
+    p, err := b.Plan(cfg)
+    if err != nil { t.Fatal(err) }
+    return p
+}
+
+func TestSSHArgs(t *testing.T) {
+    got := SSHArgs(testConfig(config.StratDD), "cat", "/srv/a b.img")
+    want := []string{"-p", "2222", "--", "backup@nas.lan", "cat '/srv/a b.img'"}
+    if strings.Join(got, "\x00") != strings.Join(want, "\x00") { t.Errorf("SSHArgs = %q, want %q", got, want) }
+}
+
+func TestDDPlan(t *testing.T) {
+    cfg := testConfig(config.StratDD)
+    cfg.SourceDisk = "/dev/sdz"
+    p := plan(t, cfg)
+    st := p.Stream.Stages
+    if got := strings.Join(st[0].Argv, " "); got != "dd if=/dev/sdz bs=64K" { t.Errorf("source = %s", got) }
+    last := st[len(st)-1]
+    if last.Argv[0] != "ssh" || !strings.HasSuffix(last.Argv[len(last.Argv)-1], ".img.zst.partial") { t.Errorf("sink = %q", last.Argv) }
+    dir := "/srv/backups/" + Hostname() + "/"
+    if !strings.HasPrefix(p.Artifact, dir+"disk-") || !strings.HasSuffix(p.Artifact, ".img.zst") { t.Errorf("artifact = %s", p.Artifact) }
+
+    cfg.SourceDisk = ""
+    if _, err := (ddBackend{}).Plan(cfg); err == nil { t.Error("planned without a source disk") }
+}
+
+func TestRsyncPlan(t *testing.T) {
+    cfg := testConfig(config.StratRsync)
+    cfg.BandwidthKbps = 500
+    argv := plan(t, cfg).Stream.Stages[0].Argv
+    got := strings.Join(argv, " ")
+    want := "rsync -aAXHz --numeric-ids --delete-after --info=progress2 --no-inc-recursive --bwlimit=500 --exclude=/proc/* --exclude=/tmp/* --exclude=/.octobackup-complete --exclude=/.octobackup-index.gz -e ssh -p 2222 / backup@nas.lan:/srv/backups/" + Hostname() + "/"
+    if got != want { t.Errorf("argv =\n  %s\nwant\n  %s", got, want) }
+
+    cfg.RsyncMode = rsyncSnapshots
+    argv = plan(t, cfg).Stream.Stages[0].Argv
+    if !strings.Contains(strings.Join(argv, " "), "--link-dest=/srv/backups/"+Hostname()+"/latest") { t.Errorf("snapshot argv lacks --link-dest: %q", argv) }
+    if dst := argv[len(argv)-1]; !strings.HasSuffix(dst, ".partial/") { t.Errorf("snapshot destination = %s", dst) }
+}
+
+func TestBorgPlan(t *testing.T) {
+    cfg := testConfig(config.StratBorg)
+    cfg.BorgRepo = "ssh://backup@nas.lan/srv/borg/$(hostname)"
+    cfg.BorgPassEnv = "BORG_PASSPHRASE"
+    t.Setenv("BORG_PASSPHRASE", "x")
+    t.Setenv("CREDENTIALS_DIRECTORY", "")
+    src := t.TempDir()
+    cfg.Sources = []config.Source{{Path: src, Excludes: []string{"*.tmp"}}}
+    c := plan(t, cfg).Stream.Stages[0]
+    got := strings.Join(c.Argv, " ")
+    repo := "ssh://backup@nas.lan/srv/borg/" + Hostname()
+    for _, want := range []string{"borg create --stats --progress --log-json", "--exclude sh:/proc/*", "--exclude sh:" + src + "/**/*.tmp", repo + "::" + Hostname() + "-"} {
+        if !strings.Contains(got, want) { t.Errorf("argv %s lacks %s", got, want) }
+    }
+    if !strings.HasSuffix(got, " "+src) { t.Errorf("argv %s does not end with the source", got) }
+    if !regexp.MustCompile(`::[^ ]+-\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d `).MatchString(got) { t.Errorf("archive name in %s has no time of day", got) }
+    if strings.Join(c.Env, " ") != "BORG_PASSCOMMAND=printenv BORG_PASSPHRASE" { t.Errorf("env = %q", c.Env) }
+}
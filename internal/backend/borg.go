@@ -0,0 +1,172 @@
+// File: internal/backend/borg.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Encrypted, deduplicated archives of the sources (fileset.go; default /)
+//   with borg over ssh, honoring the same excludes as rsync. The passphrase is
+//   never stored: BORG_PASSCOMMAND reads it from the env var named by
+//   cfg.BorgPassEnv. Progress comes from borg --progress --log-json.
+
+package backend
+
+import (
+    context "context"
+    json "encoding/json"
+    fmt "fmt"
+    io "io"
+    os "os"
+    os_exec "os/exec"
+    strings "strings"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/pipeline"
+)
+
+type borgBackend struct{}
+
+func init() { Register(borgBackend{}) }
+
+func (borgBackend) Strategy() config.Strategy { return config.StratBorg }
+func (borgBackend) Describe() string          { return "Borg encrypted (dedup/incremental)" }
+
+func (borgBackend) RequiredTools(config.Config) []string { return []string{"ssh", "borg"} }
+
+func (borgBackend) Preflight(_ context.Context, cfg config.Config, rpt io.Writer) bool {
+    if cfg.BorgRepo == "" { fmt.Fprintf(rpt, "✗ borg repo not set\n"); return false }
+    set, err := resolveFileSet(cfg)
+    if err != nil { fmt.Fprintf(rpt, "✗ %v\n", err); return false }
+    reportFileSet(cfg, set, rpt)
+    return true
+}
+
+// borgRepo is cfg.BorgRepo with $(hostname) expanded.
+func borgRepo(cfg config.Config) string {
+    return strings.ReplaceAll(cfg.BorgRepo, "$(hostname)", Hostname())
+}
+
+// borgEnv points borg at the passphrase env var, if one is configured, or
+// at the systemd credential of that name when the variable is unset.
+func borgEnv(cfg config.Config) []string {
+    if cfg.BorgPassEnv == "" { return nil }
+    if p, ok := credentialPath(cfg.BorgPassEnv); ok && os.Getenv(cfg.BorgPassEnv) == "" {
+        return []string{"BORG_PASSCOMMAND=cat " + pipeline.Quote(p)}
+    }
+    return []string{fmt.Sprintf("BORG_PASSCOMMAND=printenv %s", cfg.BorgPassEnv)}
+}
+
+func (borgBackend) Plan(cfg config.Config) (Plan, error) {
+    set, err := resolveFileSet(cfg)
+    if err != nil { return Plan{}, err }
+    repo := borgRepo(cfg)
+    env := borgEnv(cfg)
+    // to the second, so runs on the same day do not collide
+    name := fmt.Sprintf("%s-%s", Hostname(), time.Now().Format("2006-01-02T15:04:05"))
+    snap := repo + "::" + name
+    argv := append([]string{"borg", "create", "--stats", "--progress", "--log-json"}, set.borgArgs()...)
+    argv = append(append(argv, snap), set.Sources...)
+    var total func(context.Context) int64
+    // borg reads the allocated data once; deduplication only shrinks what is
+    // sent. Other source lists have no cheap estimate.
+    if set.isRoot() { total = func(context.Context) int64 { return usedBytes("/") } }
+    return Plan{
+        // ensure repo exists; fails harmlessly when it already does
+        Prepare: []pipeline.Stage{pipeline.Cmd("borg", "init", "--encryption=repokey", repo).WithEnv(env...)},
+        Stream:  pipeline.New(pipeline.Cmd(argv...).WithEnv(env...)),
+        Total:   total,
+        Filter:  borgProgress,
+        // an interrupted create may leave <name>.checkpoint archives behind
+        Cleanup:  []pipeline.Stage{pipeline.Cmd("borg", "delete", "--glob-archives", name+".checkpoint*", repo).WithEnv(env...)},
+        Artifact: snap,
+    }, nil
+}
+
+// borgProgress turns borg --log-json records into progress and plain log
+// lines: archive_progress carries bytes read (create), progress_percent
+// current/total (extract) and log_message the human-readable output.
+func borgProgress(line string, m *Meter) (string, bool) {
+    if !strings.HasPrefix(line, "{") { return line, true }
+    var rec struct {
+        Type         string `json:"type"`
+        Message      string `json:"message"`
+        OriginalSize int64  `json:"original_size"`
+        Current      int64  `json:"current"`
+        Total        int64  `json:"total"`
+        Finished     bool   `json:"finished"`
+    }
+    if err := json.Unmarshal([]byte(line), &rec); err != nil { return line, true }
+    switch rec.Type {
+    case "archive_progress":
+        if !rec.Finished { m.Set(rec.OriginalSize) }
+    case "progress_percent":
+        if rec.Total > 0 { m.SetTotal(rec.Total); m.Set(rec.Current) }
+    case "log_message":
+        return rec.Message, rec.Message != ""
+    }
+    return "", false
+}
+
+func (b borgBackend) Run(ctx context.Context, cfg config.Config, sink Sink) error {
+    p, err := b.Plan(cfg)
+    if err != nil { return err }
+    return Execute(ctx, p, sink)
+}
+
+func (borgBackend) RestorePreflight(_ context.Context, _ config.Config, opts RestoreOptions, rpt io.Writer) bool {
+    return checkDirTarget(opts.Target, rpt)
+}
+
+// Restore extracts an archive into the target directory (borg extracts
+// relative to its working directory).
+func (borgBackend) Restore(ctx context.Context, cfg config.Config, opts RestoreOptions, sink Sink) error {
+    if opts.Target == "" { return fmt.Errorf("restore target directory not set") }
+    c := pipeline.Cmd("borg", "extract", "--progress", "--log-json", opts.Artifact.Path).WithEnv(borgEnv(cfg)...).InDir(opts.Target)
+    return Execute(ctx, Plan{Stream: pipeline.New(c), Filter: borgProgress}, sink)
+}
+
+func (borgBackend) List(ctx context.Context, cfg config.Config) ([]Artifact, error) {
+    repo := borgRepo(cfg)
+    c := pipeline.Cmd("borg", "list", "--json", repo).WithEnv(borgEnv(cfg)...)
+    out, err := c.Command(ctx).Output()
+    if ee, ok := err.(*os_exec.ExitError); ok { return nil, fmt.Errorf("borg list: %v: %s", err, strings.TrimSpace(string(ee.Stderr))) }
+    if err != nil { return nil, err }
+    var res struct {
+        Archives []struct {
+            Name  string `json:"name"`
+            Start string `json:"start"`
+        } `json:"archives"`
+    }
+    if err := json.Unmarshal(out, &res); err != nil { return nil, fmt.Errorf("borg list: %w", err) }
+    var as []Artifact
+    for _, a := range res.Archives {
+        t, _ := time.ParseInLocation("2006-01-02T15:04:05.000000", a.Start, time.Local)
+        as = append(as, Artifact{Name: a.Name, Path: repo + "::" + a.Name, Time: t})
+    }
+    return sortArtifacts(as), nil
+}
+
+// Verify reads every chunk of the archive and checks it against its
+// checksum (borg check --verify-data).
+func (borgBackend) Verify(ctx context.Context, cfg config.Config, a Artifact, _ VerifyOptions, sink Sink) error {
+    c := pipeline.Cmd("borg", "check", "--verify-data", "--progress", "--log-json", a.Path).WithEnv(borgEnv(cfg)...)
+    return Execute(ctx, Plan{Stream: pipeline.New(c), Filter: borgProgress}, sink)
+}
+
+// Delete removes one archive. Space is only freed by compact, which Prune
+// runs once after all deletions.
+func (borgBackend) Delete(ctx context.Context, cfg config.Config, a Artifact) error {
+    return borgOutput(ctx, cfg, "borg", "delete", a.Path)
+}
+
+func (borgBackend) compact(ctx context.Context, cfg config.Config) error {
+    return borgOutput(ctx, cfg, "borg", "compact", borgRepo(cfg))
+}
+
+// borgOutput runs a borg command with the passphrase env and folds its
+// stderr into the error.
+func borgOutput(ctx context.Context, cfg config.Config, argv ...string) error {
+    out, err := pipeline.Cmd(argv...).WithEnv(borgEnv(cfg)...).Command(ctx).CombinedOutput()
+    if err != nil { return fmt.Errorf("%s: %v: %s", strings.Join(argv[:2], " "), err, strings.TrimSpace(string(out))) }
+    return nil
+}
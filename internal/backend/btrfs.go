@@ -0,0 +1,393 @@
+// File: internal/backend/btrfs.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Btrfs snapshot replication. For each configured subvolume (default /) a
+//   read-only snapshot cc-snap-<unix> is taken in cfg.BtrfsSnapshotDir, which
+//   must be on the same filesystem, and sent with btrfs send | ssh btrfs
+//   receive into <remote path>/.incoming. It is moved up into the remote
+//   path only once the receive completed, so a cut receive never looks like
+//   a backup. Progress counts stream bytes against the space used (full
+//   sends only).
+//
+//   Later runs send with -p from the newest local snapshot the remote
+//   confirms: same name in the remote path and a Received UUID equal to the
+//   local snapshot's UUID. After a successful send, older local snapshots of
+//   that subvolume are deleted; the new one is the next parent.
+//
+//   Subvolumes other than the default are kept apart in <label>/ under both
+//   the snapshot dir and the remote path, label being the subvolume name
+//   (@home) or its mount path with / → _ (root for /).
+//
+//   With encryption on, each stream is stored in full as a file
+//   cc-snap-<unix>.btrfs[.zst].age next to any received snapshots and
+//   received locally on restore; the local snapshot is deleted once stored.
+
+package backend
+
+import (
+    context "context"
+    fmt "fmt"
+    io "io"
+    os "os"
+    os_exec "os/exec"
+    path_file "path/filepath"
+    strings "strings"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/pipeline"
+)
+
+type btrfsBackend struct{}
+
+func init() { Register(btrfsBackend{}) }
+
+func (btrfsBackend) Strategy() config.Strategy { return config.StratBtrfs }
+func (btrfsBackend) Describe() string          { return "Btrfs snapshot send/recv" }
+
+func (btrfsBackend) RequiredTools(cfg config.Config) []string {
+    for _, s := range cfg.BtrfsSubvolumes {
+        if !strings.HasPrefix(s, "/") { return []string{"ssh", "btrfs", "findmnt"} }
+    }
+    return []string{"ssh", "btrfs"}
+}
+
+// btrfsDefaultSnapshotDir holds local snapshots unless configured otherwise.
+const btrfsDefaultSnapshotDir = "/.octobackup-snapshots"
+
+// btrfsIncoming is the remote staging directory receives land in before
+// they are complete.
+const btrfsIncoming = ".incoming"
+
+// btrfsLegacySnapshots matches the snapshots older versions left in /tmp.
+const btrfsLegacySnapshots = "/tmp/cc-snap-*"
+
+// btrfsSubvol is one subvolume to replicate.
+type btrfsSubvol struct {
+    label string // "" for the default /, kept in the top-level dirs
+    path  string // mount path
+}
+
+// snapDir is where the subvolume's local snapshots live.
+func (s btrfsSubvol) snapDir(cfg config.Config) string {
+    dir := cfg.BtrfsSnapshotDir
+    if dir == "" { dir = btrfsDefaultSnapshotDir }
+    if s.label == "" { return dir }
+    return dir + "/" + s.label
+}
+
+// remoteDir is where the subvolume's snapshots are received.
+func (s btrfsSubvol) remoteDir(cfg config.Config) string {
+    if s.label == "" { return RemoteDir(cfg) }
+    return RemoteDir(cfg) + "/" + s.label
+}
+
+// btrfsSubvolumes resolves cfg.BtrfsSubvolumes: mount paths are used as is,
+// names such as @home are looked up among the mounted btrfs subvolumes.
+func btrfsSubvolumes(ctx context.Context, cfg config.Config) ([]btrfsSubvol, error) {
+    if len(cfg.BtrfsSubvolumes) == 0 { return []btrfsSubvol{{path: "/"}}, nil }
+    var mounts map[string]string
+    var out []btrfsSubvol
+    for _, s := range cfg.BtrfsSubvolumes {
+        if strings.HasPrefix(s, "/") {
+            label := strings.ReplaceAll(strings.Trim(s, "/"), "/", "_")
+            if label == "" { label = "root" }
+            out = append(out, btrfsSubvol{label: label, path: s})
+            continue
+        }
+        if mounts == nil {
+            var err error
+            if mounts, err = btrfsMounts(ctx); err != nil { return nil, err }
+        }
+        p, ok := mounts[strings.TrimPrefix(s, "/")]
+        if !ok { return nil, fmt.Errorf("btrfs subvolume %s is not mounted", s) }
+        out = append(out, btrfsSubvol{label: s, path: p})
+    }
+    return out, nil
+}
+
+// btrfsMounts maps mounted subvolume names (fsroot without the leading /)
+// to their first mount point.
+func btrfsMounts(ctx context.Context) (map[string]string, error) {
+    out, err := os_exec.CommandContext(ctx, "findmnt", "-rn", "-t", "btrfs", "-o", "FSROOT,TARGET").Output()
+    if err != nil { return nil, fmt.Errorf("findmnt: %w", err) }
+    m := map[string]string{}
+    for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+        f := strings.Fields(line)
+        if len(f) != 2 { continue }
+        name := strings.TrimPrefix(f[0], "/")
+        if _, dup := m[name]; !dup { m[name] = f[1] }
+    }
+    return m, nil
+}
+
+func (btrfsBackend) Preflight(ctx context.Context, cfg config.Config, rpt io.Writer) bool {
+    subs, err := btrfsSubvolumes(ctx, cfg)
+    if err != nil { fmt.Fprintf(rpt, "✗ %v\n", err); return false }
+    ok := true
+    for _, s := range subs {
+        if !onBtrfs(ctx, s.path) { ok = false; fmt.Fprintf(rpt, "✗ %s is not on a btrfs filesystem\n", s.path); continue }
+        dir := s.snapDir(cfg)
+        if !encrypted(cfg) {
+            if parent, _ := btrfsParent(ctx, cfg, s); parent != "" {
+                fmt.Fprintf(rpt, "✓ %s: incremental send from %s\n", s.path, path_file.Base(parent))
+            } else {
+                fmt.Fprintf(rpt, "• %s: no confirmed parent; full send\n", s.path)
+            }
+        }
+        fmt.Fprintf(rpt, "• %s: snapshots in %s\n", s.path, dir)
+    }
+    if !encrypted(cfg) { return ok }
+    e, err := checkEncode(cfg)
+    if err != nil { fmt.Fprintf(rpt, "✗ %v\n", err); return false }
+    fmt.Fprintf(rpt, "✓ streams stored as files, %s (full sends)\n", e)
+    return ok
+}
+
+// onBtrfs reports whether path is on a btrfs filesystem.
+func onBtrfs(ctx context.Context, path string) bool {
+    out, err := os_exec.CommandContext(ctx, "stat", "-f", "-c", "%T", path).Output()
+    return err == nil && strings.TrimSpace(string(out)) == "btrfs"
+}
+
+// btrfsStreamFile reports whether an artifact is a stored send stream; the
+// subvolume it holds is named by the part before the extension.
+func btrfsStreamFile(a Artifact) (subvol string, ok bool) {
+    name := path_file.Base(a.Path)
+    i := strings.Index(name, ".btrfs")
+    if i < 0 { return name, false }
+    return name[:i], true
+}
+
+// Plan is the plan for the first configured subvolume; Run executes one per
+// subvolume.
+func (btrfsBackend) Plan(cfg config.Config) (Plan, error) {
+    ctx := context.Background()
+    subs, err := btrfsSubvolumes(ctx, cfg)
+    if err != nil { return Plan{}, err }
+    return btrfsPlan(ctx, cfg, subs[0], time.Now())
+}
+
+func btrfsPlan(ctx context.Context, cfg config.Config, s btrfsSubvol, now time.Time) (Plan, error) {
+    name := fmt.Sprintf("cc-snap-%d", now.Unix())
+    snap := s.snapDir(cfg) + "/" + name
+    dir := s.remoteDir(cfg)
+    take := []pipeline.Stage{
+        pipeline.Cmd("mkdir", "-p", s.snapDir(cfg)),
+        pipeline.Cmd("btrfs", "subvolume", "snapshot", "-r", s.path, snap),
+    }
+    used := func(context.Context) int64 { return usedBytes(s.path) }
+    if encrypted(cfg) {
+        sub := cfg
+        sub.RemotePath = dir
+        p, err := encodedFilePlan(sub, name+".btrfs", pipeline.Cmd("btrfs", "send", snap), &Manifest{Strategy: config.StratBtrfs, Created: now.UTC(), Source: s.path})
+        if err != nil { return Plan{}, err }
+        p.Prepare = append(take, p.Prepare...)
+        p.Total = used
+        commit := p.Finish
+        p.Finish = func(ctx context.Context, done Progress) error {
+            if err := commit(ctx, done); err != nil { return err }
+            // stored streams are full; the snapshot is not needed as a parent
+            _ = pipeline.Cmd("btrfs", "subvolume", "delete", snap).Command(ctx).Run()
+            return nil
+        }
+        p.Cleanup = append(p.Cleanup, pipeline.Cmd("btrfs", "subvolume", "delete", snap))
+        return p, nil
+    }
+
+    incoming := dir + "/" + btrfsIncoming
+    send := []string{"btrfs", "send"}
+    parent, err := btrfsParent(ctx, cfg, s)
+    if err != nil { return Plan{}, err }
+    if parent != "" {
+        send = append(send, "-p", parent)
+        used = nil // the delta size is not known up front
+    }
+    return Plan{
+        Prepare: append(take, sshStage(cfg, "mkdir", "-p", incoming)),
+        Stream:  pipeline.New(pipeline.Cmd(append(send, snap)...), sshStage(cfg, "btrfs", "receive", incoming)),
+        Meter:   1,
+        Total:   used,
+        Finish: func(ctx context.Context, _ Progress) error {
+            return remoteCommit(ctx, cfg, incoming+"/"+name, dir+"/"+name)
+        },
+        Cleanup: []pipeline.Stage{
+            sshStage(cfg, "btrfs", "subvolume", "delete", incoming+"/"+name),
+            pipeline.Cmd("btrfs", "subvolume", "delete", snap),
+        },
+        Artifact: dir + "/" + name,
+    }, nil
+}
+
+// btrfsLocalSnapshots lists s's snapshots in the snapshot dir, oldest
+// first; for the default subvolume, also those older versions left in /tmp.
+func btrfsLocalSnapshots(cfg config.Config, s btrfsSubvol) []Artifact {
+    paths, _ := path_file.Glob(s.snapDir(cfg) + "/cc-snap-*")
+    if s.label == "" {
+        legacy, _ := path_file.Glob(btrfsLegacySnapshots)
+        paths = append(paths, legacy...)
+    }
+    var as []Artifact
+    for _, p := range paths {
+        var secs int64
+        name := path_file.Base(p)
+        if _, err := fmt.Sscanf(name, "cc-snap-%d", &secs); err != nil || strings.Contains(name, ".") { continue }
+        as = append(as, Artifact{Name: name, Path: p, Time: time.Unix(secs, 0), Group: s.label})
+    }
+    return sortArtifacts(as)
+}
+
+// btrfsParent is the newest local snapshot of s the remote confirms holding:
+// received under the same name with a Received UUID equal to its UUID. ""
+// means a full send.
+func btrfsParent(ctx context.Context, cfg config.Config, s btrfsSubvol) (string, error) {
+    local := btrfsLocalSnapshots(cfg, s)
+    if len(local) == 0 { return "", nil }
+    remote, err := listRemoteEntries(ctx, cfg, s.remoteDir(cfg), "cc-snap-*")
+    if err != nil {
+        // no remote dir yet: nothing to be incremental to
+        if strings.Contains(err.Error(), "No such file or directory") { return "", nil }
+        return "", fmt.Errorf("remote snapshots of %s: %w", s.remoteDir(cfg), err)
+    }
+    have := map[string]bool{}
+    for _, a := range remote { have[a.Name] = true }
+    for i := len(local) - 1; i >= 0; i-- {
+        l := local[i]
+        if !have[l.Name] { continue }
+        out, err := pipeline.Cmd("btrfs", "subvolume", "show", l.Path).Command(ctx).Output()
+        if err != nil { continue }
+        uuid := btrfsShowField(out, "UUID")
+        rout, err := remoteOutput(ctx, cfg, "btrfs", "subvolume", "show", s.remoteDir(cfg)+"/"+l.Name)
+        if err != nil { return "", err }
+        if uuid != "" && uuid != "-" && btrfsShowField(rout, "Received UUID") == uuid { return l.Path, nil }
+    }
+    return "", nil
+}
+
+// btrfsShowField extracts "key: value" from btrfs subvolume show output.
+func btrfsShowField(out []byte, key string) string {
+    for _, line := range strings.Split(string(out), "\n") {
+        k, v, ok := strings.Cut(strings.TrimSpace(line), ":")
+        if ok && k == key { return strings.TrimSpace(v) }
+    }
+    return ""
+}
+
+// Run replicates each subvolume in turn, then deletes the local snapshots
+// older than the one just sent.
+func (btrfsBackend) Run(ctx context.Context, cfg config.Config, sink Sink) error {
+    subs, err := btrfsSubvolumes(ctx, cfg)
+    if err != nil { return err }
+    now := time.Now()
+    for i, s := range subs {
+        if len(subs) > 1 { sink.info("Subvolume %s (%d/%d)", s.path, i+1, len(subs)) }
+        p, err := btrfsPlan(ctx, cfg, s, now)
+        if err != nil { return err }
+        if err := Execute(ctx, p, sink); err != nil { return fmt.Errorf("%s: %w", s.path, err) }
+        if encrypted(cfg) { continue }
+        keep := fmt.Sprintf("cc-snap-%d", now.Unix())
+        for _, a := range btrfsLocalSnapshots(cfg, s) {
+            if a.Name == keep { continue }
+            sink.info("Deleting stale snapshot %s", a.Path)
+            if out, err := pipeline.Cmd("btrfs", "subvolume", "delete", a.Path).Command(ctx).CombinedOutput(); err != nil {
+                sink.info("  (ignored) %v: %s", err, strings.TrimSpace(string(out)))
+            }
+        }
+    }
+    return nil
+}
+
+func (btrfsBackend) RestorePreflight(ctx context.Context, cfg config.Config, opts RestoreOptions, rpt io.Writer) bool {
+    if !checkDirTarget(opts.Target, rpt) { return false }
+    if !onBtrfs(ctx, opts.Target) { fmt.Fprintf(rpt, "✗ %s is not on a btrfs filesystem\n", opts.Target); return false }
+    subvol, file := btrfsStreamFile(opts.Artifact)
+    if _, err := os.Stat(path_file.Join(opts.Target, subvol)); err == nil {
+        fmt.Fprintf(rpt, "✗ %s already exists in %s\n", subvol, opts.Target); return false
+    }
+    if file { return checkDecode(ctx, cfg, opts.Artifact, false, rpt) }
+    return true
+}
+
+// Restore sends a received snapshot back and receives it under the target
+// directory, from where it can be snapshotted writable or set as default.
+// Received snapshots are complete subvolumes, so this is always a full send.
+func (btrfsBackend) Restore(ctx context.Context, cfg config.Config, opts RestoreOptions, sink Sink) error {
+    if opts.Target == "" { return fmt.Errorf("restore target directory not set") }
+    subvol, file := btrfsStreamFile(opts.Artifact)
+    cleanup := []pipeline.Stage{pipeline.Cmd("btrfs", "subvolume", "delete", path_file.Join(opts.Target, subvol))}
+    if file {
+        stream, err := decodedFileStream(ctx, cfg, opts.Artifact, false, pipeline.Cmd("btrfs", "receive", opts.Target))
+        if err != nil { return err }
+        size := opts.Artifact.Size
+        return Execute(ctx, Plan{
+            Stream:  stream,
+            Meter:   1,
+            Total:   func(context.Context) int64 { return size },
+            Cleanup: cleanup,
+        }, sink)
+    }
+    return Execute(ctx, Plan{
+        Stream:  pipeline.New(sshStage(cfg, "btrfs", "send", opts.Artifact.Path), pipeline.Cmd("btrfs", "receive", opts.Target)),
+        Meter:   1,
+        Cleanup: cleanup,
+    }, sink)
+}
+
+// List returns the received snapshots and stored streams of every
+// configured subvolume; those of non-default subvolumes are named
+// <label>/cc-snap-….
+func (btrfsBackend) List(ctx context.Context, cfg config.Config) ([]Artifact, error) {
+    subs, err := btrfsSubvolumes(ctx, cfg)
+    if err != nil { return nil, err }
+    var all []Artifact
+    for _, s := range subs {
+        as, err := listRemoteEntries(ctx, cfg, s.remoteDir(cfg), "cc-snap-*")
+        if err != nil {
+            if len(subs) > 1 { continue } // a subvolume not backed up yet
+            return nil, err
+        }
+        for _, a := range as {
+            if s.label != "" { a.Name = s.label + "/" + a.Name }
+            a.Group = s.label
+            all = append(all, a)
+        }
+    }
+    return sortArtifacts(all), nil
+}
+
+// Verify checks a stored stream; received subvolumes are checksummed by
+// btrfs itself and checked by btrfs scrub on the backup host.
+func (btrfsBackend) Verify(ctx context.Context, cfg config.Config, a Artifact, opts VerifyOptions, sink Sink) error {
+    if _, file := btrfsStreamFile(a); !file { return fmt.Errorf("%s is a received subvolume; run btrfs scrub on %s to check it", a.Path, cfg.RemoteHost) }
+    return verifyStream(ctx, cfg, a, false, opts, sink)
+}
+
+// Delete removes a received subvolume, or a stored stream file.
+func (btrfsBackend) Delete(ctx context.Context, cfg config.Config, a Artifact) error {
+    if _, file := btrfsStreamFile(a); file { return deleteRemoteFile(ctx, cfg, a) }
+    _, err := remoteOutput(ctx, cfg, "btrfs", "subvolume", "delete", a.Path)
+    return err
+}
+
+// ListLocal returns the local snapshots prune may delete: all but each
+// subvolume's newest, which is the parent of its next send.
+func (btrfsBackend) ListLocal(ctx context.Context, cfg config.Config) ([]Artifact, error) {
+    subs, err := btrfsSubvolumes(ctx, cfg)
+    if err != nil { return nil, err }
+    var all []Artifact
+    for _, s := range subs {
+        as := btrfsLocalSnapshots(cfg, s)
+        if len(as) > 0 && !encrypted(cfg) { as = as[:len(as)-1] }
+        all = append(all, as...)
+    }
+    return sortArtifacts(all), nil
+}
+
+func (btrfsBackend) DeleteLocal(ctx context.Context, _ config.Config, a Artifact) error {
+    if out, err := pipeline.Cmd("btrfs", "subvolume", "delete", a.Path).Command(ctx).CombinedOutput(); err != nil {
+        return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+    }
+    return nil
+}
@@ -0,0 +1,198 @@
+// File: internal/backend/dd.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Raw disk stream: dd if=<disk> | [codec] | [encrypt] | ssh "cat > image",
+//   built as a pipeline so no local shell sees the disk or file names. The
+//   image is written as .partial and renamed once complete; its extension
+//   follows the encoding (.img.zst, .img.gz.age, …) and a manifest records
+//   it. Restore reverses it: ssh cat | [decrypt] | [decompress] | dd
+//   of=<disk>. Progress counts raw bytes read from the disk against its
+//   lsblk size. With dd_chunk_size the image is chunked and resumable
+//   (ddchunks.go); with dd_incremental later runs send only changed blocks
+//   (ddblocks.go); with dd_sparse zero and free blocks are not sent
+//   (ddsparse.go).
+
+package backend
+
+import (
+    context "context"
+    fmt "fmt"
+    io "io"
+    os "os"
+    os_exec "os/exec"
+    strings "strings"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/pipeline"
+)
+
+type ddBackend struct{}
+
+func init() { Register(ddBackend{}) }
+
+func (ddBackend) Strategy() config.Strategy { return config.StratDD }
+func (ddBackend) Describe() string          { return "Raw disk stream (dd → ssh)" }
+
+// RequiredTools needs no compressor binaries: codecs run in-process.
+func (ddBackend) RequiredTools(config.Config) []string { return []string{"ssh", "dd"} }
+
+func (ddBackend) Preflight(ctx context.Context, cfg config.Config, rpt io.Writer) bool {
+    // Disk list for dd safety
+    fmt.Fprintf(rpt, "Listing disks via lsblk…\n")
+    if !Have("lsblk") { fmt.Fprintf(rpt, "✗ need lsblk for dd safety\n"); return false }
+    out, _ := os_exec.CommandContext(ctx, "lsblk", "-o", "NAME,SIZE,TYPE,MODEL").CombinedOutput()
+    fmt.Fprintf(rpt, "%s\n", out)
+    if cfg.SourceDisk == "" { fmt.Fprintf(rpt, "✗ source disk not set\n"); return false }
+    e, err := checkEncode(cfg)
+    if err != nil { fmt.Fprintf(rpt, "✗ %v\n", err); return false }
+    fmt.Fprintf(rpt, "✓ image will be %s (in-process)\n", e)
+    chunk, err := ddChunkSize(cfg)
+    if err != nil { fmt.Fprintf(rpt, "✗ %v\n", err); return false }
+    if chunk > 0 { fmt.Fprintf(rpt, "✓ resumable: %d MiB chunks, an interrupted run continues where it stopped\n", chunk>>20) }
+    switch mode, err := ddSparse(cfg); {
+    case err != nil:
+        fmt.Fprintf(rpt, "✗ %v\n", err); return false
+    case mode == sparseZeros:
+        fmt.Fprintf(rpt, "✓ sparse: all-zero blocks are stored as runs\n")
+    case mode == sparseFS:
+        rw := rwMounts(cfg.SourceDisk)
+        for _, dev := range sortedKeys(rw) { fmt.Fprintf(rpt, "✗ %s is mounted read-write on %s: blocks it allocates while imaging would be taken for free space; unmount it, remount it read-only or use dd_sparse: zeros\n", dev, rw[dev]) }
+        if len(rw) > 0 { return false }
+        fmt.Fprintf(rpt, "✓ sparse: free space of ext2/3/4 and xfs filesystems is not read\n")
+    }
+    if cfg.DDIncremental {
+        bs, err := checkIncremental(cfg)
+        if err != nil { fmt.Fprintf(rpt, "✗ %v\n", err); return false }
+        fmt.Fprintf(rpt, "✓ incremental: changed %d KiB blocks only, a full image every %d runs\n", bs>>10, fullEvery(cfg)+1)
+    }
+    return true
+}
+
+func (ddBackend) Plan(cfg config.Config) (Plan, error) {
+    if cfg.SourceDisk == "" { return Plan{}, fmt.Errorf("source disk not set") }
+    if cfg.DDChunkSize != "" { return Plan{}, fmt.Errorf("chunked images (dd_chunk_size) are planned chunk by chunk as they run") }
+    if cfg.DDIncremental { return Plan{}, fmt.Errorf("incremental images (dd_incremental) are planned against the remote as they run") }
+    if mode, _ := ddSparse(cfg); mode != "" { return Plan{}, fmt.Errorf("sparse images (dd_sparse) are planned against the disk as they run") }
+    disk := cfg.SourceDisk
+    // seconds keep two runs on one day from colliding
+    base := fmt.Sprintf("disk-%s.img", time.Now().Format("20060102-150405"))
+    p, err := encodedFilePlan(cfg, base, pipeline.Cmd("dd", "if="+disk, "bs=64K"), &Manifest{Strategy: config.StratDD, Created: time.Now().UTC(), Source: disk})
+    if err != nil { return Plan{}, err }
+    p.Total = func(ctx context.Context) int64 { return diskSize(ctx, disk) }
+    return p, nil
+}
+
+// diskSize is the size of a block device in bytes per lsblk, or 0.
+func diskSize(ctx context.Context, disk string) int64 {
+    out, err := os_exec.CommandContext(ctx, "lsblk", "-bdno", "SIZE", disk).Output()
+    if err != nil { return 0 }
+    var n int64
+    fmt.Sscanf(strings.TrimSpace(string(out)), "%d", &n)
+    return n
+}
+
+func (b ddBackend) Run(ctx context.Context, cfg config.Config, sink Sink) error {
+    chunk, err := ddChunkSize(cfg)
+    if err != nil { return err }
+    sparse, err := ddSparse(cfg)
+    if err != nil { return err }
+    if (chunk > 0 || cfg.DDIncremental || sparse != "") && cfg.SourceDisk == "" { return fmt.Errorf("source disk not set") }
+    if sparse != "" {
+        p, err := ddSparsePlan(ctx, cfg, sparse, sink)
+        if err != nil { return err }
+        return Execute(ctx, p, sink)
+    }
+    if cfg.DDIncremental {
+        p, err := ddIncrementalPlan(ctx, cfg, sink)
+        if err != nil { return err }
+        return Execute(ctx, p, sink)
+    }
+    if chunk > 0 { return ddChunkedRun(ctx, cfg, chunk, sink) }
+    p, err := b.Plan(cfg)
+    if err != nil { return err }
+    return Execute(ctx, p, sink)
+}
+
+func (ddBackend) RestorePreflight(ctx context.Context, cfg config.Config, opts RestoreOptions, rpt io.Writer) bool {
+    ok := checkDiskTarget(ctx, opts.Target, rpt)
+    if opts.Target == cfg.SourceDisk { ok = false; fmt.Fprintf(rpt, "✗ target is the configured source disk\n") }
+    if !checkDecode(ctx, cfg, opts.Artifact, ddLegacyGzip(cfg), rpt) { ok = false }
+    if isDelta(opts.Artifact) {
+        if chain, _, err := ddChain(ctx, cfg, opts.Artifact); err != nil {
+            ok = false
+            fmt.Fprintf(rpt, "✗ %v\n", err)
+        } else {
+            fmt.Fprintf(rpt, "✓ delta: full image %s, then %d delta(s) written in place\n", chain[0].Name, len(chain)-1)
+        }
+    }
+    return ok
+}
+
+func (ddBackend) Restore(ctx context.Context, cfg config.Config, opts RestoreOptions, sink Sink) error {
+    if opts.Target == "" { return fmt.Errorf("restore target disk not set") }
+    if isChunked(opts.Artifact) { return restoreChunked(ctx, cfg, opts, sink) }
+    if isDelta(opts.Artifact) { return restoreDelta(ctx, cfg, opts, sink) }
+    return ddRestoreImage(ctx, cfg, opts.Artifact, opts.Target, sink)
+}
+
+// ddRestoreImage streams the single-file image a onto target, expanding a
+// sparse image's records; into an image file zero runs become holes.
+func ddRestoreImage(ctx context.Context, cfg config.Config, a Artifact, target string, sink Sink) error {
+    m, merr := readManifest(ctx, cfg, a.Path)
+    if unknownManifest(merr) { return merr }
+    sparse := isSparse(a, m, merr)
+    conv := "conv=fsync"
+    if fi, err := os.Stat(target); sparse && err == nil && fi.Mode().IsRegular() { conv = "conv=sparse,fsync" }
+    stream, err := decodedFileStream(ctx, cfg, a, ddLegacyGzip(cfg), pipeline.Cmd("dd", "of="+target, "bs=64K", conv))
+    if err != nil { return err }
+    if sparse { stream.Insert(len(stream.Stages)-1, sparseExpandStage(m)) }
+    size := a.Size
+    return Execute(ctx, Plan{
+        Stream: stream,
+        Meter:  1,
+        Total:  func(context.Context) int64 { return size },
+    }, sink)
+}
+
+// ddLegacyGzip: images from before manifests were always named .img, and
+// were gzip-compressed when the config said pigz/gzip.
+func ddLegacyGzip(cfg config.Config) bool { return cfg.Compression == "pigz" || cfg.Compression == "gzip" }
+
+func (ddBackend) List(ctx context.Context, cfg config.Config) ([]Artifact, error) {
+    as, err := listRemoteEntries(ctx, cfg, RemoteDir(cfg), "disk-*.img*")
+    for i := range as {
+        if isChunked(as[i]) { as[i].Size = 0 } // a directory inode's size says nothing
+    }
+    return as, err
+}
+
+// Verify checks the image against its manifest checksums.
+func (ddBackend) Verify(ctx context.Context, cfg config.Config, a Artifact, opts VerifyOptions, sink Sink) error {
+    if isChunked(a) { return verifyChunked(ctx, cfg, a, opts, sink) }
+    if isDelta(a) {
+        chain, _, err := ddChain(ctx, cfg, a)
+        if err != nil { return err }
+        sink.info("%s applies on top of %s and %d delta(s); verify those too", a.Name, chain[0].Name, len(chain)-2)
+    }
+    return verifyStream(ctx, cfg, a, ddLegacyGzip(cfg), opts, sink)
+}
+
+// Delete removes an image, or a chunked image's directory, with its
+// manifest and block map. An image a delta still applies on top of is
+// refused.
+func (b ddBackend) Delete(ctx context.Context, cfg config.Config, a Artifact) error {
+    if isChunked(a) {
+        _, err := remoteOutput(ctx, cfg, "rm", "-rf", "--", a.Path, a.Path+manifestSuffix)
+        return err
+    }
+    bases, err := b.bases(ctx, cfg)
+    if err != nil { return err }
+    for d, base := range bases {
+        if base == a.Name { return fmt.Errorf("%s is the base of %s; delete that first", a.Name, d) }
+    }
+    _, err = remoteOutput(ctx, cfg, "rm", "-f", "--", a.Path, a.Path+manifestSuffix, a.Path+blockMapSuffix)
+    return err
+}
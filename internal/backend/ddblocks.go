@@ -0,0 +1,291 @@
+// File: internal/backend/ddblocks.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Block-level incremental dd images (dd_incremental). Every image carries
+//   a block map, <artifact>.blocks: the hash of each dd_block_size block of
+//   the disk, back to back. A full image is the disk as usual; the next run
+//   fetches the newest image's map, reads the whole disk again, and streams
+//   only the blocks whose hash changed into a delta,
+//   disk-<date>.img.delta<ext>, encoded and checked like an image. Each
+//   delta's manifest names its Base, so restore writes the full image and
+//   then each delta's blocks in place, oldest first. After dd_full_every
+//   deltas the next run is a full image again, bounding the chain.
+//
+//   Delta stream (before compression/encryption):
+//     "OBDELTA1" | block size u64 | disk size u64 |
+//     { block index u64 | block bytes }… | index 2^64-1 (end)
+//   all big-endian; a block is the block size, or shorter at the disk's end.
+
+package backend
+
+import (
+    bufio "bufio"
+    bytes "bytes"
+    context "context"
+    binary "encoding/binary"
+    json "encoding/json"
+    fmt "fmt"
+    io "io"
+    math "math"
+    os "os"
+    strings "strings"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/checksum"
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/pipeline"
+)
+
+const (
+    blockMapSuffix   = ".blocks"
+    deltaInfix       = ".img.delta"
+    deltaMagic       = "OBDELTA1"
+    deltaEnd         = math.MaxUint64
+    defaultBlockSize = 1 << 20
+    defaultFullEvery = 7
+)
+
+// isDelta reports whether a is an incremental dd delta.
+func isDelta(a Artifact) bool { return strings.Contains(a.Name, deltaInfix) }
+
+// ddBlockSize is cfg's block map granularity.
+func ddBlockSize(cfg config.Config) (int64, error) {
+    if cfg.DDBlockSize == "" { return defaultBlockSize, nil }
+    n, err := config.ParseSize(cfg.DDBlockSize)
+    if err != nil { return 0, fmt.Errorf("dd_block_size: %w", err) }
+    if n < 4096 || n%4096 != 0 { return 0, fmt.Errorf("dd_block_size %s is not a multiple of 4K", cfg.DDBlockSize) }
+    return n, nil
+}
+
+// checkIncremental is the preflight for dd_incremental.
+func checkIncremental(cfg config.Config) (int64, error) {
+    if cfg.DDChunkSize != "" { return 0, fmt.Errorf("dd_incremental and dd_chunk_size cannot be combined") }
+    return ddBlockSize(cfg)
+}
+
+// blockDiffer is the stage between dd and the encoding: it hashes every
+// block into a new map and passes the disk through (prev nil: a full
+// image) or writes only the blocks whose hash differs from prev as a delta.
+type blockDiffer struct {
+    prev    []byte
+    bs      int64
+    size    int64
+    alg     string
+    sums    []byte
+    read    int64
+    out     int64
+    changed int
+}
+
+func (d *blockDiffer) stage() pipeline.Stage {
+    return pipeline.Func("blocks", func(dst io.Writer, src io.Reader) error {
+        h := checksum.New(d.alg)
+        bw := bufio.NewWriterSize(dst, 1<<20)
+        write := func(b []byte) error {
+            n, err := bw.Write(b)
+            d.out += int64(n)
+            return err
+        }
+        var u64 [8]byte
+        putIndex := func(i uint64) error { binary.BigEndian.PutUint64(u64[:], i); return write(u64[:]) }
+        if d.prev != nil {
+            if err := write([]byte(deltaMagic)); err != nil { return err }
+            if err := putIndex(uint64(d.bs)); err != nil { return err }
+            if err := putIndex(uint64(d.size)); err != nil { return err }
+        }
+        buf := make([]byte, d.bs)
+        for i := uint64(0); ; i++ {
+            n, err := io.ReadFull(src, buf)
+            if n == 0 && err == io.EOF { break }
+            if err != nil && err != io.ErrUnexpectedEOF { return err }
+            d.read += int64(n)
+            h.Reset()
+            h.Write(buf[:n])
+            sum := h.Sum(nil)
+            off := int(i) * len(sum)
+            d.sums = append(d.sums, sum...)
+            switch {
+            case d.prev == nil:
+                if werr := write(buf[:n]); werr != nil { return werr }
+            case off+len(sum) > len(d.prev) || !bytes.Equal(d.prev[off:off+len(sum)], sum):
+                d.changed++
+                if werr := putIndex(i); werr != nil { return werr }
+                if werr := write(buf[:n]); werr != nil { return werr }
+            }
+            if err == io.ErrUnexpectedEOF { break }
+        }
+        if d.prev != nil {
+            if err := putIndex(deltaEnd); err != nil { return err }
+        }
+        return bw.Flush()
+    })
+}
+
+// ddIncrementalPlan plans a full image with a block map, or, when the
+// newest image has a usable map and the chain is not yet dd_full_every
+// deltas long, a delta against it.
+func ddIncrementalPlan(ctx context.Context, cfg config.Config, sink Sink) (Plan, error) {
+    bs, err := checkIncremental(cfg)
+    if err != nil { return Plan{}, err }
+    alg, err := indexAlg(cfg)
+    if err != nil { return Plan{}, err }
+    disk := cfg.SourceDisk
+    size := diskSize(ctx, disk)
+    if size <= 0 { return Plan{}, fmt.Errorf("cannot read the size of %s; an incremental image needs it", disk) }
+
+    m := &Manifest{Strategy: config.StratDD, Created: time.Now().UTC(), Source: disk, BlockSize: bs, BlockHash: alg, DiskSize: size}
+    d := &blockDiffer{bs: bs, size: size, alg: alg}
+    if prev, pm, why := ddPreviousBlocks(ctx, cfg, *m); why != "" {
+        sink.info("Full image: %s", why)
+    } else {
+        d.prev, m.Base, m.Depth = prev, pm.Name, pm.Depth+1
+        sink.info("Delta against %s (delta %d of %d before the next full image)", pm.Name, m.Depth, fullEvery(cfg))
+    }
+    name := fmt.Sprintf("disk-%s.img", time.Now().Format("20060102-150405"))
+    if d.prev != nil { name = fmt.Sprintf("disk-%s%s", time.Now().Format("20060102-150405"), deltaInfix) }
+    p, err := encodedFilePlan(cfg, name, pipeline.Cmd("dd", "if="+disk, "bs=64K"), m)
+    if err != nil { return Plan{}, err }
+    p.Stream.Insert(1, d.stage())
+    p.Total = func(context.Context) int64 { return size }
+    blocks := p.Artifact + blockMapSuffix
+    finish := p.Finish
+    p.Finish = func(ctx context.Context, done Progress) error {
+        if d.read != size { return fmt.Errorf("read %d bytes of %s, expected %d", d.read, disk, size) }
+        if d.prev != nil {
+            m.Size = d.out
+            sink.info("%d of %d blocks changed (%d MiB of %d MiB)", d.changed, (size+bs-1)/bs, int64(d.changed)*bs>>20, size>>20)
+        }
+        err := pipeline.New(remoteWriteStage(cfg, blocks+partialSuffix)).Run(ctx, pipeline.Options{Stdin: bytes.NewReader(d.sums)})
+        if err != nil { return fmt.Errorf("write block map: %w", err) }
+        if err := remoteCommit(ctx, cfg, blocks+partialSuffix, blocks); err != nil { return err }
+        return finish(ctx, done)
+    }
+    p.Cleanup = append(p.Cleanup, sshStage(cfg, "rm", "-f", blocks+partialSuffix, blocks))
+    return p, nil
+}
+
+func fullEvery(cfg config.Config) int {
+    if cfg.DDFullEvery > 0 { return cfg.DDFullEvery }
+    return defaultFullEvery
+}
+
+// ddPreviousBlocks fetches the block map of the newest image if a delta can
+// be taken against it under m's disk, size, block size and hash; otherwise
+// why says why not.
+func ddPreviousBlocks(ctx context.Context, cfg config.Config, m Manifest) (prev []byte, pm Manifest, why string) {
+    // the remote dir may not exist yet: then there is no earlier image
+    as, _ := ddBackend{}.List(ctx, cfg)
+    for i := len(as) - 1; i >= 0; i-- {
+        if isChunked(as[i]) { continue }
+        a := as[i]
+        pm, err := readManifest(ctx, cfg, a.Path)
+        if err != nil { return nil, pm, a.Name + " has no manifest" }
+        switch {
+        case pm.BlockSize == 0:
+            return nil, pm, a.Name + " has no block map"
+        case pm.Source != m.Source || pm.DiskSize != m.DiskSize:
+            return nil, pm, a.Name + " is of another disk or size"
+        case pm.BlockSize != m.BlockSize || pm.BlockHash != m.BlockHash:
+            return nil, pm, a.Name + " has another block size or hash"
+        case pm.Depth+1 > fullEvery(cfg):
+            return nil, pm, fmt.Sprintf("%d deltas since the last one", pm.Depth)
+        }
+        out, err := remoteOutput(ctx, cfg, "cat", a.Path+blockMapSuffix)
+        blocks := (m.DiskSize + m.BlockSize - 1) / m.BlockSize
+        if err != nil || int64(len(out)) != blocks*int64(checksum.New(m.BlockHash).Size()) { return nil, pm, a.Name + "'s block map is missing or short" }
+        return out, pm, ""
+    }
+    return nil, pm, "no earlier image"
+}
+
+// --------------------------- CHAINS ---------------------------
+
+// bases maps each delta in cfg's remote dir to the backup it applies on
+// top of, reading all delta manifests in one round trip.
+func (ddBackend) bases(ctx context.Context, cfg config.Config) (map[string]string, error) {
+    out, err := remoteOutput(ctx, cfg, "sh", "-c", `for f in "$1"/disk-*`+deltaInfix+`*`+manifestSuffix+`; do [ -f "$f" ] && cat "$f"; done; true`, "sh", RemoteDir(cfg))
+    if err != nil { return nil, err }
+    bs := map[string]string{}
+    dec := json.NewDecoder(bytes.NewReader(out))
+    for dec.More() {
+        var m Manifest
+        if err := dec.Decode(&m); err != nil { return nil, fmt.Errorf("delta manifests: %w", err) }
+        bs[m.Name] = m.Base
+    }
+    return bs, nil
+}
+
+// ddChain returns what restoring a takes: the full image, then each delta
+// up to a, with their manifests.
+func ddChain(ctx context.Context, cfg config.Config, a Artifact) ([]Artifact, []Manifest, error) {
+    as, err := ddBackend{}.List(ctx, cfg)
+    if err != nil { return nil, nil, err }
+    byName := map[string]Artifact{}
+    for _, x := range as { byName[x.Name] = x }
+    var (
+        chain []Artifact
+        ms    []Manifest
+    )
+    for cur := a; ; {
+        m, err := readManifest(ctx, cfg, cur.Path)
+        if err != nil { return nil, nil, fmt.Errorf("%s: %w", cur.Name, err) }
+        chain, ms = append([]Artifact{cur}, chain...), append([]Manifest{m}, ms...)
+        if m.Base == "" { break }
+        next, ok := byName[m.Base]
+        if !ok { return nil, nil, fmt.Errorf("%s needs %s, which is not on the remote", cur.Name, m.Base) }
+        if len(chain) > 10000 { return nil, nil, fmt.Errorf("%s: delta chain loops", a.Name) }
+        cur = next
+    }
+    return chain, ms, nil
+}
+
+// applyDeltaStage writes the blocks of a decoded delta into target in
+// place and syncs it.
+func applyDeltaStage(target string, m Manifest, applied *int) pipeline.Stage {
+    return pipeline.Func("apply", func(_ io.Writer, src io.Reader) error {
+        f, err := os.OpenFile(target, os.O_WRONLY, 0)
+        if err != nil { return err }
+        defer f.Close()
+        r := bufio.NewReaderSize(src, 1<<20)
+        var hdr [len(deltaMagic) + 16]byte
+        if _, err := io.ReadFull(r, hdr[:]); err != nil || string(hdr[:len(deltaMagic)]) != deltaMagic { return fmt.Errorf("not a delta stream") }
+        bs := int64(binary.BigEndian.Uint64(hdr[len(deltaMagic):]))
+        size := int64(binary.BigEndian.Uint64(hdr[len(deltaMagic)+8:]))
+        if bs != m.BlockSize || size != m.DiskSize { return fmt.Errorf("delta header (%d/%d) does not match its manifest (%d/%d)", bs, size, m.BlockSize, m.DiskSize) }
+        buf := make([]byte, bs)
+        var u64 [8]byte
+        for {
+            if _, err := io.ReadFull(r, u64[:]); err != nil { return fmt.Errorf("delta truncated: %w", err) }
+            i := binary.BigEndian.Uint64(u64[:])
+            if i == deltaEnd { break }
+            off := int64(i) * bs
+            if i >= uint64(size) || off >= size { return fmt.Errorf("delta block %d is past the disk's end", i) }
+            n := min(bs, size-off)
+            if _, err := io.ReadFull(r, buf[:n]); err != nil { return fmt.Errorf("delta truncated: %w", err) }
+            if _, err := f.WriteAt(buf[:n], off); err != nil { return err }
+            *applied++
+        }
+        return f.Sync()
+    })
+}
+
+// restoreDelta writes a's chain into opts.Target: the full image, then each
+// delta's blocks.
+func restoreDelta(ctx context.Context, cfg config.Config, opts RestoreOptions, sink Sink) error {
+    chain, ms, err := ddChain(ctx, cfg, opts.Artifact)
+    if err != nil { return err }
+    sink.info("Restoring %s from %s and %d delta(s)", opts.Artifact.Name, chain[0].Name, len(chain)-1)
+    if err := ddRestoreImage(ctx, cfg, chain[0], opts.Target, sink); err != nil { return err }
+    for i, a := range chain[1:] {
+        applied := 0
+        stream, err := decodedFileStream(ctx, cfg, a, false, applyDeltaStage(opts.Target, ms[i+1], &applied))
+        if err != nil { return err }
+        size := a.Size
+        err = Execute(ctx, Plan{Stream: stream, Meter: 1, Total: func(context.Context) int64 { return size }}, sink)
+        if err != nil { return fmt.Errorf("%s: %w", a.Name, err) }
+        sink.info("✓ %s: %d block(s) written", a.Name, applied)
+    }
+    return nil
+}
@@ -0,0 +1,93 @@
+// File: internal/backend/ddblocks_test.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-17
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Round trips of block deltas: the differ's full image and map, then a
+//   delta against it applied to a copy of the old disk, and the deltas
+//   apply refuses. The in-process filters run directly.
+
+package backend
+
+import (
+    bytes "bytes"
+    os "os"
+    path_file "path/filepath"
+    testing "testing"
+)
+
+// disk builds a test disk from runs: a byte and how many of it.
+func disk(runs ...any) []byte {
+    var b []byte
+    for i := 0; i+1 < len(runs); i += 2 { b = append(b, bytes.Repeat([]byte{runs[i].(byte)}, runs[i+1].(int))...) }
+    return b
+}
+
+// --------------------------- BLOCK DELTAS ---------------------------
+
+func TestBlockDeltaRoundTrip(t *testing.T) {
+    const bs = 4096
+    old := disk(byte('a'), bs, byte('b'), bs, byte('c'), bs, byte('d'), 1000)
+    cases := []struct {
+        name    string
+        new     []byte
+        changed int
+    }{
+        {"unchanged", old, 0},
+        {"one block", disk(byte('a'), bs, byte('B'), bs, byte('c'), bs, byte('d'), 1000), 1},
+        {"one byte of the tail", append(append([]byte(nil), old[:len(old)-1]...), 'e'), 1},
+        {"all", disk(byte('z'), 3*bs+1000), 4},
+    }
+    // a full image: the disk passes through and its map is kept
+    full := &blockDiffer{bs: bs, size: int64(len(old)), alg: "sha256"}
+    var image bytes.Buffer
+    if err := full.stage().Filter(&image, bytes.NewReader(old)); err != nil { t.Fatal(err) }
+    if !bytes.Equal(image.Bytes(), old) { t.Fatal("a full image is not the disk") }
+
+    for _, c := range cases {
+        d := &blockDiffer{prev: full.sums, bs: bs, size: int64(len(c.new)), alg: "sha256"}
+        var delta bytes.Buffer
+        if err := d.stage().Filter(&delta, bytes.NewReader(c.new)); err != nil { t.Errorf("%s: %v", c.name, err); continue }
+        if d.changed != c.changed { t.Errorf("%s: %d block(s) changed, want %d", c.name, d.changed, c.changed) }
+        if d.out != int64(delta.Len()) || d.read != int64(len(c.new)) { t.Errorf("%s: out %d read %d, wrote %d of %d", c.name, d.out, d.read, delta.Len(), len(c.new)) }
+
+        target := path_file.Join(t.TempDir(), "disk.img")
+        if err := os.WriteFile(target, old, 0o600); err != nil { t.Fatal(err) }
+        applied := 0
+        m := Manifest{BlockSize: bs, DiskSize: int64(len(c.new))}
+        if err := applyDeltaStage(target, m, &applied).Filter(nil, bytes.NewReader(delta.Bytes())); err != nil { t.Errorf("%s: apply: %v", c.name, err); continue }
+        got, err := os.ReadFile(target)
+        if err != nil { t.Fatal(err) }
+        if applied != c.changed || !bytes.Equal(got, c.new) { t.Errorf("%s: applied %d block(s), disk matches: %v", c.name, applied, bytes.Equal(got, c.new)) }
+    }
+}
+
+func TestApplyDeltaRefuses(t *testing.T) {
+    const bs = 4096
+    old := disk(byte('a'), 2*bs)
+    full := &blockDiffer{bs: bs, size: int64(len(old)), alg: "sha256"}
+    if err := full.stage().Filter(&bytes.Buffer{}, bytes.NewReader(old)); err != nil { t.Fatal(err) }
+    d := &blockDiffer{prev: full.sums, bs: bs, size: int64(len(old)), alg: "sha256"}
+    var delta bytes.Buffer
+    if err := d.stage().Filter(&delta, bytes.NewReader(disk(byte('a'), bs, byte('b'), bs))); err != nil { t.Fatal(err) }
+    good := delta.Bytes()
+
+    hdr := len(deltaMagic) + 16
+    past := append(append([]byte(nil), good[:hdr]...), 0, 0, 0, 0, 0, 0, 0, 9)
+    cases := map[string]struct {
+        stream []byte
+        m      Manifest
+    }{
+        "not a delta":  {[]byte("OBSPARS1xxxxxxxxxxxxxxxx"), Manifest{BlockSize: bs, DiskSize: 2 * bs}},
+        "block size":   {good, Manifest{BlockSize: 2 * bs, DiskSize: 2 * bs}},
+        "disk size":    {good, Manifest{BlockSize: bs, DiskSize: 3 * bs}},
+        "truncated":    {good[:len(good)-1], Manifest{BlockSize: bs, DiskSize: 2 * bs}},
+        "past the end": {past, Manifest{BlockSize: bs, DiskSize: 2 * bs}},
+    }
+    for name, c := range cases {
+        target := path_file.Join(t.TempDir(), "disk.img")
+        if err := os.WriteFile(target, old, 0o600); err != nil { t.Fatal(err) }
+        applied := 0
+        if err := applyDeltaStage(target, c.m, &applied).Filter(nil, bytes.NewReader(c.stream)); err == nil { t.Errorf("%s: applied", name) }
+    }
+}
@@ -0,0 +1,276 @@
+// File: internal/backend/ddchunks.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Resumable chunked dd images (dd_chunk_size). The disk is read in fixed
+//   raw-size chunks, each compressed, encrypted and hashed on its own and
+//   written as <dir>/NNNNNN.chunk with a NNNNNN.json record of its size and
+//   sums; the record and chunk are renamed into place together once the
+//   chunk is complete. The directory is disk-<date>.img<ext>.chunks.partial
+//   until every chunk is there. A rerun finds a .partial written from the
+//   same disk with the same settings, asks the remote which chunks are
+//   complete and continues with the missing ones, so a dropped connection
+//   costs at most one chunk. Restore decodes the chunks in order, checking
+//   each one's size and raw sum, into one stream.
+
+package backend
+
+import (
+    bytes "bytes"
+    context "context"
+    json "encoding/json"
+    fmt "fmt"
+    io "io"
+    sort "sort"
+    strings "strings"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/checksum"
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/pipeline"
+)
+
+// chunkDirSuffix ends the name of a chunked image's directory.
+const chunkDirSuffix = ".chunks"
+
+// minChunkSize keeps the per-chunk ssh round trips negligible.
+const minChunkSize = 1 << 20
+
+// chunkRecord is a chunk's NNNNNN.json.
+type chunkRecord struct {
+    Index      int    `json:"index"`
+    Offset     int64  `json:"offset"`
+    Size       int64  `json:"size"` // raw bytes
+    RawSum     string `json:"raw_sum,omitempty"`
+    StoredSum  string `json:"stored_sum,omitempty"`
+    StoredSize int64  `json:"stored_size"`
+}
+
+func chunkFile(i int) string   { return fmt.Sprintf("%06d.chunk", i) }
+func chunkRecFile(i int) string { return fmt.Sprintf("%06d.json", i) }
+
+// isChunked reports whether a is a chunked image directory.
+func isChunked(a Artifact) bool { return strings.HasSuffix(a.Name, chunkDirSuffix) }
+
+// ddChunkSize is cfg's chunk size, or 0 for single-file images.
+func ddChunkSize(cfg config.Config) (int64, error) {
+    if cfg.DDChunkSize == "" { return 0, nil }
+    n, err := config.ParseSize(cfg.DDChunkSize)
+    if err != nil { return 0, fmt.Errorf("dd_chunk_size: %w", err) }
+    if n < minChunkSize { return 0, fmt.Errorf("dd_chunk_size %s is below 1M", cfg.DDChunkSize) }
+    return n, nil
+}
+
+// ddChunkedRun images cfg's disk chunk by chunk into the remote, resuming
+// an interrupted run of the same disk and settings.
+func ddChunkedRun(ctx context.Context, cfg config.Config, chunk int64, sink Sink) error {
+    disk := cfg.SourceDisk
+    size := diskSize(ctx, disk)
+    if size <= 0 { return fmt.Errorf("cannot read the size of %s; a chunked image needs it", disk) }
+    enc, err := encodingFor(cfg)
+    if err != nil { return err }
+    dir := RemoteDir(cfg)
+    if _, err := remoteOutput(ctx, cfg, "mkdir", "-p", dir); err != nil { return err }
+
+    m := Manifest{Strategy: config.StratDD, Created: time.Now().UTC(), Source: disk, Size: size, ChunkSize: chunk, Chunks: int((size + chunk - 1) / chunk)}
+    enc.record(&m)
+    path, done, err := ddResumable(ctx, cfg, &m, sink)
+    if err != nil { return err }
+    if path == "" {
+        m.Name = fmt.Sprintf("disk-%s.img%s%s", time.Now().Format("20060102-150405"), enc.ext(), chunkDirSuffix)
+        path = dir + "/" + m.Name
+        if _, err := remoteOutput(ctx, cfg, "mkdir", path+partialSuffix); err != nil { return err }
+        // staged now so a rerun can tell whether the .partial is resumable
+        if err := writeManifest(ctx, cfg, path, m); err != nil { return err }
+        sink.info("Imaging %s into %d chunk(s) of %d MiB in %s", disk, m.Chunks, chunk>>20, path+partialSuffix)
+    }
+
+    var sent int64
+    for i := 0; i < m.Chunks; i++ {
+        off := int64(i) * chunk
+        n := min(chunk, size-off)
+        if done[i] { sent += n; continue }
+        sink.info("Chunk %d/%d", i+1, m.Chunks)
+        if err := ddSendChunk(ctx, cfg, enc, path+partialSuffix, i, off, n, offsetSink(sink, sent, size)); err != nil {
+            return fmt.Errorf("chunk %d/%d: %w (rerun to resume)", i+1, m.Chunks, err)
+        }
+        sent += n
+    }
+    manifest := path + manifestSuffix
+    if err := remoteCommit(ctx, cfg, manifest+partialSuffix, manifest, path+partialSuffix, path); err != nil { return err }
+    if sink.Artifact != nil { sink.Artifact(path) }
+    return nil
+}
+
+// ddResumable looks for an interrupted chunked image of the same disk,
+// size, chunk size and encoding as m. When there is one, m takes its name
+// and creation time and the complete chunks are returned with its path.
+func ddResumable(ctx context.Context, cfg config.Config, m *Manifest, sink Sink) (string, map[int]bool, error) {
+    dir := RemoteDir(cfg)
+    out, err := remoteOutput(ctx, cfg, "find", dir, "-mindepth", "1", "-maxdepth", "1", "-type", "d", "-name", "disk-*"+chunkDirSuffix+partialSuffix, "-printf", `%f\n`)
+    if err != nil { return "", nil, err }
+    names := strings.Fields(string(out))
+    sort.Sort(sort.Reverse(sort.StringSlice(names)))
+    for _, name := range names {
+        path := dir + "/" + strings.TrimSuffix(name, partialSuffix)
+        b, err := remoteOutput(ctx, cfg, "cat", path+manifestSuffix+partialSuffix)
+        var old Manifest
+        if err != nil || json.Unmarshal(b, &old) != nil { sink.info("Ignoring %s: no readable manifest", name); continue }
+        if old.Source != m.Source || old.Size != m.Size || old.ChunkSize != m.ChunkSize || old.Codec != m.Codec || old.Level != m.Level || old.Encryption != m.Encryption || old.Checksum != m.Checksum {
+            sink.info("Ignoring %s: made from another disk or with other settings", name)
+            continue
+        }
+        recs, err := readChunkRecords(ctx, cfg, path+partialSuffix)
+        if err != nil { return "", nil, err }
+        done := map[int]bool{}
+        for _, r := range recs { done[r.Index] = true }
+        m.Name, m.Created = old.Name, old.Created
+        sink.info("Resuming %s: %d of %d chunks already on %s", name, len(done), m.Chunks, cfg.RemoteHost)
+        return path, done, nil
+    }
+    return "", nil, nil
+}
+
+// readChunkRecords returns the records of the complete chunks in dir (a
+// record only counts when its chunk is there too), by index.
+func readChunkRecords(ctx context.Context, cfg config.Config, dir string) ([]chunkRecord, error) {
+    out, err := remoteOutput(ctx, cfg, "sh", "-c", `cd "$1" && for r in ??????.json; do [ -f "$r" ] && [ -f "${r%.json}.chunk" ] && cat "$r"; done; true`, "sh", dir)
+    if err != nil { return nil, err }
+    var recs []chunkRecord
+    dec := json.NewDecoder(bytes.NewReader(out))
+    for dec.More() {
+        var r chunkRecord
+        if err := dec.Decode(&r); err != nil { return nil, fmt.Errorf("chunk records in %s: %w", dir, err) }
+        recs = append(recs, r)
+    }
+    sort.Slice(recs, func(i, j int) bool { return recs[i].Index < recs[j].Index })
+    return recs, nil
+}
+
+// ddSendChunk streams n bytes of the disk from off through enc into chunk i
+// of dir, then writes its record and renames both into place.
+func ddSendChunk(ctx context.Context, cfg config.Config, enc encoding, dir string, i int, off, n int64, sink Sink) error {
+    encode, err := enc.encodeStages()
+    if err != nil { return err }
+    file, rec := dir+"/"+chunkFile(i), dir+"/"+chunkRecFile(i)
+    raw, stored := checksum.NewTap(enc.sum), checksum.NewTap(enc.sum)
+    src := pipeline.Cmd("dd", "if="+cfg.SourceDisk, "bs=64K", "iflag=skip_bytes,count_bytes", fmt.Sprintf("skip=%d", off), fmt.Sprintf("count=%d", n))
+    return Execute(ctx, Plan{
+        // a chunk without its record is incomplete; drop it before rewriting
+        Prepare: []pipeline.Stage{sshStage(cfg, "rm", "-f", file, rec)},
+        Stream:  pipeline.New(src).Add(raw.Stages(enc.sum)...).Add(encode...).Add(stored.Stages(enc.sum)...).Add(remoteWriteStage(cfg, file+partialSuffix)),
+        Meter:   1,
+        Finish: func(ctx context.Context, done Progress) error {
+            if done.Bytes != n { return fmt.Errorf("read %d bytes of %s at %d, expected %d", done.Bytes, cfg.SourceDisk, off, n) }
+            b, err := json.Marshal(chunkRecord{Index: i, Offset: off, Size: n, RawSum: raw.Sum(), StoredSum: stored.Sum(), StoredSize: stored.Size()})
+            if err != nil { return err }
+            err = pipeline.New(remoteWriteStage(cfg, rec+partialSuffix)).Run(ctx, pipeline.Options{Stdin: bytes.NewReader(append(b, '\n'))})
+            if err != nil { return fmt.Errorf("write chunk record: %w", err) }
+            return remoteCommit(ctx, cfg, rec+partialSuffix, rec, file+partialSuffix, file)
+        },
+        Cleanup: []pipeline.Stage{sshStage(cfg, "rm", "-f", file+partialSuffix, rec+partialSuffix)},
+    }, sink)
+}
+
+// offsetSink reports a part's progress as part of the whole: done bytes
+// already sent of total. Artifacts are left to the caller.
+func offsetSink(sink Sink, done, total int64) Sink {
+    s := sink
+    s.Artifact = nil
+    if sink.Progress != nil {
+        s.Progress = func(p Progress) { p.Bytes += done; p.Total = total; sink.Progress(p) }
+    }
+    return s
+}
+
+// chunkedImage reads the manifest and chunk records of the chunked image a
+// and checks that every chunk is there.
+func chunkedImage(ctx context.Context, cfg config.Config, a Artifact) (Manifest, []chunkRecord, error) {
+    m, err := readManifest(ctx, cfg, a.Path)
+    if err != nil { return m, nil, err }
+    recs, err := readChunkRecords(ctx, cfg, a.Path)
+    if err != nil { return m, nil, err }
+    if len(recs) != m.Chunks { return m, nil, fmt.Errorf("%s has %d of its %d chunks", a.Name, len(recs), m.Chunks) }
+    for i, r := range recs {
+        if r.Index != i { return m, nil, fmt.Errorf("%s is missing chunk %d", a.Name, i) }
+    }
+    return m, recs, nil
+}
+
+// chunkSourceStage writes the decoded chunks of a in order, checking each
+// chunk's size and raw sum against its record.
+func chunkSourceStage(ctx context.Context, cfg config.Config, a Artifact, m Manifest, recs []chunkRecord) (pipeline.Stage, error) {
+    enc, _, err := decodingFor(ctx, cfg, a, false)
+    if err != nil { return pipeline.Stage{}, err }
+    return pipeline.Func("chunks", func(dst io.Writer, _ io.Reader) error {
+        for _, r := range recs {
+            decode, err := enc.decodeStages()
+            if err != nil { return err }
+            tap := checksum.NewTap(m.Checksum)
+            var n int64
+            copyOut := pipeline.Func("copy", func(_ io.Writer, src io.Reader) error {
+                var err error
+                n, err = io.Copy(dst, src)
+                return err
+            })
+            p := pipeline.New(sshStage(cfg, "cat", a.Path+"/"+chunkFile(r.Index))).Add(decode...).Add(tap.Stages(m.Checksum)...).Add(copyOut)
+            if err := p.Run(ctx, pipeline.Options{}); err != nil { return fmt.Errorf("chunk %d: %w", r.Index, err) }
+            if n != r.Size { return fmt.Errorf("chunk %d decodes to %d bytes, its record says %d", r.Index, n, r.Size) }
+            if r.RawSum != "" && tap.Sum() != r.RawSum { return fmt.Errorf("chunk %d: %s is %s, its record says %s", r.Index, m.Checksum, tap.Sum(), r.RawSum) }
+        }
+        return nil
+    }), nil
+}
+
+// restoreChunked streams the chunks of opts.Artifact into the target disk.
+func restoreChunked(ctx context.Context, cfg config.Config, opts RestoreOptions, sink Sink) error {
+    m, recs, err := chunkedImage(ctx, cfg, opts.Artifact)
+    if err != nil { return err }
+    src, err := chunkSourceStage(ctx, cfg, opts.Artifact, m, recs)
+    if err != nil { return err }
+    return Execute(ctx, Plan{
+        Stream: pipeline.New(src, pipeline.Cmd("dd", "of="+opts.Target, "bs=64K", "conv=fsync")),
+        Meter:  1,
+        Total:  func(context.Context) int64 { return m.Size },
+    }, sink)
+}
+
+// verifyChunked checks every chunk's stored sum on the remote and, with
+// Decode or without the remote tool, decodes the chunks and checks their
+// raw sums.
+func verifyChunked(ctx context.Context, cfg config.Config, a Artifact, opts VerifyOptions, sink Sink) error {
+    m, recs, err := chunkedImage(ctx, cfg, a)
+    if err != nil { return err }
+    decode := opts.Decode || m.Checksum == ""
+    if !decode {
+        tool := checksum.RemoteTool(m.Checksum)
+        if _, err := remoteOutput(ctx, cfg, "sh", "-c", `command -v "$1"`, "sh", tool); err != nil {
+            sink.info("%s is not installed on %s; decoding the chunks instead", tool, cfg.RemoteHost)
+            decode = true
+        } else {
+            sink.info("Running: %s on the %d chunks of %s on %s", tool, len(recs), a.Name, cfg.RemoteHost)
+            out, err := remoteOutput(ctx, cfg, "sh", "-c", `cd "$1" && "$2" ??????.chunk`, "sh", a.Path, tool)
+            if err != nil { return err }
+            sums := map[string]string{}
+            for _, line := range strings.Split(string(out), "\n") {
+                if p, sum, ok := parseSumLine(line); ok { sums[p] = sum }
+            }
+            for _, r := range recs {
+                if got := sums[chunkFile(r.Index)]; got != r.StoredSum { return fmt.Errorf("%s chunk %d: stored %s is %s, its record says %s", a.Name, r.Index, m.Checksum, got, r.StoredSum) }
+            }
+            sink.info("✓ all %d chunks of %s match their stored %s", len(recs), a.Name, m.Checksum)
+        }
+    }
+    if !decode { return nil }
+    src, err := chunkSourceStage(ctx, cfg, a, m, recs)
+    if err != nil { return err }
+    err = Execute(ctx, Plan{
+        Stream: pipeline.New(src, discardStage()),
+        Meter:  1,
+        Total:  func(context.Context) int64 { return m.Size },
+    }, sink)
+    if err != nil { return err }
+    sink.info("✓ %s decodes to %d bytes in %d chunks matching their records", a.Name, m.Size, len(recs))
+    return nil
+}
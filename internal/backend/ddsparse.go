@@ -0,0 +1,434 @@
+// File: internal/backend/ddsparse.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Sparse dd images (dd_sparse). The image is a record stream in which
+//   runs of all-zero 4 KiB blocks are a length instead of bytes:
+//     • zeros — dd's output is scanned and zero runs collapsed.
+//     • fs    — the disk is read here, and ranges that the filesystems on
+//               it report free (dumpe2fs for ext2/3/4, xfs_db for xfs) are
+//               recorded as zeros without being read at all; other
+//               partitions and gaps are read and scanned as with zeros.
+//               A filesystem mounted read-write is read in full: blocks it
+//               allocates meanwhile would still look free in its bitmap.
+//   Restore expands the records into dd, with conv=sparse for an image file
+//   so zero runs become holes; a disk gets real zeros, so the result equals
+//   the source except that free space reads back as zeros.
+//
+//   Stream (before compression/encryption), big-endian:
+//     "OBSPARS1" | disk size u64 | { 'D' len u64 bytes… | 'Z' len u64 }… | 'E'
+
+package backend
+
+import (
+    bufio "bufio"
+    bytes "bytes"
+    context "context"
+    binary "encoding/binary"
+    fmt "fmt"
+    io "io"
+    os "os"
+    os_exec "os/exec"
+    path_file "path/filepath"
+    sort "sort"
+    strconv "strconv"
+    strings "strings"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/pipeline"
+)
+
+const (
+    sparseZeros  = "zeros"
+    sparseFS     = "fs"
+    sparseMagic  = "OBSPARS1"
+    sparseInfix  = ".img.sparse"
+    sparsePiece  = 4096
+    sparseBuffer = 1 << 20
+)
+
+// ddSparse is cfg's sparse mode, "" when off.
+func ddSparse(cfg config.Config) (string, error) {
+    switch cfg.DDSparse {
+    case "", "off", "none":
+        return "", nil
+    case sparseZeros, sparseFS:
+        if cfg.DDChunkSize != "" || cfg.DDIncremental { return "", fmt.Errorf("dd_sparse cannot be combined with dd_chunk_size or dd_incremental") }
+        return cfg.DDSparse, nil
+    }
+    return "", fmt.Errorf("unknown dd_sparse %q (want zeros, fs or off)", cfg.DDSparse)
+}
+
+// isSparse reports whether a is a sparse image by its manifest m, or by
+// name when it has none.
+func isSparse(a Artifact, m Manifest, merr error) bool {
+    if merr == nil { return m.Sparse != "" }
+    return strings.Contains(a.Name, sparseInfix)
+}
+
+// --------------------------- ENCODING ---------------------------
+
+// sparseWriter writes the record stream, merging adjacent runs.
+type sparseWriter struct {
+    w     *bufio.Writer
+    n     int64 // bytes written
+    data  []byte
+    zeros int64
+}
+
+func newSparseWriter(dst io.Writer, size int64) (*sparseWriter, error) {
+    s := &sparseWriter{w: bufio.NewWriterSize(dst, sparseBuffer)}
+    if err := s.put([]byte(sparseMagic)); err != nil { return nil, err }
+    return s, s.putLen(uint64(size))
+}
+
+func (s *sparseWriter) put(b []byte) error {
+    n, err := s.w.Write(b)
+    s.n += int64(n)
+    return err
+}
+
+func (s *sparseWriter) putLen(n uint64) error {
+    var b [8]byte
+    binary.BigEndian.PutUint64(b[:], n)
+    return s.put(b[:])
+}
+
+func (s *sparseWriter) flushData() error {
+    if len(s.data) == 0 { return nil }
+    if err := s.put([]byte{'D'}); err != nil { return err }
+    if err := s.putLen(uint64(len(s.data))); err != nil { return err }
+    err := s.put(s.data)
+    s.data = s.data[:0]
+    return err
+}
+
+func (s *sparseWriter) flushZeros() error {
+    if s.zeros == 0 { return nil }
+    if err := s.put([]byte{'Z'}); err != nil { return err }
+    err := s.putLen(uint64(s.zeros))
+    s.zeros = 0
+    return err
+}
+
+// Write takes disk bytes, collapsing all-zero pieces.
+func (s *sparseWriter) Write(b []byte) (int, error) {
+    var zero [sparsePiece]byte
+    for off := 0; off < len(b); off += sparsePiece {
+        p := b[off:min(off+sparsePiece, len(b))]
+        if bytes.Equal(p, zero[:len(p)]) {
+            if err := s.flushData(); err != nil { return off, err }
+            s.zeros += int64(len(p))
+            continue
+        }
+        if err := s.flushZeros(); err != nil { return off, err }
+        s.data = append(s.data, p...)
+        if len(s.data) >= sparseBuffer {
+            if err := s.flushData(); err != nil { return off, err }
+        }
+    }
+    return len(b), nil
+}
+
+// Skip records n bytes of zeros that were not read.
+func (s *sparseWriter) Skip(n int64) error {
+    if err := s.flushData(); err != nil { return err }
+    s.zeros += n
+    return nil
+}
+
+func (s *sparseWriter) Close() error {
+    if err := s.flushData(); err != nil { return err }
+    if err := s.flushZeros(); err != nil { return err }
+    if err := s.put([]byte{'E'}); err != nil { return err }
+    return s.w.Flush()
+}
+
+// sparseEncodeStage turns the disk's bytes into records. free lists the
+// ranges that are not in its input but recorded as zeros; the input is the
+// rest of the disk, in order.
+func sparseEncodeStage(size int64, free []extent, out *int64) pipeline.Stage {
+    return pipeline.Func("sparse", func(dst io.Writer, src io.Reader) error {
+        s, err := newSparseWriter(dst, size)
+        if err != nil { return err }
+        buf := make([]byte, sparseBuffer)
+        pos := int64(0)
+        for _, e := range append(free, extent{off: size}) {
+            n, err := io.CopyBuffer(s, io.LimitReader(src, e.off-pos), buf)
+            if err != nil { return err }
+            if n != e.off-pos { return fmt.Errorf("disk stream ended at %d bytes, expected %d", pos+n, size) }
+            if err := s.Skip(e.len); err != nil { return err }
+            pos = e.off + e.len
+        }
+        err = s.Close()
+        *out = s.n
+        return err
+    })
+}
+
+// sparseReadStage reads disk, all but the free extents, in order. It needs
+// no input.
+func sparseReadStage(disk string, size int64, free []extent) pipeline.Stage {
+    return pipeline.Func("read-allocated", func(dst io.Writer, _ io.Reader) error {
+        f, err := os.Open(disk)
+        if err != nil { return err }
+        defer f.Close()
+        buf := make([]byte, sparseBuffer)
+        pos := int64(0)
+        for _, e := range append(free, extent{off: size}) {
+            if _, err := io.CopyBuffer(dst, io.NewSectionReader(f, pos, e.off-pos), buf); err != nil { return fmt.Errorf("read %s: %w", disk, err) }
+            pos = e.off + e.len
+        }
+        return nil
+    })
+}
+
+// sparseExpandStage turns records back into the raw disk stream.
+func sparseExpandStage(m Manifest) pipeline.Stage {
+    return pipeline.Func("unsparse", func(dst io.Writer, src io.Reader) error {
+        r := bufio.NewReaderSize(src, sparseBuffer)
+        var hdr [len(sparseMagic) + 8]byte
+        if _, err := io.ReadFull(r, hdr[:]); err != nil || string(hdr[:len(sparseMagic)]) != sparseMagic { return fmt.Errorf("not a sparse image stream") }
+        size := int64(binary.BigEndian.Uint64(hdr[len(sparseMagic):]))
+        if m.DiskSize > 0 && size != m.DiskSize { return fmt.Errorf("sparse header says %d bytes, manifest %d", size, m.DiskSize) }
+        zero := make([]byte, sparseBuffer)
+        var total int64
+        for {
+            kind, err := r.ReadByte()
+            if err != nil { return fmt.Errorf("sparse stream truncated: %w", err) }
+            if kind == 'E' { break }
+            var lb [8]byte
+            if _, err := io.ReadFull(r, lb[:]); err != nil { return fmt.Errorf("sparse stream truncated: %w", err) }
+            n := int64(binary.BigEndian.Uint64(lb[:]))
+            if total+n > size { return fmt.Errorf("sparse stream runs past %d bytes", size) }
+            switch kind {
+            case 'D':
+                if _, err := io.CopyN(dst, r, n); err != nil { return err }
+            case 'Z':
+                for left := n; left > 0; left -= min(left, int64(len(zero))) {
+                    if _, err := dst.Write(zero[:min(left, int64(len(zero)))]); err != nil { return err }
+                }
+            default:
+                return fmt.Errorf("bad sparse record %q", kind)
+            }
+            total += n
+        }
+        if total != size { return fmt.Errorf("sparse stream holds %d bytes, expected %d", total, size) }
+        return nil
+    })
+}
+
+// --------------------------- FREE SPACE ---------------------------
+
+// extent is a byte range of the disk.
+type extent struct{ off, len int64 }
+
+// freeSpace asks the filesystems on disk for their unallocated ranges,
+// as disk byte offsets, sorted and merged. note receives one line per
+// filesystem.
+func freeSpace(ctx context.Context, disk string, note func(string)) ([]extent, error) {
+    out, err := os_exec.CommandContext(ctx, "lsblk", "-pPno", "NAME,FSTYPE,TYPE", disk).Output()
+    if err != nil { return nil, fmt.Errorf("lsblk %s: %w", disk, err) }
+    rw := rwMounts(disk)
+    var all []extent
+    for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+        kv := lsblkPairs(line)
+        dev, fstype := kv["NAME"], kv["FSTYPE"]
+        if dev == "" || kv["TYPE"] != "part" && dev != disk { continue }
+        if mp, ok := rw[dev]; ok { note(fmt.Sprintf("• %s is mounted read-write on %s; reading it all", dev, mp)); continue }
+        start, size := int64(0), diskSize(ctx, dev)
+        if dev != disk {
+            if start, err = partStart(dev); err != nil { note(fmt.Sprintf("• %s: %v; reading it all", dev, err)); continue }
+        }
+        var es []extent
+        var tool string
+        switch fstype {
+        case "ext2", "ext3", "ext4":
+            tool = "dumpe2fs"
+            es, err = ext4Free(ctx, dev)
+        case "xfs":
+            tool = "xfs_db"
+            es, err = xfsFree(ctx, dev)
+        default:
+            continue
+        }
+        if err != nil { note(fmt.Sprintf("• %s (%s): %s failed (%v); reading it all", dev, fstype, tool, err)); continue }
+        var freeBytes int64
+        for _, e := range es {
+            // never trust a range outside the partition
+            if e.off < 0 || e.len <= 0 || e.off+e.len > size { continue }
+            all = append(all, extent{off: start + e.off, len: e.len})
+            freeBytes += e.len
+        }
+        note(fmt.Sprintf("✓ %s (%s): %d MiB free per %s, not read", dev, fstype, freeBytes>>20, tool))
+    }
+    return mergeExtents(all), nil
+}
+
+// lsblkPairs parses a lsblk -P line: KEY="value" KEY="value".
+func lsblkPairs(line string) map[string]string {
+    kv := map[string]string{}
+    for _, f := range strings.Fields(line) {
+        k, v, ok := strings.Cut(f, "=")
+        if ok { kv[k] = strings.Trim(v, `"`) }
+    }
+    return kv
+}
+
+// partStart is a partition's offset on its disk, from sysfs.
+func partStart(dev string) (int64, error) {
+    b, err := os.ReadFile("/sys/class/block/" + path_file.Base(dev) + "/start")
+    if err != nil { return 0, err }
+    sectors, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+    return sectors * 512, err
+}
+
+func mergeExtents(es []extent) []extent {
+    sort.Slice(es, func(i, j int) bool { return es[i].off < es[j].off })
+    var out []extent
+    for _, e := range es {
+        if n := len(out); n > 0 && e.off <= out[n-1].off+out[n-1].len {
+            out[n-1].len = max(out[n-1].len, e.off+e.len-out[n-1].off)
+            continue
+        }
+        out = append(out, e)
+    }
+    return out
+}
+
+// ext4Free parses the per-group "Free blocks:" lists of dumpe2fs.
+func ext4Free(ctx context.Context, dev string) ([]extent, error) {
+    out, err := os_exec.CommandContext(ctx, "dumpe2fs", dev).Output()
+    if err != nil { return nil, err }
+    var (
+        bs     int64
+        groups bool
+        es     []extent
+    )
+    for _, line := range strings.Split(string(out), "\n") {
+        t := strings.TrimSpace(line)
+        switch {
+        case strings.HasPrefix(t, "Block size:"):
+            bs, _ = strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(t, "Block size:")), 10, 64)
+        case strings.HasPrefix(t, "Group "):
+            groups = true
+        case groups && strings.HasPrefix(t, "Free blocks:"):
+            for _, r := range strings.Split(strings.TrimPrefix(t, "Free blocks:"), ",") {
+                r = strings.TrimSpace(r)
+                if r == "" { continue }
+                a, b, isRange := strings.Cut(r, "-")
+                lo, err1 := strconv.ParseInt(a, 10, 64)
+                hi, err2 := lo, error(nil)
+                if isRange { hi, err2 = strconv.ParseInt(b, 10, 64) }
+                if err1 != nil || err2 != nil || hi < lo { return nil, fmt.Errorf("unexpected free list %q", r) }
+                es = append(es, extent{off: lo * bs, len: (hi - lo + 1) * bs})
+            }
+        }
+    }
+    if bs == 0 || !groups { return nil, fmt.Errorf("no block size or groups in output") }
+    return es, nil
+}
+
+// xfsFree reads the free extents of every allocation group with xfs_db.
+func xfsFree(ctx context.Context, dev string) ([]extent, error) {
+    out, err := os_exec.CommandContext(ctx, "xfs_db", "-r", "-c", "sb 0", "-c", "print blocksize agblocks", dev).Output()
+    if err != nil { return nil, err }
+    var bs, agblocks int64
+    for _, line := range strings.Split(string(out), "\n") {
+        k, v, _ := strings.Cut(line, "=")
+        n, _ := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+        switch strings.TrimSpace(k) {
+        case "blocksize":
+            bs = n
+        case "agblocks":
+            agblocks = n
+        }
+    }
+    if bs == 0 || agblocks == 0 { return nil, fmt.Errorf("no blocksize/agblocks in superblock") }
+    out, err = os_exec.CommandContext(ctx, "xfs_db", "-r", "-c", "freesp -d", dev).Output()
+    if err != nil { return nil, err }
+    var es []extent
+    for _, line := range strings.Split(string(out), "\n") {
+        // detail lines are "agno agbno len"; the histogram has more columns
+        f := strings.Fields(line)
+        if len(f) != 3 { continue }
+        var v [3]int64
+        ok := true
+        for i := range f {
+            if v[i], err = strconv.ParseInt(f[i], 10, 64); err != nil { ok = false }
+        }
+        if !ok || v[1]+v[2] > agblocks { continue }
+        es = append(es, extent{off: (v[0]*agblocks + v[1]) * bs, len: v[2] * bs})
+    }
+    return es, nil
+}
+
+// rwMounts maps the ext2/3/4 and xfs filesystems on disk that are mounted
+// read-write to their mount points. Their free maps can change while the
+// image is read, so fs mode must not trust them.
+func rwMounts(disk string) map[string]string {
+    b, err := os.ReadFile("/proc/self/mounts")
+    if err != nil { return nil }
+    out := map[string]string{}
+    for _, line := range strings.Split(string(b), "\n") {
+        f := strings.Fields(line)
+        if len(f) < 4 || !strings.HasPrefix(f[0], disk) { continue }
+        switch f[2] {
+        case "ext2", "ext3", "ext4", "xfs":
+        default:
+            continue
+        }
+        if opts := "," + f[3] + ","; strings.Contains(opts, ",rw,") { out[f[0]] = unescapeMount(f[1]) }
+    }
+    return out
+}
+
+func sortedKeys(m map[string]string) []string {
+    var keys []string
+    for k := range m { keys = append(keys, k) }
+    sort.Strings(keys)
+    return keys
+}
+
+// --------------------------- PLAN ---------------------------
+
+// ddSparsePlan plans a sparse image: dd into the record encoder, or, in fs
+// mode, the in-process reader that skips free space.
+func ddSparsePlan(ctx context.Context, cfg config.Config, mode string, sink Sink) (Plan, error) {
+    disk := cfg.SourceDisk
+    size := diskSize(ctx, disk)
+    if size <= 0 { return Plan{}, fmt.Errorf("cannot read the size of %s; a sparse image needs it", disk) }
+    m := &Manifest{Strategy: config.StratDD, Created: time.Now().UTC(), Source: disk, Sparse: mode, DiskSize: size}
+    name := fmt.Sprintf("disk-%s%s", time.Now().Format("20060102-150405"), sparseInfix)
+    var out int64
+    if mode == sparseZeros {
+        p, err := encodedFilePlan(cfg, name, pipeline.Cmd("dd", "if="+disk, "bs=64K"), m)
+        if err != nil { return Plan{}, err }
+        p.Stream.Insert(1, sparseEncodeStage(size, nil, &out))
+        p.Total = func(context.Context) int64 { return size }
+        return sparseFinish(p, m, &out), nil
+    }
+    free, err := freeSpace(ctx, disk, func(s string) { sink.info("%s", s) })
+    if err != nil { return Plan{}, err }
+    used := size
+    for _, e := range free { used -= e.len }
+    p, err := encodedFilePlan(cfg, name, sparseReadStage(disk, size, free), m)
+    if err != nil { return Plan{}, err }
+    // the meter counts the allocated bytes read
+    p.Stream.Insert(1, sparseEncodeStage(size, free, &out))
+    p.Total = func(context.Context) int64 { return used }
+    return sparseFinish(p, m, &out), nil
+}
+
+// sparseFinish records the record stream's size, not the disk's, as the
+// manifest's raw size, so verify --decode compares like with like.
+func sparseFinish(p Plan, m *Manifest, out *int64) Plan {
+    finish := p.Finish
+    p.Finish = func(ctx context.Context, done Progress) error {
+        m.Size = *out
+        return finish(ctx, done)
+    }
+    return p
+}
@@ -0,0 +1,110 @@
+// File: internal/backend/ddsparse_test.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-17
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Round trips of sparse images: the record layout, encode then expand with
+//   and without free extents, and the streams expand refuses. The
+//   in-process filters run directly.
+
+package backend
+
+import (
+    bytes "bytes"
+    binary "encoding/binary"
+    strings "strings"
+    testing "testing"
+)
+
+// --------------------------- SPARSE ---------------------------
+
+func sparseEncode(t *testing.T, size int64, free []extent, in []byte) []byte {
+    t.Helper()
+    var out bytes.Buffer
+    var n int64
+    if err := sparseEncodeStage(size, free, &n).Filter(&out, bytes.NewReader(in)); err != nil { t.Fatal(err) }
+    if n != int64(out.Len()) { t.Errorf("encoded size reported %d, wrote %d", n, out.Len()) }
+    return out.Bytes()
+}
+
+func TestSparseRecords(t *testing.T) {
+    d := disk(byte('a'), 4096, byte(0), 8192, byte('b'), 100)
+    got := sparseEncode(t, int64(len(d)), nil, d)
+
+    var want bytes.Buffer
+    rec := func(kind byte, n int) {
+        want.WriteByte(kind)
+        binary.Write(&want, binary.BigEndian, uint64(n))
+    }
+    want.WriteString(sparseMagic)
+    binary.Write(&want, binary.BigEndian, uint64(len(d)))
+    rec('D', 4096)
+    want.Write(d[:4096])
+    rec('Z', 8192)
+    rec('D', 100)
+    want.Write(d[12288:])
+    want.WriteByte('E')
+    if !bytes.Equal(got, want.Bytes()) { t.Errorf("records =\n%q\nwant\n%q", got, want.Bytes()) }
+}
+
+func TestSparseRoundTrip(t *testing.T) {
+    cases := []struct {
+        name string
+        disk []byte
+        free []extent
+    }{
+        {"empty", nil, nil},
+        {"all zeros", disk(byte(0), 3*sparseBuffer+5), nil},
+        {"no zeros", disk(byte('x'), sparseBuffer+4097), nil},
+        {"mixed", disk(byte('a'), 5000, byte(0), 20000, byte('b'), 4096, byte(0), 4096, byte('c'), 1), nil},
+        // a zero run shorter than a piece is data
+        {"short zeros", disk(byte('a'), 10, byte(0), 100, byte('b'), 10), nil},
+        {"free extents", disk(byte('a'), 3*4096, byte('b'), 2*4096, byte('c'), 4096), []extent{{off: 4096, len: 4096}, {off: 5 * 4096, len: 4096}}},
+        {"free at both ends", disk(byte('a'), 4*4096), []extent{{off: 0, len: 4096}, {off: 3 * 4096, len: 4096}}},
+    }
+    for _, c := range cases {
+        size := int64(len(c.disk))
+        // the encoder's input is the disk without its free extents, and
+        // those read back as zeros
+        var in []byte
+        want := append([]byte(nil), c.disk...)
+        pos := int64(0)
+        for _, e := range c.free {
+            in = append(in, c.disk[pos:e.off]...)
+            copy(want[e.off:e.off+e.len], make([]byte, e.len))
+            pos = e.off + e.len
+        }
+        in = append(in, c.disk[pos:]...)
+
+        enc := sparseEncode(t, size, c.free, in)
+        var out bytes.Buffer
+        if err := sparseExpandStage(Manifest{DiskSize: size}).Filter(&out, bytes.NewReader(enc)); err != nil { t.Errorf("%s: %v", c.name, err); continue }
+        if !bytes.Equal(out.Bytes(), want) { t.Errorf("%s: expanded %d bytes differ from the disk", c.name, out.Len()) }
+    }
+}
+
+func TestSparseEncodeShortInput(t *testing.T) {
+    var n int64
+    err := sparseEncodeStage(8192, nil, &n).Filter(&bytes.Buffer{}, bytes.NewReader(make([]byte, 4096)))
+    if err == nil || !strings.Contains(err.Error(), "ended at 4096") { t.Errorf("err = %v", err) }
+}
+
+func TestSparseExpandRefuses(t *testing.T) {
+    d := disk(byte('a'), 100, byte(0), 4096)
+    good := sparseEncode(t, int64(len(d)), nil, d)
+    hdr := len(sparseMagic) + 8
+    cases := map[string]struct {
+        stream []byte
+        size   int64
+    }{
+        "not sparse":     {[]byte("OBDELTA1xxxxxxxxE"), 0},
+        "manifest size":  {good, int64(len(d)) + 1},
+        "truncated":      {good[:len(good)-1], 0},
+        "bad record":     {append(append([]byte(nil), good[:hdr]...), 'Q'), 0},
+        "runs past size": {append(append([]byte(nil), good[:hdr]...), 'Z', 0, 0, 0, 0, 0, 0, 0x20, 0), 0},
+        "ends short":     {append(append([]byte(nil), good[:hdr]...), 'E'), 0},
+    }
+    for name, c := range cases {
+        if err := sparseExpandStage(Manifest{DiskSize: c.size}).Filter(&bytes.Buffer{}, bytes.NewReader(c.stream)); err == nil { t.Errorf("%s: expanded", name) }
+    }
+}
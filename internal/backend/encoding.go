@@ -0,0 +1,229 @@
+// File: internal/backend/encoding.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   How a streamed artifact is transformed before it leaves the host:
+//   compression, then encryption. The same encoding names the file
+//   (.img.zst.age), is recorded in the manifest, and is reversed on restore
+//   — from the manifest first, then the file extensions.
+
+package backend
+
+import (
+    context "context"
+    fmt "fmt"
+    io "io"
+    os "os"
+    strings "strings"
+
+    "cloudcurio.cc/octobackup/internal/checksum"
+    "cloudcurio.cc/octobackup/internal/codec"
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/crypt"
+    "cloudcurio.cc/octobackup/internal/pipeline"
+)
+
+// encoding is a codec (nil: none) followed by an encryption mode, plus the
+// checksum algorithm recorded for the streams ("" = none).
+type encoding struct {
+    codec codec.Codec
+    opts  codec.Options
+    crypt crypt.Settings
+    sum   string
+}
+
+// cryptSettings resolves cfg's encryption, reading the secret from the env
+// var the config names or, under systemd, the credential of that name. A
+// credential is itself the key or identity file; a passphrase is its
+// contents.
+func cryptSettings(cfg config.Config, mode crypt.Mode) crypt.Settings {
+    s := crypt.Settings{Mode: mode, Recipients: cfg.Recipients}
+    if cfg.EncryptSecretEnv == "" { return s }
+    s.Secret = os.Getenv(cfg.EncryptSecretEnv)
+    if p, ok := credentialPath(cfg.EncryptSecretEnv); s.Secret == "" && ok {
+        s.Secret = p
+        if mode == crypt.Passphrase {
+            b, _ := os.ReadFile(p)
+            s.Secret = strings.TrimRight(string(b), "\r\n")
+        }
+    }
+    return s
+}
+
+// encodingFor is the encoding a new backup uses under cfg.
+func encodingFor(cfg config.Config) (encoding, error) {
+    c, err := codec.Lookup(cfg.Compression)
+    if err != nil { return encoding{}, err }
+    mode, err := crypt.ParseMode(cfg.Encryption)
+    if err != nil { return encoding{}, err }
+    sum, err := checksum.Lookup(cfg.Checksum)
+    if err != nil { return encoding{}, err }
+    return encoding{
+        codec: c,
+        opts:  codec.Options{Level: cfg.CompressLevel, Threads: cfg.CompressThreads},
+        crypt: cryptSettings(cfg, mode),
+        sum:   sum,
+    }, nil
+}
+
+// encrypted reports whether cfg asks for client-side encryption. An invalid
+// mode counts as yes, so encodingFor gets to report it.
+func encrypted(cfg config.Config) bool {
+    mode, err := crypt.ParseMode(cfg.Encryption)
+    return err != nil || mode != crypt.None
+}
+
+// ext is the suffix appended to the artifact's base name.
+func (e encoding) ext() string { return codec.Ext(e.codec) + e.crypt.Mode.Ext() }
+
+func (e encoding) String() string {
+    parts := []string{"uncompressed"}
+    if e.codec != nil { parts[0] = e.codec.Name() }
+    if e.crypt.Mode != crypt.None { parts = append(parts, string(e.crypt.Mode)) } else { parts = append(parts, "unencrypted") }
+    if e.sum != "" { parts = append(parts, e.sum+" checksums") }
+    return strings.Join(parts, ", ")
+}
+
+// record notes the encoding in a manifest.
+func (e encoding) record(m *Manifest) {
+    if e.codec != nil { m.Codec, m.Level = e.codec.Name(), e.opts.Level }
+    m.Encryption = string(e.crypt.Mode)
+    m.Checksum = e.sum
+}
+
+// encodeStages compress then encrypt.
+func (e encoding) encodeStages() ([]pipeline.Stage, error) {
+    var st []pipeline.Stage
+    if e.codec != nil { st = append(st, codec.CompressStage(e.codec, e.opts)) }
+    if e.crypt.Mode != crypt.None {
+        s, err := crypt.EncryptStage(e.crypt)
+        if err != nil { return nil, err }
+        st = append(st, s)
+    }
+    return st, nil
+}
+
+// decodeStages decrypt then decompress.
+func (e encoding) decodeStages() ([]pipeline.Stage, error) {
+    var st []pipeline.Stage
+    if e.crypt.Mode != crypt.None {
+        s, err := crypt.DecryptStage(e.crypt)
+        if err != nil { return nil, err }
+        st = append(st, s)
+    }
+    if e.codec != nil { st = append(st, codec.DecompressStage(e.codec, e.opts)) }
+    return st, nil
+}
+
+// checkEncode is the preflight for a new backup under cfg.
+func checkEncode(cfg config.Config) (encoding, error) {
+    e, err := encodingFor(cfg)
+    if err != nil { return e, err }
+    if err := e.crypt.CheckEncrypt(); err != nil { return e, err }
+    return e, nil
+}
+
+// decodingFor works out how to reverse an artifact's encoding: the
+// manifest, else the file extensions. how says which it was. Pre-manifest
+// dd images were always named .img; for those, legacyGzip decides.
+func decodingFor(ctx context.Context, cfg config.Config, a Artifact, legacyGzip bool) (e encoding, how string, err error) {
+    e.opts = codec.Options{Threads: cfg.CompressThreads}
+    if m, merr := readManifest(ctx, cfg, a.Path); merr == nil {
+        if e.codec, err = codec.Lookup(m.Codec); err != nil { return e, "", err }
+        mode, err := crypt.ParseMode(m.Encryption)
+        if err != nil { return e, "", err }
+        e.crypt = cryptSettings(cfg, mode)
+        return e, "manifest", nil
+    } else if unknownManifest(merr) {
+        return e, "", merr
+    }
+
+    how = "file extension"
+    name := a.Name
+    mode := crypt.None
+    switch {
+    case strings.HasSuffix(name, crypt.AESGCM.Ext()):
+        mode = crypt.AESGCM
+    case strings.HasSuffix(name, crypt.Age.Ext()):
+        // the extension cannot tell recipients from passphrase; trust the config
+        mode = crypt.Age
+        if m, _ := crypt.ParseMode(cfg.Encryption); m == crypt.Passphrase { mode = m }
+    }
+    name = strings.TrimSuffix(name, mode.Ext())
+    e.crypt = cryptSettings(cfg, mode)
+    if c, ok := codec.ForFile(name); ok {
+        e.codec = c
+    } else if legacyGzip {
+        e.codec, _ = codec.Lookup("gzip")
+        how = "legacy image, config compression"
+    } else if mode == crypt.None {
+        how = "no manifest"
+    }
+    return e, how, nil
+}
+
+// checkDecode is the restore preflight for a: it reports the encoding found
+// and whether the secret needed to reverse it is available.
+func checkDecode(ctx context.Context, cfg config.Config, a Artifact, legacyGzip bool, rpt io.Writer) bool {
+    e, how, err := decodingFor(ctx, cfg, a, legacyGzip)
+    if err != nil { fmt.Fprintf(rpt, "✗ %v\n", err); return false }
+    fmt.Fprintf(rpt, "✓ artifact is %s (%s)\n", e, how)
+    if err := e.crypt.CheckDecrypt(); err != nil {
+        fmt.Fprintf(rpt, "✗ cannot decrypt: %v (set %s)\n", err, secretEnvName(cfg))
+        return false
+    }
+    return true
+}
+
+func secretEnvName(cfg config.Config) string {
+    if cfg.EncryptSecretEnv == "" { return "encrypt_secret_env" }
+    return "$" + cfg.EncryptSecretEnv
+}
+
+// encodedFilePlan streams source through cfg's encoding into
+// RemoteDir/<base><ext>.partial, hashing the raw stream before encoding
+// and the stored one after it. Once the whole pipeline succeeded the
+// manifest m is written with both sums, and both files are renamed into
+// place, so a cut connection or cancel never leaves a truncated file under
+// a valid name. Callers add their own Prepare steps, Total and Cleanup
+// around it; callers metering something other than the raw stream set
+// m.Size themselves before Finish.
+func encodedFilePlan(cfg config.Config, base string, source pipeline.Stage, m *Manifest) (Plan, error) {
+    enc, err := encodingFor(cfg)
+    if err != nil { return Plan{}, err }
+    encode, err := enc.encodeStages()
+    if err != nil { return Plan{}, err }
+    dir := RemoteDir(cfg)
+    m.Name = base + enc.ext()
+    remoteFile := dir + "/" + m.Name
+    enc.record(m)
+
+    partial := remoteFile + partialSuffix
+    manifest := remoteFile + manifestSuffix
+
+    raw, stored := checksum.NewTap(enc.sum), checksum.NewTap(enc.sum)
+    stream := pipeline.New(source).Add(raw.Stages(enc.sum)...).Add(encode...).Add(stored.Stages(enc.sum)...).Add(remoteWriteStage(cfg, partial))
+    return Plan{
+        Prepare: []pipeline.Stage{sshStage(cfg, "mkdir", "-p", dir)},
+        Stream:  stream,
+        Meter:   1,
+        Finish: func(ctx context.Context, done Progress) error {
+            if m.Size == 0 { m.Size = done.Bytes }
+            m.RawSum, m.StoredSum, m.StoredSize = raw.Sum(), stored.Sum(), stored.Size()
+            if err := writeManifest(ctx, cfg, remoteFile, *m); err != nil { return err }
+            return remoteCommit(ctx, cfg, manifest+partialSuffix, manifest, partial, remoteFile)
+        },
+        Cleanup:  []pipeline.Stage{sshStage(cfg, "rm", "-f", partial, manifest+partialSuffix)},
+        Artifact: remoteFile,
+    }, nil
+}
+
+// decodedFileStream is ssh cat <artifact> | [decrypt] | [decompress] | sink.
+func decodedFileStream(ctx context.Context, cfg config.Config, a Artifact, legacyGzip bool, sink pipeline.Stage) (*pipeline.Pipeline, error) {
+    enc, _, err := decodingFor(ctx, cfg, a, legacyGzip)
+    if err != nil { return nil, err }
+    decode, err := enc.decodeStages()
+    if err != nil { return nil, err }
+    return pipeline.New(sshStage(cfg, "cat", a.Path)).Add(decode...).Add(sink), nil
+}
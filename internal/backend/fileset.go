@@ -0,0 +1,264 @@
+// File: internal/backend/fileset.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-17
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   What a file-level (rsync or borg) backup reads: the sources (default /),
+//   each with its own excludes, the global excludes, one_file_system and the
+//   include_mounts/exclude_mounts choices, resolved into one fileSet that
+//   renders rsync and borg arguments and drives the file index. Preflight
+//   prints the effective set and what happens to every mount below it, so
+//   an NFS share or a USB disk is never backed up, or skipped, by surprise.
+//
+//   Paths keep their absolute form on the remote side: rsync runs with -R
+//   when the sources are not just /, so a backup of /home and /etc holds
+//   home/ and etc/ like a backup of / would.
+
+package backend
+
+import (
+    bufio "bufio"
+    fmt "fmt"
+    io "io"
+    os "os"
+    path "path"
+    strconv "strconv"
+    strings "strings"
+    syscall "syscall"
+
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/fileindex"
+)
+
+// fileSet is the resolved selection of a file-level backup.
+type fileSet struct {
+    Sources  []string // absolute, none inside another unless on its own filesystem
+    Excludes []string // rsync-style, anchored at /
+    Includes []string // mount points kept even when an exclude matches them
+    OneFS    bool
+    notes    []string // sources dropped as covered by another
+}
+
+// mountEntry is a line of /proc/self/mounts.
+type mountEntry struct {
+    Dir  string
+    Type string
+}
+
+// resolveFileSet resolves cfg's sources, excludes and mount choices against
+// the mounts of this host.
+func resolveFileSet(cfg config.Config) (fileSet, error) {
+    set := fileSet{Excludes: append([]string(nil), cfg.Excludes...), OneFS: cfg.OneFileSystem}
+    srcs := cfg.Sources
+    if len(srcs) == 0 { srcs = []config.Source{{Path: "/"}} }
+    mounts, _ := readMounts()
+
+    var cand []string
+    for _, s := range srcs {
+        p, err := absPath("source", s.Path)
+        if err != nil { return fileSet{}, err }
+        if _, err := os.Stat(p); err != nil { return fileSet{}, fmt.Errorf("source %s: %w", p, err) }
+        cand = append(cand, p)
+        set.Excludes = append(set.Excludes, sourceExcludes(p, s.Excludes)...)
+    }
+    for _, m := range cfg.IncludeMounts {
+        p, err := absPath("include_mounts", m)
+        if err != nil { return fileSet{}, err }
+        if _, err := os.Stat(p); err != nil { return fileSet{}, fmt.Errorf("include_mounts %s: %w", p, err) }
+        cand = append(cand, p)
+        set.Includes = append(set.Includes, p)
+    }
+    for _, p := range cand {
+        if set.hasSource(p) { continue }
+        if parent, ok := covering(set.Sources, p); ok && !(set.OneFS && !sameDevice(parent, p)) {
+            set.notes = append(set.notes, fmt.Sprintf("%s is inside %s, already backed up", p, parent))
+            continue
+        }
+        set.Sources = append(set.Sources, p)
+    }
+
+    for _, x := range cfg.ExcludeMounts {
+        if strings.HasPrefix(x, "/") {
+            p := path.Clean(x)
+            if set.hasSource(p) { return fileSet{}, fmt.Errorf("exclude_mounts %s is also a source", p) }
+            set.Excludes = append(set.Excludes, mountExclude(p))
+            continue
+        }
+        for _, m := range mounts {
+            if m.Type != x { continue }
+            if _, ok := covering(set.Sources, m.Dir); !ok || set.hasSource(m.Dir) { continue }
+            set.Excludes = append(set.Excludes, mountExclude(m.Dir))
+        }
+    }
+    return set, nil
+}
+
+func absPath(what, p string) (string, error) {
+    if !strings.HasPrefix(p, "/") { return "", fmt.Errorf("%s %q is not an absolute path", what, p) }
+    return path.Clean(p), nil
+}
+
+// sourceExcludes anchors a source's patterns at its path: /x is <src>/x, a
+// pattern without a leading slash matches anywhere below <src>.
+func sourceExcludes(src string, patterns []string) []string {
+    var out []string
+    for _, p := range patterns {
+        dir := ""
+        if strings.HasSuffix(p, "/") { p, dir = strings.TrimSuffix(p, "/"), "/" }
+        switch {
+        case src == "/":
+            out = append(out, p+dir)
+        case strings.HasPrefix(p, "/"):
+            out = append(out, src+p+dir)
+        default:
+            out = append(out, src+"/"+p+dir, src+"/**/"+p+dir)
+        }
+    }
+    return out
+}
+
+// mountExclude leaves the mount point itself, as rsync -x does, and drops
+// everything on it.
+func mountExclude(mp string) string {
+    if mp == "/" { return "/*" }
+    return mp + "/*"
+}
+
+// covering is the source that p lies strictly inside, if any.
+func covering(sources []string, p string) (string, bool) {
+    for _, s := range sources {
+        if s != p && (s == "/" || strings.HasPrefix(p, s+"/")) { return s, true }
+    }
+    return "", false
+}
+
+func (set fileSet) hasSource(p string) bool {
+    for _, s := range set.Sources {
+        if s == p { return true }
+    }
+    return false
+}
+
+// excludedBy is the pattern excluding p or one of its parents, if any.
+// Included mount points are never excluded.
+func (set fileSet) excludedBy(p string) (string, bool) {
+    for _, in := range set.Includes {
+        if in == p { return "", false }
+    }
+    for q := p; q != "/"; q = path.Dir(q) {
+        for _, x := range set.Excludes {
+            if fileindex.Excluded([]string{x}, q, true) { return x, true }
+        }
+    }
+    return "", false
+}
+
+// isRoot reports whether the set is all of / (the pre-sources layout).
+func (set fileSet) isRoot() bool { return len(set.Sources) == 1 && set.Sources[0] == "/" }
+
+// rsyncArgs are the selection options, without the source arguments.
+func (set fileSet) rsyncArgs() []string {
+    var args []string
+    if set.OneFS { args = append(args, "-x") }
+    if !set.isRoot() { args = append(args, "-R") }
+    for _, in := range set.Includes { args = append(args, "--include="+in+"/") }
+    for _, ex := range set.Excludes { args = append(args, "--exclude="+ex) }
+    return args
+}
+
+// borgArgs are the borg create options for the selection; borg has no
+// directory-only patterns, so a trailing / is dropped, and a pattern
+// without a leading slash matches at any depth. Patterns match by prefix,
+// so an included mount is a path-prefix include ahead of the excludes: an
+// exclude such as /mnt/* would otherwise drop everything below it.
+func (set fileSet) borgArgs() []string {
+    var args []string
+    if set.OneFS { args = append(args, "--one-file-system") }
+    for _, in := range set.Includes { args = append(args, "--pattern=+pp:"+in) }
+    for _, ex := range set.Excludes {
+        ex = strings.TrimSuffix(ex, "/")
+        if !strings.HasPrefix(ex, "/") { ex = "**/" + ex }
+        args = append(args, "--exclude", "sh:"+ex)
+    }
+    return args
+}
+
+// sameDevice reports whether a and b are on the same filesystem.
+func sameDevice(a, b string) bool {
+    var sa, sb syscall.Stat_t
+    if syscall.Stat(a, &sa) != nil || syscall.Stat(b, &sb) != nil { return true }
+    return sa.Dev == sb.Dev
+}
+
+// readMounts lists /proc/self/mounts, unescaping the octal \040 form.
+func readMounts() ([]mountEntry, error) {
+    f, err := os.Open("/proc/self/mounts")
+    if err != nil { return nil, err }
+    defer f.Close()
+    var out []mountEntry
+    sc := bufio.NewScanner(f)
+    for sc.Scan() {
+        fl := strings.Fields(sc.Text())
+        if len(fl) < 3 { continue }
+        out = append(out, mountEntry{Dir: unescapeMount(fl[1]), Type: fl[2]})
+    }
+    return out, sc.Err()
+}
+
+func unescapeMount(s string) string {
+    var b strings.Builder
+    for i := 0; i < len(s); i++ {
+        if s[i] == '\\' && i+3 < len(s) {
+            if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil { b.WriteByte(byte(n)); i += 3; continue }
+        }
+        b.WriteByte(s[i])
+    }
+    return b.String()
+}
+
+// pseudoFS are kernel filesystems left out of the mount report.
+var pseudoFS = map[string]bool{
+    "proc": true, "sysfs": true, "devtmpfs": true, "devpts": true, "cgroup": true, "cgroup2": true,
+    "securityfs": true, "pstore": true, "bpf": true, "debugfs": true, "tracefs": true, "mqueue": true,
+    "hugetlbfs": true, "configfs": true, "fusectl": true, "binfmt_misc": true, "efivarfs": true,
+    "autofs": true, "rpc_pipefs": true, "nsfs": true,
+}
+
+// reportFileSet writes the effective selection and the fate of every real
+// mount below the sources.
+func reportFileSet(cfg config.Config, set fileSet, rpt io.Writer) {
+    for _, s := range set.Sources {
+        only := ""
+        if set.OneFS { only = " (this filesystem only)" }
+        fmt.Fprintf(rpt, "✓ source %s%s\n", s, only)
+    }
+    for _, n := range set.notes { fmt.Fprintf(rpt, "! %s\n", n) }
+    if len(set.Excludes) > 0 { fmt.Fprintf(rpt, "  excludes: %s\n", strings.Join(set.Excludes, " ")) }
+
+    mounts, err := readMounts()
+    if err != nil { fmt.Fprintf(rpt, "! cannot list mounts: %v\n", err); return }
+    seen := map[string]bool{}
+    for _, m := range mounts {
+        if pseudoFS[m.Type] || seen[m.Dir] || set.hasSource(m.Dir) { continue }
+        src, ok := covering(set.Sources, m.Dir)
+        if !ok { continue }
+        seen[m.Dir] = true
+        switch x, excl := set.excludedBy(m.Dir); {
+        case excl && (containsMount(cfg.ExcludeMounts, m.Dir) || containsMount(cfg.ExcludeMounts, m.Type)):
+            fmt.Fprintf(rpt, "  mount %s (%s): excluded by exclude_mounts\n", m.Dir, m.Type)
+        case excl:
+            fmt.Fprintf(rpt, "  mount %s (%s): excluded by %s\n", m.Dir, m.Type, x)
+        case set.OneFS && !sameDevice(src, m.Dir):
+            fmt.Fprintf(rpt, "  mount %s (%s): skipped, another filesystem (add it to include_mounts to back it up)\n", m.Dir, m.Type)
+        default:
+            fmt.Fprintf(rpt, "  mount %s (%s): included\n", m.Dir, m.Type)
+        }
+    }
+}
+
+func containsMount(list []string, s string) bool {
+    for _, x := range list {
+        if x == s || (strings.HasPrefix(x, "/") && path.Clean(x) == s) { return true }
+    }
+    return false
+}
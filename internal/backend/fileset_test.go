@@ -0,0 +1,111 @@
+// File: internal/backend/fileset_test.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-17
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Tests of the file set: resolving sources, their excludes and the mount
+//   choices, and the rsync and borg arguments for a resolved set.
+
+package backend
+
+import (
+    os "os"
+    path_file "path/filepath"
+    reflect "reflect"
+    strings "strings"
+    testing "testing"
+
+    "cloudcurio.cc/octobackup/internal/config"
+)
+
+func TestBorgArgsIncludeMounts(t *testing.T) {
+    set := fileSet{
+        Sources:  []string{"/", "/mnt/data"},
+        Excludes: []string{"/mnt/*", "*.tmp", "/var/cache/"},
+        Includes: []string{"/mnt/data"},
+        OneFS:    true,
+    }
+    got := strings.Join(set.borgArgs(), " ")
+    // the include is a path prefix and comes first: borg uses the first match
+    want := "--one-file-system --pattern=+pp:/mnt/data --exclude sh:/mnt/* --exclude sh:**/*.tmp --exclude sh:/var/cache"
+    if got != want { t.Errorf("borgArgs =\n  %s\nwant\n  %s", got, want) }
+}
+
+func TestRsyncArgs(t *testing.T) {
+    cases := []struct {
+        set  fileSet
+        want string
+    }{
+        {fileSet{Sources: []string{"/"}, Excludes: []string{"/proc/*"}}, "--exclude=/proc/*"},
+        {fileSet{Sources: []string{"/etc", "/home"}, OneFS: true}, "-x -R"},
+        {fileSet{Sources: []string{"/", "/mnt/data"}, Includes: []string{"/mnt/data"}, Excludes: []string{"/mnt/*"}}, "-R --include=/mnt/data/ --exclude=/mnt/*"},
+    }
+    for _, c := range cases {
+        if got := strings.Join(c.set.rsyncArgs(), " "); got != c.want { t.Errorf("rsyncArgs(%v) = %s, want %s", c.set.Sources, got, c.want) }
+    }
+}
+
+func TestSourceExcludes(t *testing.T) {
+    cases := []struct {
+        src      string
+        patterns []string
+        want     []string
+    }{
+        {"/", []string{"/tmp/*", "*.tmp", "cache/"}, []string{"/tmp/*", "*.tmp", "cache/"}},
+        {"/home", []string{"/me/.cache/"}, []string{"/home/me/.cache/"}},
+        {"/home", []string{"*.tmp"}, []string{"/home/*.tmp", "/home/**/*.tmp"}},
+        {"/home", []string{".cache/"}, []string{"/home/.cache/", "/home/**/.cache/"}},
+        {"/srv/www", []string{"/logs/*", "node_modules"}, []string{"/srv/www/logs/*", "/srv/www/node_modules", "/srv/www/**/node_modules"}},
+        {"/home", nil, nil},
+    }
+    for _, c := range cases {
+        if got := sourceExcludes(c.src, c.patterns); !reflect.DeepEqual(got, c.want) { t.Errorf("sourceExcludes(%s, %q) = %q, want %q", c.src, c.patterns, got, c.want) }
+    }
+}
+
+func TestResolveFileSet(t *testing.T) {
+    root := t.TempDir()
+    for _, d := range []string{"home/me", "etc", "mnt/usb"} {
+        if err := os.MkdirAll(path_file.Join(root, d), 0o755); err != nil { t.Fatal(err) }
+    }
+    home, etc, usb := path_file.Join(root, "home"), path_file.Join(root, "etc"), path_file.Join(root, "mnt/usb")
+
+    set, err := resolveFileSet(config.Config{
+        Excludes:      []string{"/proc/*"},
+        Sources:       []config.Source{{Path: home + "/", Excludes: []string{"*.tmp"}}, {Path: etc}, {Path: home + "/me"}},
+        IncludeMounts: []string{usb},
+        ExcludeMounts: []string{root + "/mnt/nfs/", "no-such-fs"},
+    })
+    if err != nil { t.Fatal(err) }
+    cases := []struct {
+        what      string
+        got, want any
+    }{
+        // home/me is inside home and on the same filesystem, so it is dropped
+        {"sources", set.Sources, []string{home, etc, usb}},
+        {"includes", set.Includes, []string{usb}},
+        {"excludes", set.Excludes, []string{"/proc/*", home + "/*.tmp", home + "/**/*.tmp", root + "/mnt/nfs/*"}},
+        {"notes", set.notes, []string{home + "/me is inside " + home + ", already backed up"}},
+    }
+    for _, c := range cases {
+        if !reflect.DeepEqual(c.got, c.want) { t.Errorf("%s = %q, want %q", c.what, c.got, c.want) }
+    }
+
+    set, err = resolveFileSet(config.Config{})
+    if err != nil { t.Fatal(err) }
+    if !set.isRoot() { t.Errorf("no sources resolve to %q, want /", set.Sources) }
+}
+
+func TestResolveFileSetRefuses(t *testing.T) {
+    root := t.TempDir()
+    cases := map[string]config.Config{
+        "relative source":        {Sources: []config.Source{{Path: "home"}}},
+        "missing source":         {Sources: []config.Source{{Path: root + "/gone"}}},
+        "relative include_mount": {IncludeMounts: []string{"mnt/usb"}},
+        "missing include_mount":  {IncludeMounts: []string{root + "/gone"}},
+        "excluded source":        {Sources: []config.Source{{Path: root}}, ExcludeMounts: []string{root + "/"}},
+    }
+    for name, cfg := range cases {
+        if set, err := resolveFileSet(cfg); err == nil { t.Errorf("%s: resolved to %q", name, set.Sources) }
+    }
+}
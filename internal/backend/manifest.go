@@ -0,0 +1,111 @@
+// File: internal/backend/manifest.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Backup manifests: a small JSON sidecar (<artifact>.manifest.json) written
+//   next to a streamed artifact once it is complete. It records how the
+//   stream was produced so restore can undo it without guessing from the
+//   file name or the current config, and the checksums of the raw and the
+//   stored stream that verify compares against.
+//
+//   Version 1 is a single stream of the source. Version 2 manifests describe
+//   streams a version-1 reader would take for the source and write out as
+//   garbage: chunked images (Chunks), block deltas (Base, BlockSize) and
+//   sparse record streams (Sparse). A manifest of a version this binary does
+//   not know is refused rather than guessed around.
+
+package backend
+
+import (
+    bytes "bytes"
+    context "context"
+    json "encoding/json"
+    errors "errors"
+    fmt "fmt"
+    strings "strings"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/pipeline"
+)
+
+// manifestSuffix is appended to an artifact's remote path.
+const manifestSuffix = ".manifest.json"
+
+// manifestVersion is the newest manifest version this binary reads and
+// writes; it is bumped when fields change what the stream holds.
+const manifestVersion = 2
+
+// Manifest describes one streamed artifact.
+type Manifest struct {
+    Version    int             `json:"version"`
+    Strategy   config.Strategy `json:"strategy"`
+    Name       string          `json:"name"`
+    Created    time.Time       `json:"created"`
+    Source     string          `json:"source,omitempty"`
+    Codec      string          `json:"codec,omitempty"`      // "" = uncompressed
+    Level      int             `json:"level,omitempty"`
+    Encryption string          `json:"encryption,omitempty"` // "" = plaintext
+    Size       int64           `json:"size,omitempty"`       // bytes before compression
+    Checksum   string          `json:"checksum,omitempty"`   // algorithm of the sums; "" = none recorded
+    RawSum     string          `json:"raw_sum,omitempty"`    // of the source stream
+    StoredSum  string          `json:"stored_sum,omitempty"` // of the file as stored on the remote
+    StoredSize int64           `json:"stored_size,omitempty"`
+    ChunkSize  int64           `json:"chunk_size,omitempty"` // chunked dd image: raw bytes per chunk
+    Chunks     int             `json:"chunks,omitempty"`
+    BlockSize  int64           `json:"block_size,omitempty"` // incremental dd: bytes per entry of the .blocks map
+    BlockHash  string          `json:"block_hash,omitempty"` // algorithm of the block map
+    DiskSize   int64           `json:"disk_size,omitempty"`  // incremental dd: bytes of the source disk
+    Base       string          `json:"base,omitempty"`       // incremental dd delta: the backup it applies on top of
+    Depth      int             `json:"depth,omitempty"`      // deltas since the last full image
+    Sparse     string          `json:"sparse,omitempty"`     // sparse dd image: zeros|fs; the stream holds run records
+}
+
+// version is the oldest manifest version that describes m's stream: 1 for a
+// plain image, so older binaries can still restore it.
+func (m Manifest) version() int {
+    if m.ChunkSize != 0 || m.Chunks != 0 || m.BlockSize != 0 || m.Base != "" || m.Sparse != "" { return 2 }
+    return 1
+}
+
+// manifestVersionError is a manifest of a version this binary does not know.
+type manifestVersionError struct {
+    path    string
+    version int
+}
+
+func (e manifestVersionError) Error() string {
+    return fmt.Sprintf("manifest %s: unknown version %d (this octobackup reads up to %d); restore it with a newer octobackup", e.path, e.version, manifestVersion)
+}
+
+// unknownManifest reports whether err is a manifest that must not be
+// guessed around: callers that fall back on file names without one stop.
+func unknownManifest(err error) bool {
+    var v manifestVersionError
+    return errors.As(err, &v)
+}
+
+// isManifest reports whether a remote file name is a manifest sidecar.
+func isManifest(name string) bool { return strings.HasSuffix(name, manifestSuffix) }
+
+// writeManifest stages m next to the artifact at path on cfg's host, as
+// <path>.manifest.json.partial; remoteCommit moves it into place.
+func writeManifest(ctx context.Context, cfg config.Config, path string, m Manifest) error {
+    m.Version = m.version()
+    b, err := json.MarshalIndent(m, "", "  ")
+    if err != nil { return err }
+    err = pipeline.New(remoteWriteStage(cfg, path+manifestSuffix+partialSuffix)).Run(ctx, pipeline.Options{Stdin: bytes.NewReader(append(b, '\n'))})
+    if err != nil { return fmt.Errorf("write manifest: %w", err) }
+    return nil
+}
+
+// readManifest fetches the manifest of the artifact at path.
+func readManifest(ctx context.Context, cfg config.Config, path string) (Manifest, error) {
+    var m Manifest
+    out, err := remoteOutput(ctx, cfg, "cat", path+manifestSuffix)
+    if err != nil { return m, err }
+    if err := json.Unmarshal(out, &m); err != nil { return m, fmt.Errorf("manifest %s: %w", path+manifestSuffix, err) }
+    if m.Version < 1 || m.Version > manifestVersion { return m, manifestVersionError{path + manifestSuffix, m.Version} }
+    return m, nil
+}
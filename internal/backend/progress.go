@@ -0,0 +1,120 @@
+// File: internal/backend/progress.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Real transfer progress. A Meter counts bytes, either from a counting
+//   reader spliced into a stream (dd, zfs/btrfs send) or from byte counts
+//   parsed out of tool output (rsync --info=progress2, borg --log-json), and
+//   reports percent, throughput and ETA against an expected total.
+
+package backend
+
+import (
+    io "io"
+    sync "sync"
+    syscall "syscall"
+    time "time"
+)
+
+// Progress is a snapshot of a running transfer.
+type Progress struct {
+    Bytes int64   // transferred so far
+    Total int64   // expected total; 0 when unknown
+    Rate  float64 // smoothed bytes per second
+}
+
+// Percent is Bytes/Total in [0,1], or 0 when the total is unknown.
+func (p Progress) Percent() float64 {
+    if p.Total <= 0 { return 0 }
+    f := float64(p.Bytes) / float64(p.Total)
+    if f > 1 { f = 1 }
+    return f
+}
+
+// ETA is the remaining time at the current rate, or 0 when unknown.
+func (p Progress) ETA() time.Duration {
+    if p.Total <= 0 || p.Rate <= 0 || p.Bytes >= p.Total { return 0 }
+    return time.Duration(float64(p.Total-p.Bytes) / p.Rate * float64(time.Second)).Round(time.Second)
+}
+
+// meterInterval is how often a Meter samples its rate and reports.
+const meterInterval = 500 * time.Millisecond
+
+// Meter tracks bytes transferred and reports Progress at most every
+// meterInterval. It is safe for concurrent use.
+type Meter struct {
+    mu        sync.Mutex
+    bytes     int64
+    total     int64
+    rate      float64
+    lastBytes int64
+    lastTime  time.Time
+    emit      func(Progress)
+}
+
+// NewMeter returns a Meter reporting to emit (which may be nil).
+func NewMeter(emit func(Progress)) *Meter {
+    return &Meter{lastTime: time.Now(), emit: emit}
+}
+
+// SetTotal sets the expected number of bytes.
+func (m *Meter) SetTotal(n int64) {
+    m.mu.Lock(); m.total = n; m.mu.Unlock()
+}
+
+// Add counts n more bytes.
+func (m *Meter) Add(n int64) {
+    m.mu.Lock(); m.bytes += n; m.tick(false); m.mu.Unlock()
+}
+
+// Set replaces the byte count with an absolute value reported by a tool.
+func (m *Meter) Set(n int64) {
+    m.mu.Lock(); m.bytes = n; m.tick(false); m.mu.Unlock()
+}
+
+// Flush reports the current progress regardless of the interval.
+func (m *Meter) Flush() {
+    m.mu.Lock(); m.tick(true); m.mu.Unlock()
+}
+
+// Progress returns the current snapshot.
+func (m *Meter) Progress() Progress {
+    m.mu.Lock(); defer m.mu.Unlock()
+    return Progress{Bytes: m.bytes, Total: m.total, Rate: m.rate}
+}
+
+// tick updates the smoothed rate and reports; callers hold m.mu.
+func (m *Meter) tick(force bool) {
+    now := time.Now()
+    dt := now.Sub(m.lastTime)
+    if dt < meterInterval && !force { return }
+    if dt > 0 {
+        inst := float64(m.bytes-m.lastBytes) / dt.Seconds()
+        if m.rate == 0 { m.rate = inst } else { m.rate = 0.3*inst + 0.7*m.rate }
+    }
+    m.lastBytes, m.lastTime = m.bytes, now
+    if m.emit != nil { m.emit(Progress{Bytes: m.bytes, Total: m.total, Rate: m.rate}) }
+}
+
+// Reader wraps r so every byte read through it is counted.
+func (m *Meter) Reader(r io.Reader) io.Reader { return &countingReader{r: r, m: m} }
+
+type countingReader struct {
+    r io.Reader
+    m *Meter
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+    n, err := c.r.Read(p)
+    if n > 0 { c.m.Add(int64(n)) }
+    return n, err
+}
+
+// usedBytes is the allocated space on the filesystem holding path, used as a
+// size estimate for whole-filesystem backups.
+func usedBytes(path string) int64 {
+    var st syscall.Statfs_t
+    if err := syscall.Statfs(path, &st); err != nil { return 0 }
+    return int64(st.Blocks-st.Bfree) * int64(st.Bsize)
+}
@@ -0,0 +1,146 @@
+// File: internal/backend/prune.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Pruning: apply cfg.Retention to the backups a strategy lists and delete
+//   the rest. Backends opt in by implementing Deleter; those that also keep
+//   snapshots on this host (zfs, btrfs) implement LocalSnapshotter, and the
+//   same policy is applied to the local set separately. A dry run only
+//   reports what would go.
+
+package backend
+
+import (
+    context "context"
+    fmt "fmt"
+    io "io"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/retention"
+)
+
+// Deleter is implemented by backends whose backups can be deleted one at a
+// time from the remote.
+type Deleter interface {
+    Delete(ctx context.Context, cfg config.Config, a Artifact) error
+}
+
+// LocalSnapshotter is implemented by backends that leave a snapshot on this
+// host after each run.
+type LocalSnapshotter interface {
+    // ListLocal returns this tool's local snapshots, oldest first.
+    ListLocal(ctx context.Context, cfg config.Config) ([]Artifact, error)
+    DeleteLocal(ctx context.Context, cfg config.Config, a Artifact) error
+}
+
+// baser is implemented by backends whose backups can depend on older ones
+// (incremental dd deltas): it maps a backup's name to the one it needs.
+// Prune keeps whatever a kept backup needs, and deletes newest first so a
+// base outlives its deltas.
+type baser interface {
+    bases(ctx context.Context, cfg config.Config) (map[string]string, error)
+}
+
+// compacter is implemented by backends that need a pass after deleting to
+// actually free space (borg compact).
+type compacter interface {
+    compact(ctx context.Context, cfg config.Config) error
+}
+
+// Prune applies cfg.Retention to cfg's backups, remote and local, writing
+// one line per backup to rpt. With dryRun nothing is deleted. Deletions
+// that fail are reported and counted; the rest still run.
+func Prune(ctx context.Context, cfg config.Config, dryRun bool, rpt io.Writer) error {
+    b, err := Get(cfg.Strategy)
+    if err != nil { return err }
+    d, ok := b.(Deleter)
+    if !ok { return fmt.Errorf("%s backups cannot be pruned", cfg.Strategy) }
+    if cfg.Retention.IsZero() { return fmt.Errorf("no retention policy configured (retention: keep_last, keep_daily, …)") }
+    fmt.Fprintf(rpt, "Policy: %s\n", retention.String(cfg.Retention))
+
+    as, err := b.List(ctx, cfg)
+    if err != nil { return err }
+    var bases map[string]string
+    if bs, ok := b.(baser); ok {
+        if bases, err = bs.bases(ctx, cfg); err != nil { return err }
+    }
+    fmt.Fprintf(rpt, "Remote %s backups on %s:\n", cfg.Strategy, cfg.RemoteHost)
+    deleted, failed, err := pruneSet(ctx, cfg, as, bases, dryRun, rpt, d.Delete)
+    if err != nil { return err }
+
+    if ls, ok := b.(LocalSnapshotter); ok {
+        local, err := ls.ListLocal(ctx, cfg)
+        if err != nil { return fmt.Errorf("local snapshots: %w", err) }
+        fmt.Fprintf(rpt, "Local snapshots:\n")
+        ld, lf, err := pruneSet(ctx, cfg, local, nil, dryRun, rpt, ls.DeleteLocal)
+        if err != nil { return err }
+        deleted, failed = deleted+ld, failed+lf
+    }
+
+    if c, ok := b.(compacter); ok && deleted > 0 && !dryRun {
+        fmt.Fprintf(rpt, "Compacting…\n")
+        if err := c.compact(ctx, cfg); err != nil { fmt.Fprintf(rpt, "✗ compact: %v\n", err); failed++ }
+    }
+    if failed > 0 { return fmt.Errorf("%d prune step(s) failed", failed) }
+    return nil
+}
+
+// pruneSet decides over one listing, each Group on its own, keeps the
+// bases of kept backups, and deletes what the policy drops.
+func pruneSet(ctx context.Context, cfg config.Config, as []Artifact, bases map[string]string, dryRun bool, rpt io.Writer, del func(context.Context, config.Config, Artifact) error) (deleted, failed int, err error) {
+    if len(as) == 0 { fmt.Fprintf(rpt, "  (none)\n"); return 0, 0, nil }
+    groups := map[string][]int{}
+    for i, a := range as { groups[a.Group] = append(groups[a.Group], i) }
+    ds := make([]retention.Decision, len(as))
+    for _, idx := range groups {
+        times := make([]time.Time, len(idx))
+        for k, i := range idx { times[k] = as[i].Time }
+        gd, err := retention.Apply(cfg.Retention, times)
+        if err != nil { return 0, 0, err }
+        for k, i := range idx { ds[i] = gd[k] }
+    }
+    order := make([]int, len(as))
+    for i := range as { order[i] = i }
+    if bases != nil {
+        byName := map[string]int{}
+        for i, a := range as { byName[a.Name] = i }
+        for i := len(as) - 1; i >= 0; i-- {
+            if !ds[i].Keep { continue }
+            for b, ok := bases[as[i].Name]; ok && b != ""; b, ok = bases[b] {
+                j, found := byName[b]
+                if !found { break }
+                if !ds[j].Keep { ds[j] = retention.Decision{Keep: true, Reason: "base of " + as[i].Name} }
+            }
+        }
+        for k := range order { order[k] = len(as) - 1 - k }
+    }
+
+    for _, i := range order {
+        a := as[i]
+        when := a.Time.Local().Format("2006-01-02 15:04")
+        switch {
+        case ds[i].Keep:
+            fmt.Fprintf(rpt, "  keep         %s  %s  (%s)\n", when, a.Name, ds[i].Reason)
+        case dryRun:
+            fmt.Fprintf(rpt, "  would prune  %s  %s\n", when, a.Name)
+        default:
+            if ctx.Err() != nil { return deleted, failed, ctx.Err() }
+            if derr := del(ctx, cfg, a); derr != nil {
+                failed++
+                fmt.Fprintf(rpt, "✗ prune        %s  %s: %v\n", when, a.Name, derr)
+                continue
+            }
+            deleted++
+            fmt.Fprintf(rpt, "  pruned       %s  %s\n", when, a.Name)
+        }
+    }
+    return deleted, failed, nil
+}
+
+// deleteRemoteFile removes a file artifact and its manifest.
+func deleteRemoteFile(ctx context.Context, cfg config.Config, a Artifact) error {
+    _, err := remoteOutput(ctx, cfg, "rm", "-f", "--", a.Path, a.Path+manifestSuffix)
+    return err
+}
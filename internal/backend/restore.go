@@ -0,0 +1,71 @@
+// File: internal/backend/restore.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Shared restore helpers: picking a backup from the remote listing and the
+//   local target safety checks used by each backend's RestorePreflight.
+
+package backend
+
+import (
+    context "context"
+    fmt "fmt"
+    io "io"
+    os "os"
+    os_exec "os/exec"
+    path_file "path/filepath"
+    strings "strings"
+
+    "cloudcurio.cc/octobackup/internal/config"
+)
+
+// Resolve finds a backup by name in the remote listing for cfg. An empty
+// name or "latest" selects the newest one.
+func Resolve(ctx context.Context, cfg config.Config, name string) (Artifact, error) {
+    b, err := Get(cfg.Strategy)
+    if err != nil { return Artifact{}, err }
+    as, err := b.List(ctx, cfg)
+    if err != nil { return Artifact{}, err }
+    if len(as) == 0 { return Artifact{}, fmt.Errorf("no %s backups found on %s", cfg.Strategy, cfg.RemoteHost) }
+    if name == "" || name == "latest" { return as[len(as)-1], nil }
+    for _, a := range as {
+        if a.Name == name || a.Path == name { return a, nil }
+    }
+    return Artifact{}, fmt.Errorf("backup %q not found", name)
+}
+
+// checkDiskTarget verifies a raw restore target: a block device (or an image
+// file) that is not mounted anywhere, including via its partitions.
+func checkDiskTarget(ctx context.Context, target string, rpt io.Writer) bool {
+    if target == "" { fmt.Fprintf(rpt, "✗ target disk not set\n"); return false }
+    fi, err := os.Stat(target)
+    if err != nil { fmt.Fprintf(rpt, "✗ target: %v\n", err); return false }
+    if fi.Mode()&os.ModeDevice == 0 {
+        if fi.Mode().IsRegular() { fmt.Fprintf(rpt, "• %s is a regular file; writing an image file\n", target); return true }
+        fmt.Fprintf(rpt, "✗ %s is not a block device\n", target); return false
+    }
+    if !Have("lsblk") { fmt.Fprintf(rpt, "✗ need lsblk to check %s is unmounted\n", target); return false }
+    out, err := os_exec.CommandContext(ctx, "lsblk", "-nro", "NAME,MOUNTPOINT", target).Output()
+    if err != nil { fmt.Fprintf(rpt, "✗ lsblk %s: %v\n", target, err); return false }
+    ok := true
+    for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+        if f := strings.Fields(line); len(f) > 1 { ok = false; fmt.Fprintf(rpt, "✗ %s is mounted on %s\n", f[0], f[1]) }
+    }
+    if ok { fmt.Fprintf(rpt, "✓ %s is not mounted\n", target); fmt.Fprintf(rpt, "⚠ everything on %s will be overwritten\n", target) }
+    return ok
+}
+
+// checkDirTarget verifies a file-level restore target: an existing directory
+// other than the running system's root.
+func checkDirTarget(target string, rpt io.Writer) bool {
+    if target == "" { fmt.Fprintf(rpt, "✗ target directory not set\n"); return false }
+    abs, err := path_file.Abs(target)
+    if err != nil { fmt.Fprintf(rpt, "✗ target: %v\n", err); return false }
+    if abs == "/" { fmt.Fprintf(rpt, "✗ refusing to restore over the running root; mount the target elsewhere\n"); return false }
+    fi, err := os.Stat(abs)
+    if err != nil { fmt.Fprintf(rpt, "✗ target: %v\n", err); return false }
+    if !fi.IsDir() { fmt.Fprintf(rpt, "✗ %s is not a directory\n", abs); return false }
+    fmt.Fprintf(rpt, "✓ target %s\n", abs)
+    return true
+}
@@ -0,0 +1,247 @@
+// File: internal/backend/rsync.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   File-level backup of the sources (fileset.go; default /) with rsync over
+//   ssh, in one of two modes:
+//     • mirror     — one directory under the remote path, updated in place
+//                    with --delete-after. A marker file is removed before
+//                    each run and rewritten only after rsync succeeded; a
+//                    mirror without it is listed as incomplete.
+//     • snapshots  — each run writes a dated directory (2006-01-02T150405)
+//                    hard-linked against the previous one with --link-dest,
+//                    so unchanged files cost no space. It is written as
+//                    <date>.partial, renamed when complete, and the latest
+//                    symlink is then swapped to it atomically. An
+//                    interrupted run's .partial is reused by the next.
+//   With rsync_index each backup also gets a per-file index (rsyncindex.go).
+//   Restore is the same transfer in reverse. Progress is parsed from
+//   --info=progress2 output.
+
+package backend
+
+import (
+    context "context"
+    fmt "fmt"
+    io "io"
+    strconv "strconv"
+    strings "strings"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/pipeline"
+)
+
+type rsyncBackend struct{}
+
+func init() { Register(rsyncBackend{}) }
+
+func (rsyncBackend) Strategy() config.Strategy { return config.StratRsync }
+func (rsyncBackend) Describe() string          { return "Rsync file-level (fast/smart)" }
+
+func (rsyncBackend) RequiredTools(config.Config) []string { return []string{"ssh", "rsync"} }
+
+const (
+    rsyncMirror    = "mirror"
+    rsyncSnapshots = "snapshots"
+)
+
+// rsyncMode is cfg's mode, defaulting to mirror.
+func rsyncMode(cfg config.Config) (string, error) {
+    switch cfg.RsyncMode {
+    case "", rsyncMirror:
+        return rsyncMirror, nil
+    case rsyncSnapshots:
+        return rsyncSnapshots, nil
+    }
+    return "", fmt.Errorf("unknown rsync_mode %q (want mirror or snapshots)", cfg.RsyncMode)
+}
+
+// rsyncLatest is the symlink naming the newest complete snapshot.
+const rsyncLatest = "latest"
+
+// rsyncSnapTime is the layout of snapshot directory names.
+const rsyncSnapTime = "2006-01-02T150405"
+
+// Preflight refuses snapshots into a remote path holding a mirror: a later
+// mirror run there would delete the snapshots.
+func (rsyncBackend) Preflight(ctx context.Context, cfg config.Config, rpt io.Writer) bool {
+    mode, err := rsyncMode(cfg)
+    if err != nil { fmt.Fprintf(rpt, "✗ %v\n", err); return false }
+    set, err := resolveFileSet(cfg)
+    if err != nil { fmt.Fprintf(rpt, "✗ %v\n", err); return false }
+    reportFileSet(cfg, set, rpt)
+    if cfg.RsyncIndex {
+        alg, err := indexAlg(cfg)
+        if err != nil { fmt.Fprintf(rpt, "✗ %v\n", err); return false }
+        fmt.Fprintf(rpt, "✓ per-file %s index written with each backup (reads changed files once more)\n", alg)
+    }
+    if mode == rsyncMirror { return true }
+    if _, err := remoteOutput(ctx, cfg, "test", "-e", RemoteDir(cfg)+"/"+rsyncMarker); err == nil {
+        fmt.Fprintf(rpt, "✗ %s holds a mirror; use another remote_path for snapshots\n", RemoteDir(cfg)); return false
+    }
+    fmt.Fprintf(rpt, "✓ dated snapshots in %s, hard-linked to %s\n", RemoteDir(cfg), rsyncLatest)
+    return true
+}
+
+// rsyncShell is the -e argument carrying the ssh port.
+func rsyncShell(cfg config.Config) string { return fmt.Sprintf("ssh -p %d", cfg.SSHPort) }
+
+// rsyncArgs is the rsync argv up to, not including, the destination.
+func rsyncArgs(cfg config.Config, set fileSet, extra ...string) []string {
+    rsArgs := []string{"rsync", "-aAXHz", "--numeric-ids", "--delete-after", "--info=progress2", "--no-inc-recursive"}
+    if cfg.BandwidthKbps > 0 { rsArgs = append(rsArgs, fmt.Sprintf("--bwlimit=%d", cfg.BandwidthKbps)) }
+    rsArgs = append(rsArgs, set.rsyncArgs()...)
+    // excluded, so --delete-after leaves the marker and index alone
+    rsArgs = append(rsArgs, "--exclude=/"+rsyncMarker, "--exclude=/"+rsyncIndexFile)
+    rsArgs = append(append(rsArgs, extra...), "-e", rsyncShell(cfg))
+    if set.isRoot() { return append(rsArgs, "/") }
+    return append(rsArgs, set.Sources...)
+}
+
+func (b rsyncBackend) Plan(cfg config.Config) (Plan, error) { return b.plan(cfg, Sink{}) }
+
+// plan is Plan with the sink the index build reports to.
+func (rsyncBackend) plan(cfg config.Config, sink Sink) (Plan, error) {
+    mode, err := rsyncMode(cfg)
+    if err != nil { return Plan{}, err }
+    set, err := resolveFileSet(cfg)
+    if err != nil { return Plan{}, err }
+    if mode == rsyncSnapshots { return rsyncSnapshotPlan(cfg, set, time.Now(), sink), nil }
+    dir := RemoteDir(cfg)
+    rsArgs := append(rsyncArgs(cfg, set), fmt.Sprintf("%s:%s/", SSHDest(cfg), dir))
+    marker := dir + "/" + rsyncMarker
+    return Plan{
+        Prepare: []pipeline.Stage{sshStage(cfg, "rm", "-f", marker)},
+        Stream:  pipeline.New(pipeline.Cmd(rsArgs...)),
+        Filter:  rsyncProgress,
+        Finish: func(ctx context.Context, _ Progress) error {
+            if cfg.RsyncIndex {
+                index := dir + "/" + rsyncIndexFile
+                if err := writeRsyncIndex(ctx, cfg, set, dir, index+partialSuffix, sink); err != nil { return err }
+                if _, err := remoteOutput(ctx, cfg, "mv", "-f", index+partialSuffix, index); err != nil { return err }
+            }
+            _, err := remoteOutput(ctx, cfg, "sh", "-c", `date -u +%Y-%m-%dT%H:%M:%SZ > "$1" && { sync "$1" 2>/dev/null || sync; }`, "sh", marker)
+            return err
+        },
+        Artifact: dir,
+    }, nil
+}
+
+// rsyncReuseScript renames the first leftover *.partial directory in $1 to
+// $2, so an interrupted run's transfer is not repeated.
+const rsyncReuseScript = `for p in "$1"/*.partial; do
+    [ -d "$p" ] && [ "$p" != "$2" ] && mv "$p" "$2"
+    break
+done`
+
+// rsyncLinkScript points $1/latest at $2 by renaming a fresh symlink over it.
+const rsyncLinkScript = `ln -sfn "$2" "$1/.latest.tmp" && mv -T "$1/.latest.tmp" "$1/latest" && { sync "$1" 2>/dev/null || sync; }`
+
+// rsyncSnapshotPlan writes a run into <dir>/<date>.partial, hard-linking
+// unchanged files against latest, then commits it and moves latest. A
+// failed run leaves its .partial for the next one to reuse.
+func rsyncSnapshotPlan(cfg config.Config, set fileSet, now time.Time, sink Sink) Plan {
+    dir := RemoteDir(cfg)
+    name := now.Format(rsyncSnapTime)
+    partial := dir + "/" + name + partialSuffix
+    // a missing latest (first run) only makes rsync warn and copy everything
+    rsArgs := append(rsyncArgs(cfg, set, "--link-dest="+dir+"/"+rsyncLatest), fmt.Sprintf("%s:%s/", SSHDest(cfg), partial))
+    return Plan{
+        Prepare: []pipeline.Stage{
+            sshStage(cfg, "mkdir", "-p", dir),
+            sshStage(cfg, "sh", "-c", rsyncReuseScript, "sh", dir, partial),
+        },
+        Stream: pipeline.New(pipeline.Cmd(rsArgs...)),
+        Filter: rsyncProgress,
+        Finish: func(ctx context.Context, _ Progress) error {
+            if cfg.RsyncIndex {
+                if err := writeRsyncIndex(ctx, cfg, set, dir+"/"+rsyncLatest, partial+"/"+rsyncIndexFile, sink); err != nil { return err }
+            }
+            if err := remoteCommit(ctx, cfg, partial, dir+"/"+name); err != nil { return err }
+            if _, err := remoteOutput(ctx, cfg, "sh", "-c", rsyncLinkScript, "sh", dir, name); err != nil { return fmt.Errorf("update %s: %w", rsyncLatest, err) }
+            return nil
+        },
+        Artifact: dir + "/" + name,
+    }
+}
+
+// rsyncMarker is written into the mirror root after a complete run.
+const rsyncMarker = ".octobackup-complete"
+
+// rsyncIncomplete is the artifact name of a mirror whose last run did not finish.
+const rsyncIncomplete = "mirror (incomplete)"
+
+// rsyncProgress parses --info=progress2 lines such as
+//   "  1,234,567  45%   12.34MB/s    0:01:23 (xfr#5, to-chk=100/200)"
+// into the meter; the total is derived from the byte count and percent.
+func rsyncProgress(line string, m *Meter) (string, bool) {
+    f := strings.Fields(line)
+    if len(f) < 4 || !strings.HasSuffix(f[1], "%") { return line, true }
+    n, err := strconv.ParseInt(strings.ReplaceAll(f[0], ",", ""), 10, 64)
+    if err != nil { return line, true }
+    pct, err := strconv.Atoi(strings.TrimSuffix(f[1], "%"))
+    if err != nil { return line, true }
+    if pct > 0 { m.SetTotal(n * 100 / int64(pct)) }
+    m.Set(n)
+    return "", false
+}
+
+func (b rsyncBackend) Run(ctx context.Context, cfg config.Config, sink Sink) error {
+    p, err := b.plan(cfg, sink)
+    if err != nil { return err }
+    return Execute(ctx, p, sink)
+}
+
+func (rsyncBackend) RestorePreflight(_ context.Context, _ config.Config, opts RestoreOptions, rpt io.Writer) bool {
+    if opts.Artifact.Name == rsyncIncomplete { fmt.Fprintf(rpt, "! the mirror's last backup did not complete; files may be a mix of two runs\n") }
+    return checkDirTarget(opts.Target, rpt)
+}
+
+func (rsyncBackend) Restore(ctx context.Context, cfg config.Config, opts RestoreOptions, sink Sink) error {
+    if opts.Target == "" { return fmt.Errorf("restore target directory not set") }
+    src := fmt.Sprintf("%s:%s/", SSHDest(cfg), opts.Artifact.Path)
+    // a snapshot holds no marker, the exclude is harmless there
+    argv := []string{"rsync", "-aAXH", "--numeric-ids", "--info=progress2", "--no-inc-recursive", "--exclude=/" + rsyncMarker, "--exclude=/" + rsyncIndexFile, "-e", rsyncShell(cfg), src, opts.Target + "/"}
+    return Execute(ctx, Plan{Stream: pipeline.New(pipeline.Cmd(argv...)), Filter: rsyncProgress}, sink)
+}
+
+// List reports the single mirror directory, timed by its completion marker
+// (or the directory itself when the last run did not complete), or the
+// complete snapshot directories, timed by their names.
+func (rsyncBackend) List(ctx context.Context, cfg config.Config) ([]Artifact, error) {
+    dir := RemoteDir(cfg)
+    if mode, err := rsyncMode(cfg); err != nil {
+        return nil, err
+    } else if mode == rsyncSnapshots {
+        as, err := listRemoteEntries(ctx, cfg, dir, "????-??-??T??????")
+        if err != nil { return nil, err }
+        var out []Artifact
+        for _, a := range as {
+            t, err := time.ParseInLocation(rsyncSnapTime, a.Name, time.Local)
+            if err != nil { continue }
+            a.Time, a.Size = t, 0 // size of the directory inode says nothing
+            out = append(out, a)
+        }
+        return sortArtifacts(out), nil
+    }
+    a := Artifact{Name: "mirror", Path: dir}
+    out, err := remoteOutput(ctx, cfg, "stat", "-c", "%Y", dir+"/"+rsyncMarker)
+    if err != nil {
+        a.Name = rsyncIncomplete
+        if out, err = remoteOutput(ctx, cfg, "stat", "-c", "%Y", dir); err != nil { return nil, err }
+    }
+    var secs int64
+    fmt.Sscanf(string(out), "%d", &secs)
+    a.Time = time.Unix(secs, 0)
+    return []Artifact{a}, nil
+}
+
+// Delete removes a snapshot directory. The mirror itself is updated in
+// place and never deleted; retention always keeps the newest backup anyway.
+func (rsyncBackend) Delete(ctx context.Context, cfg config.Config, a Artifact) error {
+    if a.Path == RemoteDir(cfg) || a.Path == "" { return fmt.Errorf("refusing to delete the mirror root %s", a.Path) }
+    _, err := remoteOutput(ctx, cfg, "rm", "-rf", "--", a.Path)
+    return err
+}
@@ -0,0 +1,210 @@
+// File: internal/backend/rsyncindex.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Per-file indexes of rsync backups (rsync_index: true). After rsync
+//   succeeded, the source is walked with the same excludes and each regular
+//   file's path, size, mtime, mode and hash go into .octobackup-index.gz in
+//   the snapshot (or mirror) root; files unchanged since the previous index
+//   are not re-read. Scrub re-hashes the remote copy on the backup host and
+//   reports files whose contents changed while size and mtime did not —
+//   silent corruption. FileHistory reads every snapshot's index to show when
+//   a file last changed.
+
+package backend
+
+import (
+    bytes "bytes"
+    context "context"
+    fmt "fmt"
+    io "io"
+    strconv "strconv"
+    strings "strings"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/checksum"
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/fileindex"
+    "cloudcurio.cc/octobackup/internal/pipeline"
+)
+
+// rsyncIndexFile is the index in a snapshot or mirror root. rsync excludes
+// it, so a mirror's --delete-after keeps it and snapshots do not link it.
+const rsyncIndexFile = ".octobackup-index.gz"
+
+// indexAlg is the hash of file and block indexes: the configured
+// checksum, or sha256 when checksums are off for streams, since an index
+// without hashes is useless.
+func indexAlg(cfg config.Config) (string, error) {
+    alg, err := checksum.Lookup(cfg.Checksum)
+    if alg == "" { alg = checksum.SHA256 }
+    return alg, err
+}
+
+// readRsyncIndex fetches the index in the backup directory dir.
+func readRsyncIndex(ctx context.Context, cfg config.Config, dir string) (fileindex.Index, error) {
+    out, err := remoteOutput(ctx, cfg, "cat", dir+"/"+rsyncIndexFile)
+    if err != nil { return fileindex.Index{}, fmt.Errorf("%s has no file index: %w", dir, err) }
+    return fileindex.Read(bytes.NewReader(out))
+}
+
+// writeRsyncIndex indexes the file set and writes the index to path on the
+// remote, reusing hashes from the index in prevDir when there is one.
+func writeRsyncIndex(ctx context.Context, cfg config.Config, set fileSet, prevDir, path string, sink Sink) error {
+    alg, err := indexAlg(cfg)
+    if err != nil { return err }
+    prev, err := readRsyncIndex(ctx, cfg, prevDir)
+    if err != nil { sink.info("No previous file index; hashing every file") }
+    sink.info("Indexing %s (%s)", strings.Join(set.Sources, " "), alg)
+    start := time.Now()
+    x, st, err := fileindex.Build(ctx, set.Sources, set.Excludes, set.OneFS, alg, prev)
+    if err != nil { return fmt.Errorf("index: %w", err) }
+    sink.info("Indexed %d files in %s: %d hashed (%d MiB), %d unchanged, %d unreadable",
+        st.Files, time.Since(start).Round(time.Second), st.Hashed, st.Bytes>>20, st.Reused, st.Skipped)
+    var buf bytes.Buffer
+    if err := fileindex.Write(&buf, x); err != nil { return err }
+    err = pipeline.New(remoteWriteStage(cfg, path)).Run(ctx, pipeline.Options{Stdin: &buf})
+    if err != nil { return fmt.Errorf("write index: %w", err) }
+    return nil
+}
+
+// --------------------------- SCRUB ---------------------------
+
+// ScrubReport counts what Scrub found.
+type ScrubReport struct {
+    Files     int // in the index
+    OK        int
+    Corrupt   int // same size and mtime, different contents
+    Modified  int // size or mtime differs: changed while it was copied, or by hand
+    Missing   int
+    Unindexed int // on the remote, not in the index
+}
+
+// Clean reports whether nothing was corrupt or missing.
+func (r ScrubReport) Clean() bool { return r.Corrupt == 0 && r.Missing == 0 }
+
+func (r ScrubReport) String() string {
+    return fmt.Sprintf("%d files: %d ok, %d corrupt, %d modified, %d missing, %d not indexed", r.Files, r.OK, r.Corrupt, r.Modified, r.Missing, r.Unindexed)
+}
+
+// rsyncScrubFind lists the regular files under $1 as size, mtime and path,
+// NUL-terminated, leaving out OctoBackup's own files.
+const rsyncScrubFind = `cd "$1" && find . -type f ! -path ./` + rsyncIndexFile + ` ! -path ./` + rsyncMarker + ` -printf '%s\t%T@\t%p\0'`
+
+// rsyncScrubHash hashes the same files with $2 (sha256sum or b3sum).
+const rsyncScrubHash = `cd "$1" && find . -type f ! -path ./` + rsyncIndexFile + ` ! -path ./` + rsyncMarker + ` -print0 | xargs -0 -r "$2"`
+
+// Scrub re-hashes the rsync backup a on the backup host and compares every
+// file with a's index, writing one line per problem to rpt.
+func Scrub(ctx context.Context, cfg config.Config, a Artifact, rpt io.Writer) (ScrubReport, error) {
+    var r ScrubReport
+    if cfg.Strategy != config.StratRsync { return r, fmt.Errorf("scrub needs rsync backups with rsync_index; %s backups have verify", cfg.Strategy) }
+    x, err := readRsyncIndex(ctx, cfg, a.Path)
+    if err != nil { return r, err }
+    tool := checksum.RemoteTool(x.Alg)
+    if _, err := remoteOutput(ctx, cfg, "sh", "-c", `command -v "$1"`, "sh", tool); err != nil { return r, fmt.Errorf("%s is not installed on %s", tool, cfg.RemoteHost) }
+
+    out, err := remoteOutput(ctx, cfg, "sh", "-c", rsyncScrubFind, "sh", a.Path)
+    if err != nil { return r, err }
+    type stat struct{ size, mtime int64 }
+    remote := map[string]stat{}
+    for _, rec := range strings.Split(string(out), "\x00") {
+        f := strings.SplitN(rec, "\t", 3)
+        if len(f) != 3 { continue }
+        var s stat
+        s.size, _ = strconv.ParseInt(f[0], 10, 64)
+        secs, _ := strconv.ParseFloat(f[1], 64)
+        s.mtime = int64(secs)
+        remote[strings.TrimPrefix(f[2], ".")] = s
+    }
+
+    fmt.Fprintf(rpt, "Hashing %d files in %s on %s with %s\n", len(remote), a.Path, cfg.RemoteHost, tool)
+    out, err = remoteOutput(ctx, cfg, "sh", "-c", rsyncScrubHash, "sh", a.Path, tool)
+    if err != nil { return r, err }
+    sums := map[string]string{}
+    for _, line := range strings.Split(string(out), "\n") {
+        if p, sum, ok := parseSumLine(line); ok { sums[strings.TrimPrefix(p, ".")] = sum }
+    }
+
+    seen := map[string]bool{}
+    for _, e := range x.Entries {
+        r.Files++
+        seen[e.Path] = true
+        s, ok := remote[e.Path]
+        switch {
+        case !ok:
+            r.Missing++
+            fmt.Fprintf(rpt, "✗ missing   %s\n", e.Path)
+        case s.size != e.Size || s.mtime != e.MTime:
+            r.Modified++
+            fmt.Fprintf(rpt, "! modified  %s (size %d→%d, mtime %s→%s)\n", e.Path, e.Size, s.size,
+                time.Unix(e.MTime, 0).Format(time.DateTime), time.Unix(s.mtime, 0).Format(time.DateTime))
+        case sums[e.Path] != e.Sum:
+            r.Corrupt++
+            fmt.Fprintf(rpt, "✗ corrupt   %s (%s %s, index has %s)\n", e.Path, x.Alg, shortSum(sums[e.Path]), shortSum(e.Sum))
+        default:
+            r.OK++
+        }
+    }
+    for p := range remote { if !seen[p] { r.Unindexed++ } }
+    return r, nil
+}
+
+// parseSumLine splits a sha256sum/b3sum output line into path and sum. A
+// leading backslash marks a name with \n or \\ escapes in it.
+func parseSumLine(line string) (string, string, bool) {
+    escaped := strings.HasPrefix(line, `\`)
+    if escaped { line = line[1:] }
+    sum, p, ok := strings.Cut(line, "  ")
+    if !ok { return "", "", false }
+    if escaped { p = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(p) }
+    return p, sum, true
+}
+
+func shortSum(s string) string {
+    if s == "" { return "(none)" }
+    if len(s) > 12 { return s[:12] }
+    return s
+}
+
+// --------------------------- FILE HISTORY ---------------------------
+
+// FileVersion is a file as one backup's index recorded it; a zero Entry
+// means the backup did not have the file.
+type FileVersion struct {
+    Backup  Artifact
+    Entry   fileindex.Entry
+    Present bool
+    Changed bool // differs from the previous backup that had an index
+}
+
+// FileHistory looks file up in the index of every rsync backup, oldest
+// first. Backups without an index are left out.
+func FileHistory(ctx context.Context, cfg config.Config, file string) ([]FileVersion, error) {
+    if cfg.Strategy != config.StratRsync { return nil, fmt.Errorf("file history needs rsync backups with rsync_index") }
+    as, err := rsyncBackend{}.List(ctx, cfg)
+    if err != nil { return nil, err }
+    var (
+        vs   []FileVersion
+        prev *FileVersion
+    )
+    for _, a := range as {
+        x, err := readRsyncIndex(ctx, cfg, a.Path)
+        if err != nil { continue }
+        v := FileVersion{Backup: a}
+        v.Entry, v.Present = x.Map()[file]
+        switch {
+        case prev == nil:
+            v.Changed = v.Present
+        case v.Present != prev.Present:
+            v.Changed = true
+        default:
+            v.Changed = v.Present && (v.Entry.Sum != prev.Entry.Sum || v.Entry.Mode != prev.Entry.Mode)
+        }
+        vs = append(vs, v)
+        prev = &vs[len(vs)-1]
+    }
+    if len(vs) == 0 { return nil, fmt.Errorf("no backup in %s has a file index", RemoteDir(cfg)) }
+    return vs, nil
+}
@@ -0,0 +1,345 @@
+// File: internal/backend/zfs.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   ZFS snapshot replication: zfs snapshot <dataset>@YYYYMMDD-HHMMSS, then
+//   zfs send | ssh zfs recv -s into the remote dataset. cfg.SourceDisk holds
+//   the local dataset (pool/root) and cfg.RemotePath the receiving dataset.
+//
+//   Each run looks up the newest snapshot both sides share (same name and
+//   guid) and sends incrementally from it with -I; only a remote without the
+//   dataset gets a full stream. An interrupted receive leaves a resume token
+//   on the remote; the next run finishes that transfer with zfs send -t
+//   before taking a new snapshot. A resume that fails (ssh dropped) keeps
+//   the token for the run after; only a token zfs send rejects, because its
+//   snapshot is gone, is discarded with zfs recv -A. Progress counts stream
+//   bytes against the zfs send -nvP estimate. A received snapshot only
+//   appears once zfs recv completed, so listed snapshots need no separate
+//   completion marker.
+//
+//   With encryption on, the remote cannot receive the stream; it is stored
+//   instead as a file <dataset>@<snap>.zfs[.zst].age under cfg.RemotePath
+//   (then a directory) and received locally on restore.
+
+package backend
+
+import (
+    context "context"
+    fmt "fmt"
+    io "io"
+    os_exec "os/exec"
+    strings "strings"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/config"
+    "cloudcurio.cc/octobackup/internal/pipeline"
+)
+
+type zfsBackend struct{}
+
+func init() { Register(zfsBackend{}) }
+
+func (zfsBackend) Strategy() config.Strategy { return config.StratZFS }
+func (zfsBackend) Describe() string          { return "ZFS snapshot send/recv" }
+
+func (zfsBackend) RequiredTools(config.Config) []string { return []string{"ssh", "zfs"} }
+
+func (zfsBackend) Preflight(ctx context.Context, cfg config.Config, rpt io.Writer) bool {
+    if cfg.SourceDisk == "" { fmt.Fprintf(rpt, "✗ source dataset not set (source disk, e.g. pool/root)\n"); return false }
+    if encrypted(cfg) {
+        e, err := checkEncode(cfg)
+        if err != nil { fmt.Fprintf(rpt, "✗ %v\n", err); return false }
+        fmt.Fprintf(rpt, "✓ stream stored as a file, %s (full sends)\n", e)
+        return true
+    }
+    st, err := zfsDiscover(ctx, cfg)
+    if err != nil { fmt.Fprintf(rpt, "✗ %v\n", err); return false }
+    if st.token != "" { fmt.Fprintf(rpt, "• an interrupted receive into %s will be resumed first\n", RemoteDir(cfg)) }
+    switch {
+    case st.base != "":
+        fmt.Fprintf(rpt, "✓ incremental send from @%s\n", st.base)
+    case st.remoteExists && st.token == "":
+        fmt.Fprintf(rpt, "✗ %s exists but shares no snapshot with %s; rename or destroy it for a full send\n", RemoteDir(cfg), cfg.SourceDisk)
+        return false
+    default:
+        fmt.Fprintf(rpt, "• no common snapshot; full send into %s\n", RemoteDir(cfg))
+    }
+    return true
+}
+
+// zfsStreamFile reports whether an artifact is a stored send stream rather
+// than a received snapshot.
+func zfsStreamFile(a Artifact) bool { return strings.Contains(a.Name, ".zfs") }
+
+// Plan resolves the next run against the current local and remote
+// snapshots; Run does the same with its own context.
+func (zfsBackend) Plan(cfg config.Config) (Plan, error) { return zfsPlan(context.Background(), cfg) }
+
+func zfsPlan(ctx context.Context, cfg config.Config) (Plan, error) {
+    if cfg.SourceDisk == "" { return Plan{}, fmt.Errorf("source dataset not set") }
+    // seconds keep the name unique, so Cleanup only ever destroys this run's snapshot
+    snap := fmt.Sprintf("%s@%s", cfg.SourceDisk, time.Now().Format(zfsSnapTime))
+    if encrypted(cfg) {
+        // stored streams stay full: each file restores on its own
+        base := strings.ReplaceAll(snap, "/", "_") + ".zfs"
+        p, err := encodedFilePlan(cfg, base, pipeline.Cmd("zfs", "send", snap), &Manifest{Strategy: config.StratZFS, Created: time.Now().UTC(), Source: snap})
+        if err != nil { return Plan{}, err }
+        p.Prepare = append([]pipeline.Stage{pipeline.Cmd("zfs", "snapshot", snap)}, p.Prepare...)
+        p.Total = func(ctx context.Context) int64 { return zfsSendSize(ctx, pipeline.Cmd("zfs", "send", "-nvP", snap)) }
+        p.Cleanup = append(p.Cleanup, pipeline.Cmd("zfs", "destroy", snap))
+        return p, nil
+    }
+
+    st, err := zfsDiscover(ctx, cfg)
+    if err != nil { return Plan{}, err }
+    send := []string{"zfs", "send"}
+    recv := []string{"zfs", "recv", "-s"}
+    switch {
+    case st.base != "":
+        // -F rolls the remote back to the base if it was touched (atime, mounts)
+        send = append(send, "-I", "@"+st.base)
+        recv = append(recv, "-F")
+    case st.remoteExists:
+        return Plan{}, fmt.Errorf("%s exists but shares no snapshot with %s; rename or destroy it for a full send", RemoteDir(cfg), cfg.SourceDisk)
+    }
+    send = append(send, snap)
+    recv = append(recv, RemoteDir(cfg))
+    dry := append([]string{"zfs", "send", "-nvP"}, send[2:]...)
+    return Plan{
+        Prepare: []pipeline.Stage{pipeline.Cmd("zfs", "snapshot", snap)},
+        Stream:  pipeline.New(pipeline.Cmd(send...), sshStage(cfg, recv...)),
+        Meter:   1,
+        Total:   func(ctx context.Context) int64 { return zfsSendSize(ctx, pipeline.Cmd(dry...)) },
+        // no Cleanup: the snapshot stays so the resume token left by an
+        // interrupted zfs recv -s stays usable; prune removes it otherwise
+        Artifact: RemoteDir(cfg) + snap[strings.IndexByte(snap, '@'):],
+    }, nil
+}
+
+// zfsResumePlan finishes an interrupted receive from its resume token.
+func zfsResumePlan(cfg config.Config, token string) Plan {
+    return Plan{
+        Stream: pipeline.New(pipeline.Cmd("zfs", "send", "-t", token), sshStage(cfg, "zfs", "recv", "-s", RemoteDir(cfg))),
+        Meter:  1,
+        Total:  func(ctx context.Context) int64 { return zfsSendSize(ctx, pipeline.Cmd("zfs", "send", "-nvP", "-t", token)) },
+    }
+}
+
+// zfsCheckToken asks zfs send whether it can still resume from token; it
+// refuses a token whose snapshot was destroyed.
+func zfsCheckToken(ctx context.Context, token string) error {
+    out, err := pipeline.Cmd("zfs", "send", "-nvP", "-t", token).Command(ctx).CombinedOutput()
+    if err != nil { return fmt.Errorf("zfs send -t: %v: %s", err, strings.TrimSpace(string(out))) }
+    return nil
+}
+
+// zfsState is what the remote dataset holds relative to the source.
+type zfsState struct {
+    remoteExists bool
+    token        string // receive_resume_token, "" = none
+    base         string // newest snapshot name both sides share, "" = none
+}
+
+// zfsSnap is one snapshot's short name and guid; equal guids are the same
+// snapshot whatever the dataset.
+type zfsSnap struct{ name, guid string }
+
+// zfsDiscover looks up the remote dataset's resume token and the newest
+// snapshot the source and remote have in common.
+func zfsDiscover(ctx context.Context, cfg config.Config) (zfsState, error) {
+    var st zfsState
+    remote := RemoteDir(cfg)
+    out, err := remoteOutput(ctx, cfg, "zfs", "list", "-H", "-o", "name,receive_resume_token", remote)
+    if err != nil {
+        if strings.Contains(err.Error(), "does not exist") { return st, nil }
+        return st, fmt.Errorf("remote dataset %s: %w", remote, err)
+    }
+    st.remoteExists = true
+    if f := strings.Split(strings.TrimSpace(string(out)), "\t"); len(f) == 2 && f[1] != "-" { st.token = f[1] }
+
+    rout, err := remoteOutput(ctx, cfg, zfsListGUIDs(remote)...)
+    if err != nil { return st, err }
+    lout, err := pipeline.Cmd(zfsListGUIDs(cfg.SourceDisk)...).Command(ctx).Output()
+    if err != nil { return st, fmt.Errorf("zfs list %s: %w", cfg.SourceDisk, err) }
+    have := map[zfsSnap]bool{}
+    for _, s := range parseZFSGUIDs(rout) { have[s] = true }
+    for _, s := range parseZFSGUIDs(lout) {
+        // oldest first, so the last match is the newest common snapshot
+        if have[s] { st.base = s.name }
+    }
+    return st, nil
+}
+
+// zfsListGUIDs is the zfs list argv for dataset's snapshots, name and guid,
+// oldest first.
+func zfsListGUIDs(dataset string) []string {
+    return []string{"zfs", "list", "-H", "-t", "snapshot", "-o", "name,guid", "-s", "createtxg", "-d", "1", dataset}
+}
+
+func parseZFSGUIDs(out []byte) []zfsSnap {
+    var ss []zfsSnap
+    for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+        f := strings.Split(line, "\t")
+        i := strings.IndexByte(f[0], '@')
+        if len(f) != 2 || i < 0 { continue }
+        ss = append(ss, zfsSnap{name: f[0][i+1:], guid: f[1]})
+    }
+    return ss
+}
+
+// zfsSendSize runs a dry-run "zfs send -nvP …" and returns its size estimate
+// (the "size\t<bytes>" line), or 0.
+func zfsSendSize(ctx context.Context, c pipeline.Stage) int64 {
+    out, err := c.Command(ctx).CombinedOutput()
+    if err != nil { return 0 }
+    var n int64
+    for _, line := range strings.Split(string(out), "\n") {
+        if f := strings.Fields(line); len(f) == 2 && f[0] == "size" { fmt.Sscanf(f[1], "%d", &n) }
+    }
+    return n
+}
+
+// Run first completes an interrupted receive, if the remote holds one, so
+// the new snapshot can be sent incrementally on top of it. A stale token
+// would block every later receive, so it is discarded and the run goes on.
+func (zfsBackend) Run(ctx context.Context, cfg config.Config, sink Sink) error {
+    if cfg.SourceDisk == "" { return fmt.Errorf("source dataset not set") }
+    if !encrypted(cfg) {
+        st, err := zfsDiscover(ctx, cfg)
+        if err != nil { return err }
+        if st.token != "" {
+            if err := zfsCheckToken(ctx, st.token); err != nil {
+                sink.info("The interrupted receive cannot be resumed (%v); discarding it: %s", err, sshStage(cfg, "zfs", "recv", "-A", RemoteDir(cfg)))
+                if _, err := remoteOutput(ctx, cfg, "zfs", "recv", "-A", RemoteDir(cfg)); err != nil { return fmt.Errorf("discard partial receive: %w", err) }
+            } else {
+                sink.info("Resuming interrupted receive into %s", RemoteDir(cfg))
+                if err := Execute(ctx, zfsResumePlan(cfg, st.token), sink); err != nil { return fmt.Errorf("resume (the partial receive is kept for the next run): %w", err) }
+            }
+        }
+    }
+    p, err := zfsPlan(ctx, cfg)
+    if err != nil { return err }
+    return Execute(ctx, p, sink)
+}
+
+// RestorePreflight requires a new target dataset under an existing parent;
+// a full stream cannot be received over an existing dataset without -F.
+func (zfsBackend) RestorePreflight(ctx context.Context, cfg config.Config, opts RestoreOptions, rpt io.Writer) bool {
+    if opts.Target == "" { fmt.Fprintf(rpt, "✗ target dataset not set\n"); return false }
+    if os_exec.CommandContext(ctx, "zfs", "list", "-H", opts.Target).Run() == nil {
+        fmt.Fprintf(rpt, "✗ dataset %s already exists\n", opts.Target); return false
+    }
+    if i := strings.LastIndexByte(opts.Target, '/'); i > 0 {
+        if err := os_exec.CommandContext(ctx, "zfs", "list", "-H", opts.Target[:i]).Run(); err != nil {
+            fmt.Fprintf(rpt, "✗ parent dataset %s not found\n", opts.Target[:i]); return false
+        }
+    }
+    fmt.Fprintf(rpt, "✓ will receive into new dataset %s\n", opts.Target)
+    if zfsStreamFile(opts.Artifact) { return checkDecode(ctx, cfg, opts.Artifact, false, rpt) }
+    return true
+}
+
+// Restore sends a remote snapshot back into a local dataset.
+func (zfsBackend) Restore(ctx context.Context, cfg config.Config, opts RestoreOptions, sink Sink) error {
+    if opts.Target == "" { return fmt.Errorf("restore target dataset not set") }
+    if zfsStreamFile(opts.Artifact) {
+        stream, err := decodedFileStream(ctx, cfg, opts.Artifact, false, pipeline.Cmd("zfs", "recv", opts.Target))
+        if err != nil { return err }
+        size := opts.Artifact.Size
+        return Execute(ctx, Plan{Stream: stream, Meter: 1, Total: func(context.Context) int64 { return size }}, sink)
+    }
+    snap := opts.Artifact.Path
+    return Execute(ctx, Plan{
+        Stream: pipeline.New(sshStage(cfg, "zfs", "send", snap), pipeline.Cmd("zfs", "recv", opts.Target)),
+        Meter:  1,
+        Total:  func(ctx context.Context) int64 { return zfsSendSize(ctx, sshStage(cfg, "zfs", "send", "-nvP", snap)) },
+    }, sink)
+}
+
+func (zfsBackend) List(ctx context.Context, cfg config.Config) ([]Artifact, error) {
+    if encrypted(cfg) { return listRemoteEntries(ctx, cfg, RemoteDir(cfg), "*.zfs*") }
+    out, err := remoteOutput(ctx, cfg, zfsListSnapshots(RemoteDir(cfg))...)
+    if err != nil { return nil, err }
+    return parseZFSSnapshots(out), nil
+}
+
+// zfsSnapTime is the layout of the snapshot names Plan creates.
+const zfsSnapTime = "20060102-150405"
+
+// zfsListSnapshots is the zfs list argv for dataset's own snapshots:
+// name, used bytes and creation time, tab-separated.
+func zfsListSnapshots(dataset string) []string {
+    return []string{"zfs", "list", "-H", "-p", "-t", "snapshot", "-o", "name,used,creation", "-s", "createtxg", "-d", "1", dataset}
+}
+
+func parseZFSSnapshots(out []byte) []Artifact {
+    var as []Artifact
+    for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+        f := strings.Split(line, "\t")
+        if len(f) != 3 { continue }
+        a := Artifact{Path: f[0]}
+        if i := strings.IndexByte(f[0], '@'); i >= 0 { a.Name = f[0][i+1:] }
+        var secs int64
+        fmt.Sscanf(f[1], "%d", &a.Size)
+        fmt.Sscanf(f[2], "%d", &secs)
+        a.Time = time.Unix(secs, 0)
+        as = append(as, a)
+    }
+    return sortArtifacts(as)
+}
+
+// zfsDestroySnapshot guards zfs destroy: without an @ the name is a whole
+// dataset.
+func zfsDestroySnapshot(snap string) ([]string, error) {
+    if !strings.Contains(snap, "@") { return nil, fmt.Errorf("refusing to destroy %s: not a snapshot", snap) }
+    return []string{"zfs", "destroy", snap}, nil
+}
+
+// Verify checks a stored stream; received snapshots are checksummed by
+// ZFS itself and checked by zpool scrub on the backup host.
+func (zfsBackend) Verify(ctx context.Context, cfg config.Config, a Artifact, opts VerifyOptions, sink Sink) error {
+    if !zfsStreamFile(a) { return fmt.Errorf("%s is a received snapshot; run zpool scrub on %s to check it", a.Path, cfg.RemoteHost) }
+    return verifyStream(ctx, cfg, a, false, opts, sink)
+}
+
+// Delete destroys a received snapshot, or removes a stored stream file.
+func (zfsBackend) Delete(ctx context.Context, cfg config.Config, a Artifact) error {
+    if zfsStreamFile(a) { return deleteRemoteFile(ctx, cfg, a) }
+    argv, err := zfsDestroySnapshot(a.Path)
+    if err != nil { return err }
+    _, err = remoteOutput(ctx, cfg, argv...)
+    return err
+}
+
+// ListLocal returns the snapshots of the source dataset that Plan created;
+// snapshots made by hand or other tools are left alone. When replicating,
+// only snapshots older than the newest common one are listed: that one is
+// the next incremental base and newer ones have not reached the remote.
+func (zfsBackend) ListLocal(ctx context.Context, cfg config.Config) ([]Artifact, error) {
+    if cfg.SourceDisk == "" { return nil, nil }
+    base := ""
+    if !encrypted(cfg) {
+        st, err := zfsDiscover(ctx, cfg)
+        if err != nil { return nil, err }
+        if st.base == "" { return nil, nil }
+        base = st.base
+    }
+    out, err := pipeline.Cmd(zfsListSnapshots(cfg.SourceDisk)...).Command(ctx).Output()
+    if err != nil { return nil, fmt.Errorf("zfs list %s: %w", cfg.SourceDisk, err) }
+    var ours []Artifact
+    for _, a := range parseZFSSnapshots(out) {
+        if a.Name == base { break }
+        if _, err := time.Parse(zfsSnapTime, a.Name); err == nil { ours = append(ours, a) }
+    }
+    return ours, nil
+}
+
+func (zfsBackend) DeleteLocal(ctx context.Context, _ config.Config, a Artifact) error {
+    argv, err := zfsDestroySnapshot(a.Path)
+    if err != nil { return err }
+    if out, err := pipeline.Cmd(argv...).Command(ctx).CombinedOutput(); err != nil {
+        return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+    }
+    return nil
+}
@@ -0,0 +1,183 @@
+// File: internal/catalog/catalog.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   The local run catalog: every backup run, headless, scheduled or from the
+//   TUI, is recorded in catalog.jsonl in the state dir (~/.local/state/
+//   cloudcurio) with its job, strategy, start and end, bytes sent, the
+//   artifacts it produced (remote path, repo::archive or dataset@snap), exit
+//   status and the path of its full log under logs/.
+//
+//   The file is append-only JSON lines: a run is written once when it starts
+//   and again when it ends, and the last line of an ID wins. A run whose
+//   process died without an end record reads back as interrupted.
+
+package catalog
+
+import (
+    bufio "bufio"
+    json "encoding/json"
+    errors "errors"
+    fmt "fmt"
+    os "os"
+    path_file "path/filepath"
+    sort "sort"
+    strings "strings"
+    sync "sync"
+    syscall "syscall"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/config"
+)
+
+// Status is how a run ended.
+type Status string
+
+const (
+    Running     Status = "running"
+    OK          Status = "ok"
+    Failed      Status = "failed"
+    Aborted     Status = "aborted"     // cancelled or interrupted by a signal
+    Interrupted Status = "interrupted" // the process died mid-run
+)
+
+// Record is one run.
+type Record struct {
+    ID        string          `json:"id"`
+    Job       string          `json:"job"`
+    Strategy  config.Strategy `json:"strategy"`
+    Remote    string          `json:"remote"` // user@host
+    Start     time.Time       `json:"start"`
+    End       time.Time       `json:"end"` // zero while running
+    Bytes     int64           `json:"bytes"`
+    Artifacts []string        `json:"artifacts,omitempty"`
+    Status    Status          `json:"status"`
+    Error     string          `json:"error,omitempty"`
+    Log       string          `json:"log"`
+    PID       int             `json:"pid"`
+}
+
+// Duration is the run time so far, or in total once ended.
+func (r Record) Duration() time.Duration {
+    if r.End.IsZero() { return time.Since(r.Start).Round(time.Second) }
+    return r.End.Sub(r.Start).Round(time.Second)
+}
+
+// Path is the catalog file.
+func Path() string { return path_file.Join(config.StateDir(), "catalog.jsonl") }
+
+// LogDir holds one log file per run.
+func LogDir() string { return path_file.Join(config.StateDir(), "logs") }
+
+// Run is a run being recorded. Its methods are safe for concurrent use, as
+// a backend's stdout and stderr arrive from different goroutines.
+type Run struct {
+    mu  sync.Mutex
+    rec Record
+    log *os.File
+}
+
+// Begin records the start of a backup of cfg as job and opens its log.
+func Begin(job string, cfg config.Config) (*Run, error) {
+    now := time.Now()
+    id := fmt.Sprintf("%s-%s-%d", now.Format("20060102-150405"), job, os.Getpid())
+    if err := os.MkdirAll(LogDir(), 0o700); err != nil { return nil, err }
+    logPath := path_file.Join(LogDir(), id+".log")
+    f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+    if err != nil { return nil, err }
+    r := &Run{log: f, rec: Record{
+        ID:       id,
+        Job:      job,
+        Strategy: cfg.Strategy,
+        Remote:   cfg.RemoteUser + "@" + cfg.RemoteHost,
+        Start:    now,
+        Status:   Running,
+        Log:      logPath,
+        PID:      os.Getpid(),
+    }}
+    if err := appendRecord(r.rec); err != nil { f.Close(); return nil, err }
+    return r, nil
+}
+
+// Line appends one line to the run's log.
+func (r *Run) Line(line string) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    fmt.Fprintf(r.log, "%s %s\n", time.Now().Format(time.TimeOnly), strings.TrimRight(line, "\r\n"))
+}
+
+// Bytes notes the bytes transferred so far.
+func (r *Run) Bytes(n int64) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if n > r.rec.Bytes { r.rec.Bytes = n }
+}
+
+// Artifact notes a backup the run completed.
+func (r *Run) Artifact(path string) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.rec.Artifacts = append(r.rec.Artifacts, path)
+}
+
+// Finish records how the run ended and closes its log.
+func (r *Run) Finish(status Status, err error) (Record, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.rec.End, r.rec.Status = time.Now(), status
+    if err != nil {
+        r.rec.Error = err.Error()
+        fmt.Fprintf(r.log, "%s %s: %v\n", r.rec.End.Format(time.TimeOnly), status, err)
+    } else {
+        fmt.Fprintf(r.log, "%s %s\n", r.rec.End.Format(time.TimeOnly), status)
+    }
+    r.log.Close()
+    return r.rec, appendRecord(r.rec)
+}
+
+// appendRecord writes rec as one line; O_APPEND keeps concurrent runs'
+// lines whole.
+func appendRecord(rec Record) error {
+    b, err := json.Marshal(rec)
+    if err != nil { return err }
+    f, err := os.OpenFile(Path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+    if err != nil { return err }
+    _, err = f.Write(append(b, '\n'))
+    if cerr := f.Close(); err == nil { err = cerr }
+    return err
+}
+
+// List returns every recorded run, oldest first. Unreadable lines (a crash
+// mid-write) are skipped.
+func List() ([]Record, error) {
+    f, err := os.Open(Path())
+    if errors.Is(err, os.ErrNotExist) { return nil, nil }
+    if err != nil { return nil, err }
+    defer f.Close()
+
+    byID := map[string]Record{}
+    sc := bufio.NewScanner(f)
+    sc.Buffer(make([]byte, 64*1024), 1024*1024)
+    for sc.Scan() {
+        var rec Record
+        if json.Unmarshal(sc.Bytes(), &rec) != nil || rec.ID == "" { continue }
+        byID[rec.ID] = rec
+    }
+    if err := sc.Err(); err != nil { return nil, err }
+
+    out := make([]Record, 0, len(byID))
+    for _, rec := range byID {
+        if rec.Status == Running && !alive(rec.PID) { rec.Status = Interrupted }
+        out = append(out, rec)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+    return out, nil
+}
+
+// alive reports whether a process with pid exists.
+func alive(pid int) bool {
+    if pid <= 0 { return false }
+    err := syscall.Kill(pid, 0)
+    return err == nil || errors.Is(err, syscall.EPERM)
+}
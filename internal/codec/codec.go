@@ -0,0 +1,103 @@
+// File: internal/codec/codec.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   In-process compression codecs (zstd, lz4, xz, gzip) as pipeline stages,
+//   so images no longer depend on pigz/gzip being installed. Each codec has a
+//   file extension; the codec actually used is also recorded in the backup's
+//   manifest, which is what restore trusts first.
+
+package codec
+
+import (
+    fmt "fmt"
+    io "io"
+    runtime "runtime"
+    sort "sort"
+    strings "strings"
+
+    "cloudcurio.cc/octobackup/internal/pipeline"
+)
+
+// Options tune a codec. Zero values pick the codec's defaults.
+type Options struct {
+    Level   int // codec-specific scale, e.g. zstd 1–19, gzip/xz 1–9
+    Threads int // worker goroutines where the codec supports them; 0 = all CPUs
+}
+
+func (o Options) threads() int {
+    if o.Threads > 0 { return o.Threads }
+    return runtime.NumCPU()
+}
+
+// Codec is one compression format.
+type Codec interface {
+    // Name is the config and manifest value, e.g. "zstd".
+    Name() string
+    // Ext is the file extension including the dot, e.g. ".zst".
+    Ext() string
+    NewWriter(w io.Writer, o Options) (io.WriteCloser, error)
+    NewReader(r io.Reader, o Options) (io.ReadCloser, error)
+}
+
+var registry = map[string]Codec{}
+
+func register(c Codec) { registry[c.Name()] = c }
+
+// aliases keeps configs written for the external tools working.
+var aliases = map[string]string{"pigz": "gzip", "gz": "gzip", "zst": "zstd", "lzma": "xz"}
+
+// Lookup returns the codec for a config value. "" and "none" return nil, nil:
+// no compression.
+func Lookup(name string) (Codec, error) {
+    name = strings.ToLower(strings.TrimSpace(name))
+    if name == "" || name == "none" { return nil, nil }
+    if a, ok := aliases[name]; ok { name = a }
+    c, ok := registry[name]
+    if !ok { return nil, fmt.Errorf("unknown compression %q (want %s or none)", name, strings.Join(Names(), ", ")) }
+    return c, nil
+}
+
+// ForFile guesses the codec from a file name's extension.
+func ForFile(name string) (Codec, bool) {
+    for _, c := range registry {
+        if strings.HasSuffix(name, c.Ext()) { return c, true }
+    }
+    return nil, false
+}
+
+// Names lists the registered codecs, sorted.
+func Names() []string {
+    out := make([]string, 0, len(registry))
+    for n := range registry { out = append(out, n) }
+    sort.Strings(out)
+    return out
+}
+
+// Ext is c's extension, or "" for no compression.
+func Ext(c Codec) string {
+    if c == nil { return "" }
+    return c.Ext()
+}
+
+// CompressStage is a pipeline stage compressing its input with c.
+func CompressStage(c Codec, o Options) pipeline.Stage {
+    return pipeline.Func(c.Name(), func(dst io.Writer, src io.Reader) error {
+        w, err := c.NewWriter(dst, o)
+        if err != nil { return err }
+        if _, err := io.Copy(w, src); err != nil { w.Close(); return err }
+        return w.Close()
+    })
+}
+
+// DecompressStage is a pipeline stage decompressing its input with c.
+func DecompressStage(c Codec, o Options) pipeline.Stage {
+    return pipeline.Func("un"+c.Name(), func(dst io.Writer, src io.Reader) error {
+        r, err := c.NewReader(src, o)
+        if err != nil { return err }
+        defer r.Close()
+        _, err = io.Copy(dst, r)
+        return err
+    })
+}
@@ -0,0 +1,115 @@
+// File: internal/codec/formats.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   The registered codecs. zstd and lz4 compress on Threads goroutines;
+//   gzip and xz are single-stream. Levels outside a codec's range are clamped.
+
+package codec
+
+import (
+    io "io"
+
+    "github.com/klauspost/compress/gzip"
+    "github.com/klauspost/compress/zstd"
+    "github.com/pierrec/lz4/v4"
+    "github.com/ulikunitz/xz"
+)
+
+func init() {
+    register(zstdCodec{})
+    register(lz4Codec{})
+    register(xzCodec{})
+    register(gzipCodec{})
+}
+
+func clamp(v, lo, hi int) int {
+    if v < lo { return lo }
+    if v > hi { return hi }
+    return v
+}
+
+// --------------------------- ZSTD ---------------------------
+
+type zstdCodec struct{}
+
+func (zstdCodec) Name() string { return "zstd" }
+func (zstdCodec) Ext() string  { return ".zst" }
+
+func (zstdCodec) NewWriter(w io.Writer, o Options) (io.WriteCloser, error) {
+    level := zstd.SpeedDefault
+    if o.Level > 0 { level = zstd.EncoderLevelFromZstd(clamp(o.Level, 1, 22)) }
+    return zstd.NewWriter(w, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(o.threads()))
+}
+
+func (zstdCodec) NewReader(r io.Reader, o Options) (io.ReadCloser, error) {
+    d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(o.threads()))
+    if err != nil { return nil, err }
+    return d.IOReadCloser(), nil
+}
+
+// --------------------------- LZ4 ---------------------------
+
+type lz4Codec struct{}
+
+func (lz4Codec) Name() string { return "lz4" }
+func (lz4Codec) Ext() string  { return ".lz4" }
+
+var lz4Levels = []lz4.CompressionLevel{
+    lz4.Level1, lz4.Level2, lz4.Level3, lz4.Level4, lz4.Level5,
+    lz4.Level6, lz4.Level7, lz4.Level8, lz4.Level9,
+}
+
+func (lz4Codec) NewWriter(w io.Writer, o Options) (io.WriteCloser, error) {
+    zw := lz4.NewWriter(w)
+    level := lz4.Fast
+    if o.Level > 0 { level = lz4Levels[clamp(o.Level, 1, 9)-1] }
+    if err := zw.Apply(lz4.CompressionLevelOption(level), lz4.ConcurrencyOption(o.threads())); err != nil { return nil, err }
+    return zw, nil
+}
+
+func (lz4Codec) NewReader(r io.Reader, o Options) (io.ReadCloser, error) {
+    zr := lz4.NewReader(r)
+    if err := zr.Apply(lz4.ConcurrencyOption(o.threads())); err != nil { return nil, err }
+    return io.NopCloser(zr), nil
+}
+
+// --------------------------- XZ ---------------------------
+
+type xzCodec struct{}
+
+func (xzCodec) Name() string { return "xz" }
+func (xzCodec) Ext() string  { return ".xz" }
+
+// xzDictCap follows the xz(1) presets: level n uses the -n dictionary size.
+var xzDictCap = []int{256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+
+func (xzCodec) NewWriter(w io.Writer, o Options) (io.WriteCloser, error) {
+    level := 6
+    if o.Level > 0 { level = clamp(o.Level, 0, 9) }
+    return xz.WriterConfig{DictCap: xzDictCap[level]}.NewWriter(w)
+}
+
+func (xzCodec) NewReader(r io.Reader, _ Options) (io.ReadCloser, error) {
+    zr, err := xz.NewReader(r)
+    if err != nil { return nil, err }
+    return io.NopCloser(zr), nil
+}
+
+// --------------------------- GZIP ---------------------------
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string { return "gzip" }
+func (gzipCodec) Ext() string  { return ".gz" }
+
+func (gzipCodec) NewWriter(w io.Writer, o Options) (io.WriteCloser, error) {
+    level := gzip.DefaultCompression
+    if o.Level > 0 { level = clamp(o.Level, gzip.BestSpeed, gzip.BestCompression) }
+    return gzip.NewWriterLevel(w, level)
+}
+
+func (gzipCodec) NewReader(r io.Reader, _ Options) (io.ReadCloser, error) {
+    return gzip.NewReader(r)
+}
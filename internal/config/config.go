@@ -0,0 +1,155 @@
+// File: internal/config/config.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   OctoBackup configuration: the Strategy names, the Config of one backup
+//   job, defaults and paths. ~/.config/cloudcurio/octobackup.yaml holds
+//   named jobs over shared defaults (jobs.go). Shared by the TUI, the
+//   headless CLI and the strategy backends.
+//
+// Security:
+//   • Never stores secrets; passphrases are referenced by env var name only.
+
+package config
+
+import (
+    fmt "fmt"
+    os "os"
+    path_file "path/filepath"
+    strconv "strconv"
+    strings "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+type Strategy string
+
+const (
+    StratDD    Strategy = "raw-dd"
+    StratRsync Strategy = "rsync"
+    StratBorg  Strategy = "borg"
+    StratZFS   Strategy = "zfs-send"
+    StratBtrfs Strategy = "btrfs-send"
+)
+
+type Config struct {
+    RemoteUser       string    `yaml:"remote_user"`
+    RemoteHost       string    `yaml:"remote_host"`
+    SSHPort          int       `yaml:"ssh_port"`
+    RemotePath       string    `yaml:"remote_path"`
+    Strategy         Strategy  `yaml:"strategy"`
+    SourceDisk       string    `yaml:"source_disk"`                   // for dd/zfs roots; empty for rsync/borg
+    BtrfsSnapshotDir string    `yaml:"btrfs_snapshot_dir,omitempty"`  // local, on the same filesystem; default /.octobackup-snapshots
+    BtrfsSubvolumes  []string  `yaml:"btrfs_subvolumes,omitempty"`    // mount paths or subvolume names (@, @home); default /
+    Compression      string    `yaml:"compression"`                   // zstd|lz4|xz|gzip|none (pigz = gzip)
+    CompressLevel    int       `yaml:"compression_level,omitempty"`   // 0 = codec default
+    CompressThreads  int       `yaml:"compression_threads,omitempty"` // 0 = all CPUs
+    BandwidthKbps    int       `yaml:"bandwidth_kbps"`                // 0 = unlimited
+    Excludes         []string  `yaml:"excludes"`                      // rsync/borg, rsync-style patterns from /
+    Sources          []Source  `yaml:"sources,omitempty"`             // rsync/borg: directories to back up, with their own excludes; default /
+    OneFileSystem    bool      `yaml:"one_file_system,omitempty"`     // rsync/borg: do not descend into other mounts below a source
+    IncludeMounts    []string  `yaml:"include_mounts,omitempty"`      // rsync/borg: mount points backed up even with one_file_system
+    ExcludeMounts    []string  `yaml:"exclude_mounts,omitempty"`      // rsync/borg: mount points, or filesystem types (nfs, cifs), never backed up
+    RsyncMode        string    `yaml:"rsync_mode,omitempty"`          // mirror|snapshots; default mirror
+    RsyncIndex       bool      `yaml:"rsync_index,omitempty"`         // per-file checksum index with each rsync backup, for scrub
+    BorgRepo         string    `yaml:"borg_repo"`                     // ssh://user@host:/path/repo
+    BorgPassEnv      string    `yaml:"borg_pass_env"`                 // env var name holding passphrase
+    Encryption       string    `yaml:"encryption,omitempty"`          // age|age-passphrase|aes-gcm|none; dd, zfs, btrfs
+    Recipients       []string  `yaml:"encrypt_recipients,omitempty"`  // age public keys (age1…), not secrets
+    EncryptSecretEnv string    `yaml:"encrypt_secret_env,omitempty"`  // env var name: passphrase, key file path or age identity
+    Checksum         string    `yaml:"checksum,omitempty"`            // sha256|blake3|none for images and streams; default sha256
+    DDChunkSize      string    `yaml:"dd_chunk_size,omitempty"`       // raw-dd: resumable image in chunks of this size, e.g. 4G; "" = one file
+    DDIncremental    bool      `yaml:"dd_incremental,omitempty"`      // raw-dd: after a full image, send only the blocks that changed
+    DDBlockSize      string    `yaml:"dd_block_size,omitempty"`       // raw-dd incremental: block map granularity; default 1M
+    DDFullEvery      int       `yaml:"dd_full_every,omitempty"`       // raw-dd incremental: a new full image after this many deltas; default 7
+    DDSparse         string    `yaml:"dd_sparse,omitempty"`           // raw-dd: zeros = zero blocks as runs; fs = also skip free space (ext2/3/4, xfs)
+    Retention        Retention `yaml:"retention,omitempty"`
+    Schedule         string    `yaml:"schedule,omitempty"`            // daemon: cron "0 3 * * *", "daily" or calendar "Mon..Fri 02:30"
+    ScheduleJitter   string    `yaml:"schedule_jitter,omitempty"`     // daemon: random delay up to this, e.g. 10m
+    OnFailure        string    `yaml:"on_failure,omitempty"`          // timer units: shell command run with the failed unit as $1
+}
+
+// Source is a directory a file-level backup reads. Its excludes are
+// rsync-style patterns relative to Path: /cache is Path/cache, *.tmp matches
+// anywhere below it. In YAML a source without excludes may be a plain path.
+type Source struct {
+    Path     string   `yaml:"path"`
+    Excludes []string `yaml:"excludes,omitempty"`
+}
+
+func (s *Source) UnmarshalYAML(n *yaml.Node) error {
+    if n.Kind == yaml.ScalarNode { s.Path, s.Excludes = n.Value, nil; return nil }
+    type plain Source
+    return n.Decode((*plain)(s))
+}
+
+func (s Source) MarshalYAML() (any, error) {
+    if len(s.Excludes) == 0 { return s.Path, nil }
+    type plain Source
+    return plain(s), nil
+}
+
+// Retention is how many backups prune keeps, borg-style: the newest Last,
+// plus the newest backup of each of the last Daily days, Weekly ISO weeks,
+// Monthly months and Yearly years. Zero disables a rule.
+type Retention struct {
+    KeepLast    int `yaml:"keep_last,omitempty"`
+    KeepDaily   int `yaml:"keep_daily,omitempty"`
+    KeepWeekly  int `yaml:"keep_weekly,omitempty"`
+    KeepMonthly int `yaml:"keep_monthly,omitempty"`
+    KeepYearly  int `yaml:"keep_yearly,omitempty"`
+}
+
+// IsZero reports whether no rule is set; prune refuses to run then.
+func (r Retention) IsZero() bool { return r == Retention{} }
+
+// ParseSize reads a byte count such as 4G, 512M, 1GiB or 1048576. The
+// suffixes are binary (K = 1024).
+func ParseSize(s string) (int64, error) {
+    t := strings.ToUpper(strings.TrimSpace(s))
+    t = strings.TrimSuffix(strings.TrimSuffix(t, "B"), "I")
+    shift := 0
+    if n := len(t); n > 0 {
+        if i := strings.IndexByte("KMGT", t[n-1]); i >= 0 { shift, t = 10*(i+1), t[:n-1] }
+    }
+    n, err := strconv.ParseInt(t, 10, 64)
+    if err != nil || n < 0 { return 0, fmt.Errorf("bad size %q (want e.g. 4G or 512M)", s) }
+    return n << shift, nil
+}
+
+func Default() Config {
+    return Config{
+        RemoteUser:    "cbwinslow",
+        RemoteHost:    "cbwdellr720.cloudcurio.cc",
+        SSHPort:       22,
+        RemotePath:    "/backups/$(hostname)",
+        Strategy:      StratRsync,
+        Compression:   "zstd",
+        BandwidthKbps: 0,
+        Excludes: []string{
+            "/dev/*", "/proc/*", "/sys/*", "/tmp/*", "/run/*", "/mnt/*", "/media/*", "/lost+found",
+        },
+        BorgRepo:       "ssh://cbwinslow@cbwdellr720.cloudcurio.cc:/backups/borg/$(hostname)",
+        BorgPassEnv:    "BORG_PASSPHRASE",
+        ScheduleJitter: "5m",
+    }
+}
+
+// Path returns ~/.config/cloudcurio/octobackup.yaml, creating the directory.
+func Path() string {
+    cfgDir := path_file.Join(os.Getenv("HOME"), ".config", "cloudcurio")
+    _ = os.MkdirAll(cfgDir, 0o700)
+    return path_file.Join(cfgDir, "octobackup.yaml")
+}
+
+// StateDir returns $XDG_STATE_HOME/cloudcurio (~/.local/state/cloudcurio),
+// creating it. It holds run state: locks, the daemon's last runs, the
+// run catalog and its logs.
+func StateDir() string {
+    base := os.Getenv("XDG_STATE_HOME")
+    if base == "" { base = path_file.Join(os.Getenv("HOME"), ".local", "state") }
+    dir := path_file.Join(base, "cloudcurio")
+    _ = os.MkdirAll(dir, 0o700)
+    return dir
+}
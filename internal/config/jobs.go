@@ -0,0 +1,249 @@
+// File: internal/config/jobs.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-17
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   The config file as a list of named jobs sharing defaults:
+//
+//     defaults:
+//       remote_user: backup
+//       remote_host: nas.lan
+//     jobs:
+//       - name: root
+//         strategy: rsync
+//         schedule: daily
+//       - name: disk
+//         strategy: raw-dd
+//         source_disk: /dev/sda
+//         retention: {keep_weekly: 4}
+//
+//   A job is its defaults with the job's own keys decoded on top, so it sets
+//   only what differs; nested keys merge (a job's retention.keep_weekly keeps
+//   the default keep_daily). A file from before jobs (the Config keys at the
+//   top level) reads as those keys for defaults and one job, "default"; it is
+//   rewritten as a jobs list the first time it is saved, keeping a .bak copy.
+//   On save each job is written as its differences from the defaults.
+
+package config
+
+import (
+    fmt "fmt"
+    os "os"
+    reflect "reflect"
+    strings "strings"
+
+    "gopkg.in/yaml.v3"
+)
+
+// DefaultJob names the single job of a config written before jobs.
+const DefaultJob = "default"
+
+// Job is a named backup job: the defaults with its own settings applied.
+type Job struct {
+    Name string
+    Config
+}
+
+// File is a config file: shared defaults and the jobs.
+type File struct {
+    Defaults Config
+    Jobs     []Job
+    Migrated bool // read from a single-job file
+}
+
+// fileYAML is the on-disk form; jobs stay nodes until decoded onto defaults.
+type fileYAML struct {
+    Defaults yaml.Node   `yaml:"defaults,omitempty"`
+    Jobs     []yaml.Node `yaml:"jobs"`
+}
+
+// DefaultFile is the file used when there is none: Default() as one job.
+func DefaultFile() File {
+    return File{Defaults: Default(), Jobs: []Job{{Name: DefaultJob, Config: Default()}}}
+}
+
+// LoadFile reads a config file, returning DefaultFile alongside the error
+// when it is missing or unparsable.
+func LoadFile(p string) (File, error) {
+    b, err := os.ReadFile(p)
+    if err != nil { return DefaultFile(), err }
+    f, err := ParseFile(b)
+    if err != nil { return DefaultFile(), err }
+    return f, nil
+}
+
+// ParseFile decodes a config file, migrating the single-job form.
+func ParseFile(b []byte) (File, error) {
+    var keys map[string]yaml.Node
+    if err := yaml.Unmarshal(b, &keys); err != nil { return File{}, err }
+    if _, ok := keys["jobs"]; !ok {
+        var c Config
+        if err := yaml.Unmarshal(b, &c); err != nil { return File{}, err }
+        var j Config
+        _ = yaml.Unmarshal(b, &j) // a copy that shares no slices with c
+        return File{Defaults: c, Jobs: []Job{{Name: DefaultJob, Config: j}}, Migrated: true}, nil
+    }
+
+    var raw fileYAML
+    if err := yaml.Unmarshal(b, &raw); err != nil { return File{}, err }
+    var f File
+    if err := decodeDefaults(&raw.Defaults, &f.Defaults); err != nil { return File{}, fmt.Errorf("defaults: %w", err) }
+    if len(raw.Jobs) == 0 { return File{}, fmt.Errorf("jobs: no jobs listed") }
+    for i := range raw.Jobs {
+        n := &raw.Jobs[i]
+        var head struct{ Name string `yaml:"name"` }
+        if err := n.Decode(&head); err != nil { return File{}, fmt.Errorf("job %d: %w", i+1, err) }
+        if err := CheckJobName(head.Name); err != nil { return File{}, fmt.Errorf("job %d: %w", i+1, err) }
+        if _, dup := f.find(head.Name); dup { return File{}, fmt.Errorf("job %q is listed twice", head.Name) }
+        // decode the defaults afresh so jobs share no slices
+        j := Job{Name: head.Name}
+        if err := decodeDefaults(&raw.Defaults, &j.Config); err != nil { return File{}, err }
+        if err := n.Decode(&j.Config); err != nil { return File{}, fmt.Errorf("job %s: %w", head.Name, err) }
+        f.Jobs = append(f.Jobs, j)
+    }
+    return f, nil
+}
+
+func decodeDefaults(n *yaml.Node, c *Config) error {
+    if n.IsZero() { return nil }
+    return n.Decode(c)
+}
+
+// CheckJobName refuses names that cannot be a lock file or unit name.
+func CheckJobName(name string) error {
+    if name == "" { return fmt.Errorf("job has no name") }
+    if strings.ContainsAny(name, "/ \t\n\\@") || strings.HasPrefix(name, "-") { return fmt.Errorf("bad job name %q (no spaces, slashes or @)", name) }
+    return nil
+}
+
+// SaveFile writes f, each job as its differences from the defaults. A
+// single-job file it replaces is kept as <p>.bak.
+func SaveFile(p string, f File) error {
+    b, err := f.Marshal()
+    if err != nil { return err }
+    if f.Migrated {
+        if old, err := os.ReadFile(p); err == nil {
+            if err := os.WriteFile(p+".bak", old, 0o600); err != nil { return err }
+        }
+    }
+    return os.WriteFile(p, b, 0o600)
+}
+
+// Marshal renders f in the jobs form.
+func (f File) Marshal() ([]byte, error) {
+    zero, err := nodeOf(Config{})
+    if err != nil { return nil, err }
+    defaults, err := nodeOf(f.Defaults)
+    if err != nil { return nil, err }
+    out := struct {
+        Defaults *yaml.Node  `yaml:"defaults,omitempty"`
+        Jobs     []*yaml.Node `yaml:"jobs"`
+    }{}
+    if d := overlay(zero, defaults); len(d.Content) > 0 { out.Defaults = d }
+    for _, j := range f.Jobs {
+        if err := CheckJobName(j.Name); err != nil { return nil, err }
+        n, err := nodeOf(j.Config)
+        if err != nil { return nil, err }
+        d := overlay(defaults, n)
+        name := []*yaml.Node{{Kind: yaml.ScalarNode, Tag: "!!str", Value: "name"}, {Kind: yaml.ScalarNode, Tag: "!!str", Value: j.Name}}
+        d.Content = append(name, d.Content...)
+        out.Jobs = append(out.Jobs, d)
+    }
+    return yaml.Marshal(out)
+}
+
+// Job returns the named job, or the only one when name is "".
+func (f File) Job(name string) (Job, error) {
+    if name == "" {
+        if len(f.Jobs) == 1 { return f.Jobs[0], nil }
+        return Job{}, fmt.Errorf("this config has %d jobs (%s); name one", len(f.Jobs), strings.Join(f.Names(), ", "))
+    }
+    if i, ok := f.find(name); ok { return f.Jobs[i], nil }
+    return Job{}, fmt.Errorf("unknown job %q (jobs: %s)", name, strings.Join(f.Names(), ", "))
+}
+
+// Names lists the job names in file order.
+func (f File) Names() []string {
+    var names []string
+    for _, j := range f.Jobs { names = append(names, j.Name) }
+    return names
+}
+
+// SetJob replaces the job of the same name, or appends j.
+func (f *File) SetJob(j Job) {
+    if i, ok := f.find(j.Name); ok { f.Jobs[i] = j; return }
+    f.Jobs = append(f.Jobs, j)
+}
+
+func (f File) find(name string) (int, bool) {
+    for i, j := range f.Jobs {
+        if j.Name == name { return i, true }
+    }
+    return -1, false
+}
+
+// --------------------------- OVERLAY ---------------------------
+
+// nodeOf encodes v as a YAML mapping node.
+func nodeOf(v any) (*yaml.Node, error) {
+    var n yaml.Node
+    if err := n.Encode(v); err != nil { return nil, err }
+    return &n, nil
+}
+
+// overlay returns the keys of mapping n that differ from mapping base, such
+// that decoding them onto a value encoded as base yields n's value. Keys
+// base has and n omits (omitempty zeros) are written as zeros.
+func overlay(base, n *yaml.Node) *yaml.Node {
+    out := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+    have := map[string]*yaml.Node{}
+    for i := 0; i+1 < len(base.Content); i += 2 { have[base.Content[i].Value] = base.Content[i+1] }
+    seen := map[string]bool{}
+    for i := 0; i+1 < len(n.Content); i += 2 {
+        k, v := n.Content[i], n.Content[i+1]
+        seen[k.Value] = true
+        b, ok := have[k.Value]
+        switch {
+        case !ok:
+            out.Content = append(out.Content, k, v)
+        case b.Kind == yaml.MappingNode && v.Kind == yaml.MappingNode:
+            // nested structs merge on decode, so recurse
+            if d := overlay(b, v); len(d.Content) > 0 { out.Content = append(out.Content, k, d) }
+        case !sameNode(b, v):
+            out.Content = append(out.Content, k, v)
+        }
+    }
+    for i := 0; i+1 < len(base.Content); i += 2 {
+        k, b := base.Content[i], base.Content[i+1]
+        if !seen[k.Value] && !isZeroNode(b) { out.Content = append(out.Content, k, zeroNode(b)) }
+    }
+    return out
+}
+
+func sameNode(a, b *yaml.Node) bool {
+    var x, y any
+    if a.Decode(&x) != nil || b.Decode(&y) != nil { return false }
+    return reflect.DeepEqual(x, y)
+}
+
+func isZeroNode(n *yaml.Node) bool { return sameNode(n, zeroNode(n)) }
+
+// zeroNode is the zero value of n's type: "", 0, false, [] or the zero of
+// every key of a mapping.
+func zeroNode(n *yaml.Node) *yaml.Node {
+    switch n.Kind {
+    case yaml.MappingNode:
+        z := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Style: yaml.FlowStyle}
+        for i := 0; i+1 < len(n.Content); i += 2 { z.Content = append(z.Content, n.Content[i], zeroNode(n.Content[i+1])) }
+        return z
+    case yaml.SequenceNode:
+        return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+    }
+    switch n.Tag {
+    case "!!int":
+        return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: "0"}
+    case "!!bool":
+        return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"}
+    }
+    return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "", Style: yaml.DoubleQuotedStyle}
+}
@@ -0,0 +1,160 @@
+// File: internal/config/jobs_test.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-17
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Tests of the jobs file: the defaults overlay and nested merge, the
+//   migration of a single-job file, Marshal/ParseFile round trips and the
+//   files ParseFile refuses.
+
+package config
+
+import (
+    reflect "reflect"
+    strings "strings"
+    testing "testing"
+
+    "gopkg.in/yaml.v3"
+)
+
+const jobsYAML = `
+defaults:
+  remote_user: backup
+  remote_host: nas.lan
+  ssh_port: 2222
+  excludes: [/tmp/*]
+  retention: {keep_daily: 7, keep_weekly: 4}
+jobs:
+  - name: root
+    strategy: rsync
+    schedule: daily
+  - name: disk
+    strategy: raw-dd
+    source_disk: /dev/sda
+    ssh_port: 22
+    excludes: []
+    retention: {keep_weekly: 8}
+`
+
+func TestParseFileOverlay(t *testing.T) {
+    f, err := ParseFile([]byte(jobsYAML))
+    if err != nil { t.Fatal(err) }
+    if f.Migrated { t.Error("a jobs file reads as migrated") }
+    if got := strings.Join(f.Names(), ","); got != "root,disk" { t.Fatalf("jobs = %s", got) }
+
+    root, disk := f.Jobs[0].Config, f.Jobs[1].Config
+    cases := []struct {
+        what      string
+        got, want any
+    }{
+        {"root remote_host", root.RemoteHost, "nas.lan"},
+        {"root ssh_port", root.SSHPort, 2222},
+        {"root strategy", root.Strategy, StratRsync},
+        {"root schedule", root.Schedule, "daily"},
+        {"root excludes", root.Excludes, []string{"/tmp/*"}},
+        {"root retention", root.Retention, Retention{KeepDaily: 7, KeepWeekly: 4}},
+        {"disk remote_user", disk.RemoteUser, "backup"},
+        {"disk ssh_port", disk.SSHPort, 22},
+        {"disk source_disk", disk.SourceDisk, "/dev/sda"},
+        {"disk schedule", disk.Schedule, ""},
+        {"disk excludes", disk.Excludes, []string{}},
+        // nested keys merge: keep_daily comes from the defaults
+        {"disk retention", disk.Retention, Retention{KeepDaily: 7, KeepWeekly: 8}},
+    }
+    for _, c := range cases {
+        if !reflect.DeepEqual(c.got, c.want) { t.Errorf("%s = %#v, want %#v", c.what, c.got, c.want) }
+    }
+
+    // jobs share no slices with each other or the defaults
+    root.Excludes[0] = "/changed"
+    if f.Defaults.Excludes[0] != "/tmp/*" { t.Error("a job's excludes alias the defaults") }
+}
+
+func TestParseFileMigrates(t *testing.T) {
+    f, err := ParseFile([]byte("remote_host: old.lan\nstrategy: borg\nexcludes: [/proc/*]\n"))
+    if err != nil { t.Fatal(err) }
+    if !f.Migrated { t.Error("not marked migrated") }
+    if len(f.Jobs) != 1 || f.Jobs[0].Name != DefaultJob { t.Fatalf("jobs = %v", f.Names()) }
+    j := f.Jobs[0]
+    if j.RemoteHost != "old.lan" || j.Strategy != StratBorg || f.Defaults.RemoteHost != "old.lan" { t.Errorf("job = %+v, defaults = %+v", j.Config, f.Defaults) }
+    j.Excludes[0] = "/changed"
+    if f.Defaults.Excludes[0] != "/proc/*" { t.Error("the job's excludes alias the defaults") }
+
+    // saved, it is a jobs file with the job adding nothing to the defaults
+    b, err := f.Marshal()
+    if err != nil { t.Fatal(err) }
+    if !strings.Contains(string(b), "jobs:\n    - name: default\n") { t.Errorf("migrated file:\n%s", b) }
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+    d := Default()
+    d.Retention = Retention{KeepDaily: 7, KeepMonthly: 6}
+    home := d
+    home.Sources = []Source{{Path: "/home", Excludes: []string{".cache/"}}, {Path: "/etc"}}
+    home.Retention.KeepDaily = 14
+    disk := d
+    disk.Strategy, disk.SourceDisk, disk.DDSparse = StratDD, "/dev/nvme0n1", "fs"
+    disk.Excludes = nil
+    disk.ScheduleJitter = ""
+    disk.Retention = Retention{}
+    cases := map[string]File{
+        "defaults only":  DefaultFile(),
+        "jobs":           {Defaults: d, Jobs: []Job{{Name: "home", Config: home}, {Name: "disk", Config: disk}}},
+        "empty defaults": {Jobs: []Job{{Name: "a", Config: disk}}},
+    }
+    for name, f := range cases {
+        b, err := f.Marshal()
+        if err != nil { t.Errorf("%s: %v", name, err); continue }
+        g, err := ParseFile(b)
+        if err != nil { t.Errorf("%s: %v\n%s", name, err, b); continue }
+        if !reflect.DeepEqual(jobConfigs(g), jobConfigs(f)) { t.Errorf("%s: round trip changed the jobs:\n%s", name, b) }
+    }
+}
+
+// jobConfigs renders each job as YAML, where a nil and an empty list read
+// the same.
+func jobConfigs(f File) map[string]string {
+    out := map[string]string{}
+    for _, j := range f.Jobs {
+        b, err := yaml.Marshal(j.Config)
+        if err != nil { panic(err) }
+        out[j.Name] = string(b)
+    }
+    return out
+}
+
+func TestMarshalWritesDifferences(t *testing.T) {
+    d := Default()
+    j := d
+    j.Strategy = StratBorg
+    b, err := File{Defaults: d, Jobs: []Job{{Name: "b", Config: j}}}.Marshal()
+    if err != nil { t.Fatal(err) }
+    _, jobs, _ := strings.Cut(string(b), "jobs:\n")
+    if want := "    - name: b\n      strategy: borg\n"; jobs != want { t.Errorf("job written as\n%s\nwant\n%s", jobs, want) }
+}
+
+func TestSourceYAML(t *testing.T) {
+    f, err := ParseFile([]byte("jobs:\n  - name: s\n    sources:\n      - /etc\n      - {path: /home, excludes: [.cache/]}\n"))
+    if err != nil { t.Fatal(err) }
+    want := []Source{{Path: "/etc"}, {Path: "/home", Excludes: []string{".cache/"}}}
+    if got := f.Jobs[0].Sources; !reflect.DeepEqual(got, want) { t.Errorf("sources = %+v", got) }
+    b, err := f.Marshal()
+    if err != nil { t.Fatal(err) }
+    if !strings.Contains(string(b), "- /etc\n") || !strings.Contains(string(b), "path: /home") { t.Errorf("sources written as\n%s", b) }
+}
+
+func TestParseFileRefuses(t *testing.T) {
+    cases := map[string]string{
+        "no jobs":      "jobs: []\n",
+        "no name":      "jobs:\n  - strategy: rsync\n",
+        "bad name":     "jobs:\n  - name: a/b\n",
+        "@ in name":    "jobs:\n  - name: a@b\n",
+        "dash name":    "jobs:\n  - name: -x\n",
+        "listed twice": "jobs:\n  - name: a\n  - name: a\n",
+        "bad key type": "jobs:\n  - name: a\n    ssh_port: many\n",
+        "not yaml":     "jobs: [\n",
+    }
+    for name, y := range cases {
+        if _, err := ParseFile([]byte(y)); err == nil { t.Errorf("%s: parsed", name) }
+    }
+}
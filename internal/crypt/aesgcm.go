@@ -0,0 +1,165 @@
+// File: internal/crypt/aesgcm.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   AES-256-GCM stream framing. A stream is a header (magic + random 7-byte
+//   nonce prefix) followed by frames of at most 64 KiB plaintext:
+//
+//     uint32 BE (ciphertext length | 0x80000000 on the last frame) ‖ ciphertext
+//
+//   Frame i is sealed with nonce prefix ‖ uint32 BE i ‖ last-flag byte and the
+//   header as additional data, so reordered, dropped, truncated or appended
+//   frames all fail to open.
+
+package crypt
+
+import (
+    aes "crypto/aes"
+    cipher "crypto/cipher"
+    rand "crypto/rand"
+    binary "encoding/binary"
+    hex "encoding/hex"
+    errors "errors"
+    fmt "fmt"
+    io "io"
+    math "math"
+    os "os"
+    strings "strings"
+)
+
+const (
+    gcmMagic    = "OCTOAES1"
+    gcmPrefix   = 7
+    gcmChunk    = 64 << 10
+    gcmLastFlag = 0x80000000
+)
+
+var errTruncated = errors.New("aes-gcm: stream truncated")
+
+// readKey loads a 32-byte key file, raw or as 64 hex characters.
+func readKey(path string) ([]byte, error) {
+    if path == "" { return nil, fmt.Errorf("aes-gcm: key file env var is empty") }
+    b, err := os.ReadFile(path)
+    if err != nil { return nil, fmt.Errorf("aes-gcm key: %w", err) }
+    if len(b) == 32 { return b, nil }
+    if k, err := hex.DecodeString(strings.TrimSpace(string(b))); err == nil && len(k) == 32 { return k, nil }
+    return nil, fmt.Errorf("aes-gcm key %s: want 32 raw bytes or 64 hex characters", path)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+    block, err := aes.NewCipher(key)
+    if err != nil { return nil, err }
+    return cipher.NewGCM(block)
+}
+
+func gcmNonce(prefix []byte, i uint32, last bool) []byte {
+    n := make([]byte, 12)
+    copy(n, prefix)
+    binary.BigEndian.PutUint32(n[gcmPrefix:], i)
+    if last { n[11] = 1 }
+    return n
+}
+
+// --------------------------- SEAL ---------------------------
+
+type sealer struct {
+    w      io.Writer
+    aead   cipher.AEAD
+    header []byte
+    buf    []byte
+    n      uint32
+}
+
+func newSealer(w io.Writer, key []byte) (*sealer, error) {
+    aead, err := newGCM(key)
+    if err != nil { return nil, err }
+    header := make([]byte, len(gcmMagic)+gcmPrefix)
+    copy(header, gcmMagic)
+    if _, err := rand.Read(header[len(gcmMagic):]); err != nil { return nil, err }
+    if _, err := w.Write(header); err != nil { return nil, err }
+    return &sealer{w: w, aead: aead, header: header, buf: make([]byte, 0, gcmChunk)}, nil
+}
+
+func (s *sealer) Write(p []byte) (int, error) {
+    written := 0
+    for len(p) > 0 {
+        // a full buffer is only flushed once more data arrives, so the
+        // last frame is always the one Close writes
+        if len(s.buf) == gcmChunk {
+            if err := s.flush(false); err != nil { return written, err }
+        }
+        k := copy(s.buf[len(s.buf):gcmChunk], p)
+        s.buf = s.buf[:len(s.buf)+k]
+        p = p[k:]
+        written += k
+    }
+    return written, nil
+}
+
+func (s *sealer) Close() error { return s.flush(true) }
+
+func (s *sealer) flush(last bool) error {
+    if s.n == math.MaxUint32 { return fmt.Errorf("aes-gcm: stream too long") }
+    ct := s.aead.Seal(nil, gcmNonce(s.header[len(gcmMagic):], s.n, last), s.buf, s.header)
+    hdr := uint32(len(ct))
+    if last { hdr |= gcmLastFlag }
+    var lb [4]byte
+    binary.BigEndian.PutUint32(lb[:], hdr)
+    if _, err := s.w.Write(lb[:]); err != nil { return err }
+    if _, err := s.w.Write(ct); err != nil { return err }
+    s.n++
+    s.buf = s.buf[:0]
+    return nil
+}
+
+// --------------------------- OPEN ---------------------------
+
+type opener struct {
+    r      io.Reader
+    aead   cipher.AEAD
+    header []byte
+    ct     []byte
+    plain  []byte
+    n      uint32
+    done   bool
+}
+
+func newOpener(r io.Reader, key []byte) (*opener, error) {
+    aead, err := newGCM(key)
+    if err != nil { return nil, err }
+    header := make([]byte, len(gcmMagic)+gcmPrefix)
+    if _, err := io.ReadFull(r, header); err != nil { return nil, errTruncated }
+    if string(header[:len(gcmMagic)]) != gcmMagic { return nil, fmt.Errorf("aes-gcm: not an octobackup aes-gcm stream") }
+    return &opener{r: r, aead: aead, header: header, ct: make([]byte, gcmChunk+aead.Overhead())}, nil
+}
+
+func (o *opener) Read(p []byte) (int, error) {
+    for len(o.plain) == 0 {
+        if o.done { return 0, io.EOF }
+        if err := o.next(); err != nil { return 0, err }
+    }
+    k := copy(p, o.plain)
+    o.plain = o.plain[k:]
+    return k, nil
+}
+
+func (o *opener) next() error {
+    var lb [4]byte
+    if _, err := io.ReadFull(o.r, lb[:]); err != nil { return errTruncated }
+    hdr := binary.BigEndian.Uint32(lb[:])
+    last := hdr&gcmLastFlag != 0
+    size := int(hdr &^ gcmLastFlag)
+    if size < o.aead.Overhead() || size > len(o.ct) { return fmt.Errorf("aes-gcm: bad frame length %d", size) }
+    if _, err := io.ReadFull(o.r, o.ct[:size]); err != nil { return errTruncated }
+    plain, err := o.aead.Open(o.ct[:0], gcmNonce(o.header[len(gcmMagic):], o.n, last), o.ct[:size], o.header)
+    if err != nil { return fmt.Errorf("aes-gcm: frame %d failed authentication (wrong key or corrupted)", o.n) }
+    o.plain = plain
+    o.n++
+    if last {
+        o.done = true
+        var extra [1]byte
+        if k, _ := o.r.Read(extra[:]); k > 0 { return fmt.Errorf("aes-gcm: data after final frame") }
+    }
+    return nil
+}
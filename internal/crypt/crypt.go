@@ -0,0 +1,181 @@
+// File: internal/crypt/crypt.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Client-side encryption of streamed backups as pipeline stages, so the
+//   remote only ever stores ciphertext. Three modes:
+//     • age             — age format to X25519 recipients (public keys);
+//                         restore needs the matching identity.
+//     • age-passphrase  — age format with an scrypt passphrase.
+//     • aes-gcm         — AES-256-GCM in authenticated 64 KiB frames with a
+//                         32-byte key file.
+//
+// Security:
+//   • Secrets never come from the YAML config; callers pass what they read
+//     from the env var the config names (identity, passphrase or key path).
+
+package crypt
+
+import (
+    fmt "fmt"
+    io "io"
+    os "os"
+    strings "strings"
+
+    "filippo.io/age"
+
+    "cloudcurio.cc/octobackup/internal/pipeline"
+)
+
+// Mode selects the encryption scheme.
+type Mode string
+
+const (
+    None       Mode = ""
+    Age        Mode = "age"
+    Passphrase Mode = "age-passphrase"
+    AESGCM     Mode = "aes-gcm"
+)
+
+// ParseMode accepts the config spellings; "" and "none" are None.
+func ParseMode(s string) (Mode, error) {
+    switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+    case "none", None:
+        return None, nil
+    case Age, Passphrase, AESGCM:
+        return m, nil
+    }
+    return None, fmt.Errorf("unknown encryption %q (want age, age-passphrase, aes-gcm or none)", s)
+}
+
+// Ext is the file extension for mode, including the dot.
+func (m Mode) Ext() string {
+    switch m {
+    case Age, Passphrase:
+        return ".age"
+    case AESGCM:
+        return ".aes"
+    }
+    return ""
+}
+
+// Settings is everything a stage needs. Secret is the value of the config's
+// secret env var: the passphrase, the path of the AES key file, or (for
+// age, on restore only) an AGE-SECRET-KEY identity or identity file path.
+type Settings struct {
+    Mode       Mode
+    Recipients []string
+    Secret     string
+}
+
+// CheckEncrypt reports why s cannot encrypt, or nil.
+func (s Settings) CheckEncrypt() error {
+    switch s.Mode {
+    case Age:
+        _, err := s.recipients()
+        return err
+    case Passphrase:
+        if s.Secret == "" { return fmt.Errorf("age-passphrase: passphrase env var is empty") }
+    case AESGCM:
+        _, err := readKey(s.Secret)
+        return err
+    }
+    return nil
+}
+
+// CheckDecrypt reports why s cannot decrypt, or nil.
+func (s Settings) CheckDecrypt() error {
+    switch s.Mode {
+    case Age, Passphrase:
+        _, err := s.identities()
+        return err
+    case AESGCM:
+        _, err := readKey(s.Secret)
+        return err
+    }
+    return nil
+}
+
+func (s Settings) recipients() ([]age.Recipient, error) {
+    switch s.Mode {
+    case Passphrase:
+        r, err := age.NewScryptRecipient(s.Secret)
+        if err != nil { return nil, err }
+        return []age.Recipient{r}, nil
+    case Age:
+        if len(s.Recipients) == 0 { return nil, fmt.Errorf("age: no recipients configured") }
+        rs, err := age.ParseRecipients(strings.NewReader(strings.Join(s.Recipients, "\n")))
+        if err != nil { return nil, fmt.Errorf("age recipients: %w", err) }
+        return rs, nil
+    }
+    return nil, fmt.Errorf("%s is not an age mode", s.Mode)
+}
+
+func (s Settings) identities() ([]age.Identity, error) {
+    switch s.Mode {
+    case Passphrase:
+        if s.Secret == "" { return nil, fmt.Errorf("age-passphrase: passphrase env var is empty") }
+        id, err := age.NewScryptIdentity(s.Secret)
+        if err != nil { return nil, err }
+        return []age.Identity{id}, nil
+    case Age:
+        if s.Secret == "" { return nil, fmt.Errorf("age: identity env var is empty") }
+        src := s.Secret
+        if !strings.HasPrefix(strings.TrimSpace(src), "AGE-SECRET-KEY-") {
+            b, err := os.ReadFile(src)
+            if err != nil { return nil, fmt.Errorf("age identity file: %w", err) }
+            src = string(b)
+        }
+        ids, err := age.ParseIdentities(strings.NewReader(src))
+        if err != nil { return nil, fmt.Errorf("age identity: %w", err) }
+        return ids, nil
+    }
+    return nil, fmt.Errorf("%s is not an age mode", s.Mode)
+}
+
+// EncryptStage is a pipeline stage encrypting its input per s.
+func EncryptStage(s Settings) (pipeline.Stage, error) {
+    if err := s.CheckEncrypt(); err != nil { return pipeline.Stage{}, err }
+    return pipeline.Func("encrypt-"+string(s.Mode), func(dst io.Writer, src io.Reader) error {
+        var (
+            w   io.WriteCloser
+            err error
+        )
+        if s.Mode == AESGCM {
+            key, kerr := readKey(s.Secret)
+            if kerr != nil { return kerr }
+            w, err = newSealer(dst, key)
+        } else {
+            rs, rerr := s.recipients()
+            if rerr != nil { return rerr }
+            w, err = age.Encrypt(dst, rs...)
+        }
+        if err != nil { return err }
+        if _, err := io.Copy(w, src); err != nil { w.Close(); return err }
+        return w.Close()
+    }), nil
+}
+
+// DecryptStage is a pipeline stage decrypting its input per s.
+func DecryptStage(s Settings) (pipeline.Stage, error) {
+    if err := s.CheckDecrypt(); err != nil { return pipeline.Stage{}, err }
+    return pipeline.Func("decrypt-"+string(s.Mode), func(dst io.Writer, src io.Reader) error {
+        var (
+            r   io.Reader
+            err error
+        )
+        if s.Mode == AESGCM {
+            key, kerr := readKey(s.Secret)
+            if kerr != nil { return kerr }
+            r, err = newOpener(src, key)
+        } else {
+            ids, ierr := s.identities()
+            if ierr != nil { return ierr }
+            r, err = age.Decrypt(src, ids...)
+        }
+        if err != nil { return err }
+        _, err = io.Copy(dst, r)
+        return err
+    }), nil
+}
@@ -0,0 +1,216 @@
+// File: internal/fileindex/fileindex.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Per-file checksum indexes of a file-level backup: one entry per regular
+//   file with its path, size, mtime, mode and content hash. Build walks the
+//   source trees with the backup's excludes, reusing the previous index's
+//   hash for files whose size and mtime are unchanged (rsync's own quick
+//   check), so only changed files are read.
+//
+//   The on-disk form is gzip'd text, one tab-separated entry per line after
+//   a "# octobackup-index 1 <alg>" header; paths are last and Go-quoted when
+//   they contain a tab, newline or leading quote.
+
+package fileindex
+
+import (
+    bufio "bufio"
+    gzip "compress/gzip"
+    context "context"
+    fmt "fmt"
+    io "io"
+    fs "io/fs"
+    os "os"
+    path "path"
+    path_file "path/filepath"
+    sort "sort"
+    strconv "strconv"
+    strings "strings"
+    syscall "syscall"
+
+    "cloudcurio.cc/octobackup/internal/checksum"
+)
+
+const header = "# octobackup-index 1 "
+
+// Entry is one regular file.
+type Entry struct {
+    Path  string      // absolute path on the source, e.g. /etc/hosts
+    Size  int64
+    MTime int64       // unix seconds
+    Mode  fs.FileMode // permission bits
+    Sum   string      // hex digest
+}
+
+// Index is the entries of one backup, sorted by path.
+type Index struct {
+    Alg     string
+    Entries []Entry
+}
+
+// Map returns the entries by path.
+func (x Index) Map() map[string]Entry {
+    m := make(map[string]Entry, len(x.Entries))
+    for _, e := range x.Entries { m[e.Path] = e }
+    return m
+}
+
+// Stats counts what Build did.
+type Stats struct {
+    Files   int
+    Hashed  int   // read and hashed
+    Reused  int   // hash taken from the previous index
+    Skipped int   // unreadable
+    Bytes   int64 // bytes hashed
+}
+
+// Build indexes the regular files under the roots (absolute paths), skipping
+// paths the rsync-style excludes match and, with oneFS, directories on
+// another filesystem than their root. prev, if it used the same algorithm,
+// supplies hashes of unchanged files. Files that cannot be read are counted
+// and left out.
+func Build(ctx context.Context, roots []string, excludes []string, oneFS bool, alg string, prev Index) (Index, Stats, error) {
+    var st Stats
+    if checksum.New(alg) == nil { return Index{}, st, fmt.Errorf("unknown checksum %q", alg) }
+    old := map[string]Entry{}
+    if prev.Alg == alg { old = prev.Map() }
+    x := Index{Alg: alg}
+    seen := map[string]bool{}
+    for _, root := range roots {
+        rootDev, _ := device(root)
+        err := path_file.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+            if ctx.Err() != nil { return ctx.Err() }
+            if err != nil {
+                if p == root { return err }
+                st.Skipped++
+                if d != nil && d.IsDir() { return fs.SkipDir }
+                return nil
+            }
+            if p != root && Excluded(excludes, p, d.IsDir()) {
+                if d.IsDir() { return fs.SkipDir }
+                return nil
+            }
+            if d.IsDir() && oneFS && p != root {
+                // like rsync -x: the mount point is kept, not its contents
+                if dev, ok := device(p); ok && dev != rootDev { return fs.SkipDir }
+            }
+            if !d.Type().IsRegular() || seen[p] { return nil }
+            seen[p] = true
+            return st.add(&x, old, p, d, alg)
+        })
+        if err != nil { return Index{}, st, err }
+    }
+    sort.Slice(x.Entries, func(i, j int) bool { return x.Entries[i].Path < x.Entries[j].Path })
+    return x, st, nil
+}
+
+// device is the filesystem a path is on.
+func device(p string) (uint64, bool) {
+    var s syscall.Stat_t
+    if err := syscall.Lstat(p, &s); err != nil { return 0, false }
+    return uint64(s.Dev), true
+}
+
+// add indexes the regular file p, reusing its old hash when unchanged.
+func (st *Stats) add(x *Index, old map[string]Entry, p string, d fs.DirEntry, alg string) error {
+    fi, err := d.Info()
+    if err != nil { st.Skipped++; return nil }
+    e := Entry{Path: p, Size: fi.Size(), MTime: fi.ModTime().Unix(), Mode: fi.Mode().Perm()}
+    st.Files++
+    if o, ok := old[p]; ok && o.Size == e.Size && o.MTime == e.MTime {
+        e.Sum = o.Sum
+        st.Reused++
+    } else {
+        if e.Sum, err = hashFile(p, alg); err != nil { st.Files--; st.Skipped++; return nil }
+        st.Hashed++
+        st.Bytes += e.Size
+    }
+    x.Entries = append(x.Entries, e)
+    return nil
+}
+
+func hashFile(p, alg string) (string, error) {
+    f, err := os.Open(p)
+    if err != nil { return "", err }
+    defer f.Close()
+    h := checksum.New(alg)
+    if _, err := io.Copy(h, f); err != nil { return "", err }
+    return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// Excluded reports whether rsync-style patterns exclude rel (an absolute
+// path from the transfer root). A leading / anchors a pattern at the root,
+// a trailing / limits it to directories, and a pattern without a slash
+// matches the last component; * and ? do not cross a slash. ** is only
+// supported as /prefix/**/pattern: pattern, unanchored, below /prefix.
+func Excluded(patterns []string, rel string, dir bool) bool {
+    for _, p := range patterns {
+        if strings.HasSuffix(p, "/") {
+            if !dir { continue }
+            p = strings.TrimSuffix(p, "/")
+        }
+        if pre, post, ok := strings.Cut(p, "/**/"); ok && strings.HasPrefix(pre, "/") {
+            if below, ok := strings.CutPrefix(rel, pre+"/"); ok && Excluded([]string{post}, "/"+below, dir) { return true }
+            continue
+        }
+        switch {
+        case strings.HasPrefix(p, "/"):
+            if ok, _ := path.Match(p, rel); ok { return true }
+        case !strings.Contains(p, "/"):
+            if ok, _ := path.Match(p, path.Base(rel)); ok { return true }
+        default:
+            // match the pattern against the same number of trailing components
+            n := strings.Count(p, "/") + 1
+            parts := strings.Split(strings.TrimPrefix(rel, "/"), "/")
+            if len(parts) < n { continue }
+            if ok, _ := path.Match(p, strings.Join(parts[len(parts)-n:], "/")); ok { return true }
+        }
+    }
+    return false
+}
+
+// Write stores x gzip-compressed.
+func Write(w io.Writer, x Index) error {
+    zw := gzip.NewWriter(w)
+    bw := bufio.NewWriter(zw)
+    fmt.Fprintf(bw, "%s%s\n", header, x.Alg)
+    for _, e := range x.Entries {
+        fmt.Fprintf(bw, "%s\t%d\t%d\t%o\t%s\n", e.Sum, e.Size, e.MTime, uint32(e.Mode), quotePath(e.Path))
+    }
+    if err := bw.Flush(); err != nil { return err }
+    return zw.Close()
+}
+
+// Read loads an index written by Write.
+func Read(r io.Reader) (Index, error) {
+    zr, err := gzip.NewReader(r)
+    if err != nil { return Index{}, fmt.Errorf("index: %w", err) }
+    defer zr.Close()
+    sc := bufio.NewScanner(zr)
+    sc.Buffer(make([]byte, 64*1024), 1024*1024)
+    if !sc.Scan() || !strings.HasPrefix(sc.Text(), header) { return Index{}, fmt.Errorf("index: bad header") }
+    x := Index{Alg: strings.TrimPrefix(sc.Text(), header)}
+    for sc.Scan() {
+        f := strings.SplitN(sc.Text(), "\t", 5)
+        if len(f) != 5 { return Index{}, fmt.Errorf("index: bad line %q", sc.Text()) }
+        e := Entry{Sum: f[0]}
+        var mode uint32
+        if _, err := fmt.Sscanf(f[1]+" "+f[2]+" "+f[3], "%d %d %o", &e.Size, &e.MTime, &mode); err != nil { return Index{}, fmt.Errorf("index: bad line %q", sc.Text()) }
+        e.Mode = fs.FileMode(mode)
+        if e.Path, err = unquotePath(f[4]); err != nil { return Index{}, fmt.Errorf("index: bad path %q", f[4]) }
+        x.Entries = append(x.Entries, e)
+    }
+    return x, sc.Err()
+}
+
+func quotePath(p string) string {
+    if strings.ContainsAny(p, "\t\n") || strings.HasPrefix(p, `"`) { return strconv.Quote(p) }
+    return p
+}
+
+func unquotePath(p string) (string, error) {
+    if strings.HasPrefix(p, `"`) { return strconv.Unquote(p) }
+    return p, nil
+}
@@ -0,0 +1,306 @@
+// File: internal/pipeline/pipeline.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   A shell-free pipeline builder. Stages are external commands (os/exec,
+//   connected with OS pipes) or in-process filters (Go funcs copying from
+//   the previous stage to the next). No local shell parses anything, and
+//   every stage's exit status is collected, so a failure names the stage
+//   that caused it instead of whatever `bash -c` saw last.
+//
+//   Commands are started through internal/proc: each runs in its own
+//   process group, follows the run's pause/resume and is terminated as a
+//   group when the context is cancelled.
+//
+//   Arguments bound for a remote shell (ssh) are quoted with Quote/Join.
+
+package pipeline
+
+import (
+    bufio "bufio"
+    bytes "bytes"
+    context "context"
+    errors "errors"
+    fmt "fmt"
+    io "io"
+    os "os"
+    os_exec "os/exec"
+    path_file "path/filepath"
+    strings "strings"
+    sync "sync"
+    syscall "syscall"
+
+    "cloudcurio.cc/octobackup/internal/proc"
+)
+
+// Stage is one step of a pipeline: an external command (Argv) or an
+// in-process filter (Filter) reading the previous stage's output from src
+// and writing its own to dst.
+type Stage struct {
+    Name   string
+    Argv   []string
+    Env    []string // appended to os.Environ()
+    Dir    string
+    Filter func(dst io.Writer, src io.Reader) error
+}
+
+// Cmd is an external command stage.
+func Cmd(argv ...string) Stage { return Stage{Name: path_file.Base(argv[0]), Argv: argv} }
+
+// Func is an in-process filter stage.
+func Func(name string, fn func(dst io.Writer, src io.Reader) error) Stage {
+    return Stage{Name: name, Filter: fn}
+}
+
+// WithEnv returns s with extra environment variables.
+func (s Stage) WithEnv(env ...string) Stage { s.Env = append(append([]string(nil), s.Env...), env...); return s }
+
+// InDir returns s running in dir.
+func (s Stage) InDir(dir string) Stage { s.Dir = dir; return s }
+
+// Command builds the exec.Cmd for a command stage, in its own process group.
+func (s Stage) Command(ctx context.Context) *os_exec.Cmd {
+    cmd := proc.Command(ctx, s.Argv[0], s.Argv[1:]...)
+    if len(s.Env) > 0 { cmd.Env = append(os.Environ(), s.Env...) }
+    cmd.Dir = s.Dir
+    return cmd
+}
+
+func (s Stage) String() string {
+    if s.Filter != nil { return "[" + s.Name + "]" }
+    return Join(s.Argv)
+}
+
+// Pipeline is an ordered list of stages, each feeding the next.
+type Pipeline struct {
+    Stages []Stage
+}
+
+// New starts a pipeline from stages.
+func New(stages ...Stage) *Pipeline { return &Pipeline{Stages: stages} }
+
+// Add appends stages and returns p for chaining.
+func (p *Pipeline) Add(stages ...Stage) *Pipeline { p.Stages = append(p.Stages, stages...); return p }
+
+// Insert puts s before index i (clamped to the ends).
+func (p *Pipeline) Insert(i int, s Stage) *Pipeline {
+    if i < 0 { i = 0 }
+    if i > len(p.Stages) { i = len(p.Stages) }
+    p.Stages = append(p.Stages[:i], append([]Stage{s}, p.Stages[i:]...)...)
+    return p
+}
+
+// String renders the pipeline shell-style, for logs only.
+func (p *Pipeline) String() string {
+    parts := make([]string, len(p.Stages))
+    for i, s := range p.Stages { parts[i] = s.String() }
+    return strings.Join(parts, " | ")
+}
+
+// Options wires a pipeline's ends. Nil funcs discard output.
+type Options struct {
+    Stdin  io.Reader                // input to the first stage; none when nil
+    Stdout func(line string)        // last stage's output, line by line
+    Stderr func(stage, line string) // every command stage's stderr
+}
+
+// StageError reports the stage a pipeline failed in.
+type StageError struct {
+    Index    int    // 0-based stage index
+    Total    int    // number of stages
+    Name     string
+    ExitCode int    // -1 when the stage did not exit normally
+    Stderr   string // last stderr line, if any
+    Err      error
+}
+
+func (e *StageError) Error() string {
+    msg := fmt.Sprintf("stage %d/%d (%s): %v", e.Index+1, e.Total, e.Name, e.Err)
+    if e.Stderr != "" { msg += ": " + e.Stderr }
+    return msg
+}
+
+func (e *StageError) Unwrap() error { return e.Err }
+
+// stageResult is what one stage ended with, in completion order.
+type stageResult struct {
+    index int
+    err   error
+}
+
+// Run executes the pipeline and waits for every stage. It returns nil or a
+// *StageError for the stage that caused the failure: the first to fail for
+// a reason of its own, ignoring stages that only died of a broken pipe or
+// of the teardown that followed.
+func (p *Pipeline) Run(ctx context.Context, opts Options) error {
+    n := len(p.Stages)
+    if n == 0 { return errors.New("pipeline: no stages") }
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+
+    // pipes[i] connects stage i to stage i+1; pipes[n-1] carries the output
+    readers := make([]*os.File, n)
+    writers := make([]*os.File, n)
+    for i := 0; i < n; i++ {
+        r, w, err := os.Pipe()
+        if err != nil { closeAll(readers, writers); return err }
+        readers[i], writers[i] = r, w
+    }
+
+    var (
+        wg       sync.WaitGroup
+        results  = make(chan stageResult, n)
+        tails    = make([]*lastLine, n)
+        launched int
+    )
+
+    outDone := make(chan struct{})
+    go func() { scanLines(readers[n-1], opts.Stdout); readers[n-1].Close(); close(outDone) }()
+
+    for i, st := range p.Stages {
+        var in io.Reader = opts.Stdin
+        if i > 0 { in = readers[i-1] }
+        out := writers[i]
+
+        if st.Filter != nil {
+            wg.Add(1)
+            go func(i int, st Stage, in io.Reader, out *os.File) {
+                defer wg.Done()
+                if in == nil { in = bytes.NewReader(nil) }
+                err := st.Filter(out, in)
+                out.Close()
+                // unblock the upstream stage if we stopped reading early; the
+                // caller's Stdin is not ours to close
+                if f, ok := in.(*os.File); ok && i > 0 { f.Close() }
+                results <- stageResult{index: i, err: err}
+            }(i, st, in, out)
+            launched++
+            continue
+        }
+
+        cmd := st.Command(ctx)
+        cmd.Stdin = in
+        cmd.Stdout = out
+        stderr, err := cmd.StderrPipe()
+        if err == nil { err = proc.Start(ctx, cmd) }
+        if err != nil {
+            results <- stageResult{index: i, err: err}
+            launched++
+            cancel()
+            // stages not started never close their ends; do it for them
+            for j := i; j < n; j++ { writers[j].Close() }
+            for j := i; j < n-1; j++ { readers[j].Close() }
+            break
+        }
+        // the child holds its own copies now
+        out.Close()
+        if f, ok := in.(*os.File); ok && i > 0 { f.Close() }
+
+        tails[i] = &lastLine{}
+        wg.Add(1)
+        go func(i int, cmd *os_exec.Cmd, stderr io.Reader) {
+            defer wg.Done()
+            scanLines(stderr, func(line string) {
+                tails[i].set(line)
+                if opts.Stderr != nil { opts.Stderr(p.Stages[i].Name, line) }
+            })
+            results <- stageResult{index: i, err: cmd.Wait()}
+        }(i, cmd, stderr)
+        launched++
+    }
+
+    // collect in completion order; the first real failure tears down the rest
+    var first, fallback *StageError
+    for k := 0; k < launched; k++ {
+        r := <-results
+        if r.err == nil { continue }
+        se := &StageError{Index: r.index, Total: n, Name: p.Stages[r.index].Name, ExitCode: exitCode(r.err), Err: r.err}
+        if tails[r.index] != nil { se.Stderr = tails[r.index].get() }
+        if fallback == nil || se.Index < fallback.Index { fallback = se }
+        if first == nil && !collateral(r.err) && ctx.Err() == nil {
+            first = se
+            cancel()
+        }
+    }
+    wg.Wait()
+    <-outDone
+
+    if first != nil { return first }
+    if fallback != nil { return fallback }
+    return nil
+}
+
+// collateral reports whether err is a stage dying because a neighbour went
+// away (SIGPIPE/EPIPE) rather than failing by itself.
+func collateral(err error) bool {
+    if errors.Is(err, syscall.EPIPE) { return true }
+    var ee *os_exec.ExitError
+    if errors.As(err, &ee) {
+        if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() && ws.Signal() == syscall.SIGPIPE { return true }
+    }
+    return false
+}
+
+func exitCode(err error) int {
+    var ee *os_exec.ExitError
+    if errors.As(err, &ee) { return ee.ExitCode() }
+    return -1
+}
+
+func closeAll(fs ...[]*os.File) {
+    for _, l := range fs {
+        for _, f := range l { if f != nil { f.Close() } }
+    }
+}
+
+// lastLine remembers the most recent stderr line of a stage.
+type lastLine struct {
+    mu   sync.Mutex
+    line string
+}
+
+func (l *lastLine) set(s string) { l.mu.Lock(); l.line = s; l.mu.Unlock() }
+func (l *lastLine) get() string  { l.mu.Lock(); defer l.mu.Unlock(); return l.line }
+
+// scanLines calls fn per line, splitting on \n or \r since progress output
+// redraws a single line with carriage returns. It drains r even when fn is nil.
+func scanLines(r io.Reader, fn func(string)) {
+    s := bufio.NewScanner(r)
+    s.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+    s.Split(scanLinesCR)
+    for s.Scan() { if fn != nil && s.Text() != "" { fn(s.Text()) } }
+    // keep draining after an over-long line so the writer never blocks
+    _, _ = io.Copy(io.Discard, r)
+}
+
+func scanLinesCR(data []byte, atEOF bool) (int, []byte, error) {
+    if atEOF && len(data) == 0 { return 0, nil, nil }
+    if i := bytes.IndexAny(data, "\r\n"); i >= 0 { return i + 1, data[:i], nil }
+    if atEOF { return len(data), data, nil }
+    return 0, nil, nil
+}
+
+// --------------------------- REMOTE QUOTING ---------------------------
+
+// Quote quotes s for a POSIX shell, as ssh hands its command line to the
+// remote user's shell. Plain words are left alone.
+func Quote(s string) string {
+    if s == "" { return "''" }
+    safe := true
+    for _, r := range s {
+        if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@%+=:,./_-", r)) {
+            safe = false
+            break
+        }
+    }
+    if safe { return s }
+    return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Join quotes every argument and joins them into one remote command line.
+func Join(args []string) string {
+    q := make([]string, len(args))
+    for i, a := range args { q[i] = Quote(a) }
+    return strings.Join(q, " ")
+}
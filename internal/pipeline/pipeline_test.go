@@ -0,0 +1,84 @@
+// File: internal/pipeline/pipeline_test.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-17
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Tests of remote quoting (checked against a real sh) and of Run's error
+//   attribution and stdin handling.
+
+package pipeline
+
+import (
+    context "context"
+    errors "errors"
+    io "io"
+    os "os"
+    os_exec "os/exec"
+    strings "strings"
+    testing "testing"
+)
+
+func TestQuote(t *testing.T) {
+    cases := []struct{ in, want string }{
+        {"", "''"},
+        {"plain", "plain"},
+        {"/backups/host/disk.img.zst", "/backups/host/disk.img.zst"},
+        {"user@host:/p", "user@host:/p"},
+        {"--exclude=/dev/*", "'--exclude=/dev/*'"},
+        {"a b", "'a b'"},
+        {"it's", `'it'\''s'`},
+        {"$HOME", "'$HOME'"},
+        {"a;rm -rf /", "'a;rm -rf /'"},
+        {"`x`", "'`x`'"},
+    }
+    for _, c := range cases {
+        if got := Quote(c.in); got != c.want { t.Errorf("Quote(%q) = %s, want %s", c.in, got, c.want) }
+    }
+}
+
+// TestJoinShell checks that a remote shell splits Join's output back into
+// exactly the original arguments.
+func TestJoinShell(t *testing.T) {
+    if _, err := os_exec.LookPath("sh"); err != nil { t.Skip("no sh") }
+    args := []string{"", "plain", "a b", "it's", "$HOME", "*", "a\nb", `back\slash`, `"q"`, "-n"}
+    out, err := os_exec.Command("sh", "-c", "set -- "+Join(args)+`; for a in "$@"; do printf '%s\0' "$a"; done`).Output()
+    if err != nil { t.Fatal(err) }
+    got := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+    if strings.Join(got, "|") != strings.Join(args, "|") || len(got) != len(args) { t.Errorf("sh saw %q, want %q", got, args) }
+}
+
+func TestInsertClamps(t *testing.T) {
+    p := New(Cmd("a"), Cmd("b"))
+    p.Insert(1, Cmd("mid")).Insert(-5, Cmd("first")).Insert(99, Cmd("last"))
+    var names []string
+    for _, s := range p.Stages { names = append(names, s.Name) }
+    if got := strings.Join(names, " "); got != "first a mid b last" { t.Errorf("stages = %s", got) }
+}
+
+func TestRunNamesFailingStage(t *testing.T) {
+    err := New(Cmd("sh", "-c", "echo boom >&2; exit 3"), Cmd("cat")).Run(context.Background(), Options{})
+    var se *StageError
+    if !errors.As(err, &se) { t.Fatalf("err = %v, want a StageError", err) }
+    if se.Index != 0 || se.ExitCode != 3 || se.Stderr != "boom" { t.Errorf("StageError = %+v", se) }
+}
+
+func TestRunBlamesCauseNotBrokenPipe(t *testing.T) {
+    // yes dies of SIGPIPE once sh exits; the error must name sh
+    err := New(Cmd("yes"), Cmd("sh", "-c", "read l; exit 4")).Run(context.Background(), Options{})
+    var se *StageError
+    if !errors.As(err, &se) { t.Fatalf("err = %v, want a StageError", err) }
+    if se.Index != 1 || se.ExitCode != 4 { t.Errorf("blamed stage %d (%s) exit %d, want stage 1 exit 4", se.Index, se.Name, se.ExitCode) }
+}
+
+func TestRunLeavesCallerStdinOpen(t *testing.T) {
+    f, err := os.CreateTemp(t.TempDir(), "in")
+    if err != nil { t.Fatal(err) }
+    defer f.Close()
+    if _, err := f.WriteString("hello\n"); err != nil { t.Fatal(err) }
+    if _, err := f.Seek(0, io.SeekStart); err != nil { t.Fatal(err) }
+    var got []string
+    copyStage := Func("copy", func(dst io.Writer, src io.Reader) error { _, err := io.Copy(dst, src); return err })
+    if err := New(copyStage).Run(context.Background(), Options{Stdin: f, Stdout: func(l string) { got = append(got, l) }}); err != nil { t.Fatal(err) }
+    if len(got) != 1 || got[0] != "hello" { t.Errorf("output = %q", got) }
+    if _, err := f.Seek(0, io.SeekStart); err != nil { t.Errorf("the pipeline closed its caller's stdin: %v", err) }
+}
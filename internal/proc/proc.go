@@ -0,0 +1,114 @@
+// File: internal/proc/proc.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Process-group control for backup pipelines. Every child runs in its own
+//   process group so a shell pipeline (bash -c "pigz | ssh …") can be paused,
+//   resumed or terminated as a unit. Cancelling the command's context sends
+//   SIGTERM to the whole group, then SIGKILL to the leader after KillGrace.
+//
+//   A Group, carried on the context, collects the groups started for one run
+//   so the TUI can SIGSTOP/SIGCONT all of them. Linux/Unix only.
+
+package proc
+
+import (
+    context "context"
+    errors "errors"
+    os_exec "os/exec"
+    sync "sync"
+    syscall "syscall"
+    time "time"
+)
+
+// KillGrace is how long a cancelled process group gets to exit after
+// SIGTERM before the leader is killed and its pipes are closed.
+const KillGrace = 10 * time.Second
+
+// Command is exec.CommandContext with the process placed in its own group
+// and cancellation delivered to the whole group.
+func Command(ctx context.Context, name string, args ...string) *os_exec.Cmd {
+    cmd := os_exec.CommandContext(ctx, name, args...)
+    cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+    cmd.Cancel = func() error {
+        pgid := cmd.Process.Pid
+        err := syscall.Kill(-pgid, syscall.SIGTERM)
+        // a paused group only sees SIGTERM once continued
+        _ = syscall.Kill(-pgid, syscall.SIGCONT)
+        return err
+    }
+    cmd.WaitDelay = KillGrace
+    return cmd
+}
+
+// Start starts cmd and registers its process group with the Group on ctx,
+// if any, so it follows Pause/Resume.
+func Start(ctx context.Context, cmd *os_exec.Cmd) error {
+    if err := cmd.Start(); err != nil { return err }
+    if g := FromContext(ctx); g != nil { g.add(cmd.Process.Pid) }
+    return nil
+}
+
+// Group is the set of process groups belonging to one run.
+type Group struct {
+    mu     sync.Mutex
+    pgids  []int
+    paused bool
+}
+
+func NewGroup() *Group { return &Group{} }
+
+type groupKey struct{}
+
+// WithGroup returns a context carrying g.
+func WithGroup(ctx context.Context, g *Group) context.Context {
+    return context.WithValue(ctx, groupKey{}, g)
+}
+
+// FromContext returns the Group carried by ctx, or nil.
+func FromContext(ctx context.Context) *Group {
+    g, _ := ctx.Value(groupKey{}).(*Group)
+    return g
+}
+
+func (g *Group) add(pgid int) {
+    g.mu.Lock(); defer g.mu.Unlock()
+    g.pgids = append(g.pgids, pgid)
+    // a stage started while paused must not run ahead of the others
+    if g.paused { _ = syscall.Kill(-pgid, syscall.SIGSTOP) }
+}
+
+// Pause stops every process group in the run (SIGSTOP).
+func (g *Group) Pause() error {
+    g.mu.Lock(); defer g.mu.Unlock()
+    g.paused = true
+    return g.signal(syscall.SIGSTOP)
+}
+
+// Resume continues every process group in the run (SIGCONT).
+func (g *Group) Resume() error {
+    g.mu.Lock(); defer g.mu.Unlock()
+    g.paused = false
+    return g.signal(syscall.SIGCONT)
+}
+
+func (g *Group) Paused() bool {
+    g.mu.Lock(); defer g.mu.Unlock()
+    return g.paused
+}
+
+// signal delivers sig to every live group; callers hold g.mu. Groups that
+// already exited are forgotten.
+func (g *Group) signal(sig syscall.Signal) error {
+    var errs []error
+    live := g.pgids[:0]
+    for _, pgid := range g.pgids {
+        err := syscall.Kill(-pgid, sig)
+        if errors.Is(err, syscall.ESRCH) { continue }
+        if err != nil { errs = append(errs, err) }
+        live = append(live, pgid)
+    }
+    g.pgids = live
+    return errors.Join(errs...)
+}
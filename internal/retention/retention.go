@@ -0,0 +1,90 @@
+// File: internal/retention/retention.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Retention policy evaluation, the same rules borg prune uses so a borg
+//   repo and an image directory pruned with one policy keep the same
+//   backups. keep_last keeps the newest N; each period rule walks backups
+//   newest first and keeps the newest one of each day, ISO week, month or
+//   year not already kept, until it has kept N. Periods are local time.
+
+package retention
+
+import (
+    fmt "fmt"
+    sort "sort"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/config"
+)
+
+// Decision is the verdict for one backup. Reason names the rule that kept
+// it, e.g. "daily #2".
+type Decision struct {
+    Keep   bool
+    Reason string
+}
+
+type rule struct {
+    name   string
+    n      int
+    period func(time.Time) string
+}
+
+func rules(p config.Retention) []rule {
+    return []rule{
+        {"last", p.KeepLast, nil},
+        {"daily", p.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") }},
+        {"weekly", p.KeepWeekly, func(t time.Time) string { y, w := t.ISOWeek(); return fmt.Sprintf("%d-W%02d", y, w) }},
+        {"monthly", p.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") }},
+        {"yearly", p.KeepYearly, func(t time.Time) string { return t.Format("2006") }},
+    }
+}
+
+// Apply decides which of the backups created at times to keep under p. The
+// result is indexed like times. Backups with an unknown (zero) time are
+// always kept. An empty policy is an error rather than "delete everything".
+func Apply(p config.Retention, times []time.Time) ([]Decision, error) {
+    if p.IsZero() { return nil, fmt.Errorf("retention: no keep_* rule set") }
+    for _, r := range rules(p) {
+        if r.n < 0 { return nil, fmt.Errorf("retention: keep_%s is negative", r.name) }
+    }
+
+    out := make([]Decision, len(times))
+    var idx []int
+    for i, t := range times {
+        if t.IsZero() { out[i] = Decision{Keep: true, Reason: "unknown time"}; continue }
+        idx = append(idx, i)
+    }
+    // newest first; ties keep their input order
+    sort.SliceStable(idx, func(a, b int) bool { return times[idx[a]].After(times[idx[b]]) })
+
+    for _, r := range rules(p) {
+        kept, last := 0, ""
+        for _, i := range idx {
+            if kept == r.n { break }
+            if r.period != nil {
+                pd := r.period(times[i].Local())
+                if pd == last { continue }
+                last = pd
+            }
+            if out[i].Keep { continue }
+            kept++
+            out[i] = Decision{Keep: true, Reason: fmt.Sprintf("%s #%d", r.name, kept)}
+        }
+    }
+    return out, nil
+}
+
+// String is a one-line summary of p, e.g. "last 3, daily 7, weekly 4".
+func String(p config.Retention) string {
+    s := ""
+    for _, r := range rules(p) {
+        if r.n == 0 { continue }
+        if s != "" { s += ", " }
+        s += fmt.Sprintf("%s %d", r.name, r.n)
+    }
+    if s == "" { return "none" }
+    return s
+}
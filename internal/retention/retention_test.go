@@ -0,0 +1,77 @@
+// File: internal/retention/retention_test.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-17
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Table tests of Apply against borg prune's rules.
+
+package retention
+
+import (
+    strings "strings"
+    testing "testing"
+    time "time"
+
+    "cloudcurio.cc/octobackup/internal/config"
+)
+
+func at(s string) time.Time {
+    t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+    if err != nil { panic(err) }
+    return t
+}
+
+func TestApply(t *testing.T) {
+    days := []time.Time{
+        at("2026-03-01 08:00"), at("2026-03-01 12:00"), at("2026-03-01 18:00"),
+        at("2026-03-02 09:00"), at("2026-03-02 21:00"),
+        at("2026-03-03 18:00"),
+    }
+    cases := []struct {
+        name   string
+        policy config.Retention
+        times  []time.Time
+        want   []string // reason per backup, "" = delete
+    }{
+        {"last", config.Retention{KeepLast: 2}, days,
+            []string{"", "", "", "", "last #2", "last #1"}},
+        {"daily keeps the newest of each day", config.Retention{KeepDaily: 2}, days,
+            []string{"", "", "", "", "daily #2", "daily #1"}},
+        {"a day kept by last still uses up its period", config.Retention{KeepLast: 1, KeepDaily: 2}, days,
+            []string{"", "", "daily #2", "", "daily #1", "last #1"}},
+        {"more rules than backups keeps all", config.Retention{KeepDaily: 30}, days,
+            []string{"", "", "daily #3", "", "daily #2", "daily #1"}},
+        {"ISO weeks across new year", config.Retention{KeepWeekly: 2},
+            []time.Time{at("2020-12-28 10:00"), at("2020-12-31 10:00"), at("2021-01-03 10:00"), at("2021-01-04 10:00")},
+            []string{"", "", "weekly #2", "weekly #1"}},
+        {"monthly and yearly", config.Retention{KeepMonthly: 1, KeepYearly: 2},
+            []time.Time{at("2024-06-01 00:00"), at("2025-02-01 00:00"), at("2025-03-01 00:00")},
+            []string{"yearly #1", "", "monthly #1"}},
+        {"input order does not matter", config.Retention{KeepLast: 1},
+            []time.Time{at("2026-03-03 18:00"), at("2026-03-01 08:00")},
+            []string{"last #1", ""}},
+        {"unknown times are kept", config.Retention{KeepLast: 1},
+            []time.Time{{}, at("2026-03-01 08:00"), at("2026-03-02 08:00")},
+            []string{"unknown time", "", "last #1"}},
+    }
+    for _, c := range cases {
+        ds, err := Apply(c.policy, c.times)
+        if err != nil { t.Errorf("%s: %v", c.name, err); continue }
+        var got []string
+        for _, d := range ds {
+            if d.Keep != (d.Reason != "") { t.Errorf("%s: Keep=%v with reason %q", c.name, d.Keep, d.Reason) }
+            got = append(got, d.Reason)
+        }
+        if strings.Join(got, "|") != strings.Join(c.want, "|") { t.Errorf("%s:\n  got  %q\n  want %q", c.name, got, c.want) }
+    }
+}
+
+func TestApplyRefuses(t *testing.T) {
+    if _, err := Apply(config.Retention{}, []time.Time{at("2026-03-01 08:00")}); err == nil { t.Error("empty policy accepted") }
+    if _, err := Apply(config.Retention{KeepLast: 1, KeepDaily: -1}, nil); err == nil { t.Error("negative rule accepted") }
+}
+
+func TestString(t *testing.T) {
+    if got := String(config.Retention{KeepLast: 3, KeepWeekly: 4}); got != "last 3, weekly 4" { t.Errorf("String = %q", got) }
+    if got := String(config.Retention{}); got != "none" { t.Errorf("String(empty) = %q", got) }
+}
@@ -0,0 +1,240 @@
+// File: internal/schedule/schedule.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Backup schedules with minute resolution, in local time. Three spellings
+//   are accepted:
+//     • cron        "0 3 * * *", "*/30 1-5 * * mon-fri" (5 fields; names,
+//                   ranges, lists and steps; day-of-month and day-of-week
+//                   match either when both are restricted, as in cron)
+//     • shorthand   hourly, daily, weekly, monthly, yearly (or @daily, …)
+//     • calendar    a systemd OnCalendar subset: "[Mon..Fri] [*-*-01] 03:30"
+//                   with weekday lists/ranges, month and day fields, and
+//                   hour:minute fields that may be *, lists or a/step
+//   A parsed Schedule gives the next firing time and renders itself as a
+//   systemd OnCalendar expression, so the daemon and timer units agree.
+
+package schedule
+
+import (
+    fmt "fmt"
+    strconv "strconv"
+    strings "strings"
+    time "time"
+    unicode "unicode"
+)
+
+// Schedule is a set of matching minutes.
+type Schedule struct {
+    spec   string
+    minute uint64 // bits 0-59
+    hour   uint64 // bits 0-23
+    dom    uint64 // bits 1-31
+    month  uint64 // bits 1-12
+    dow    uint64 // bits 0-6, Sunday = 0
+    // domAny/dowAny record an unrestricted cron field, for cron's either-day
+    // rule; a calendar spec keeps domAny set, as systemd ANDs the two
+    domAny, dowAny bool
+}
+
+var (
+    monthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+    dayNames   = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+    dayOrder   = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+)
+
+// shorthands are the systemd meanings of the shorthand words.
+var shorthands = map[string]string{
+    "hourly":   "*-*-* *:00",
+    "daily":    "*-*-* 00:00",
+    "midnight": "*-*-* 00:00",
+    "weekly":   "Mon *-*-* 00:00",
+    "monthly":  "*-*-01 00:00",
+    "yearly":   "*-01-01 00:00",
+    "annually": "*-01-01 00:00",
+}
+
+// Parse reads a cron, shorthand or calendar spec.
+func Parse(spec string) (*Schedule, error) {
+    s := strings.TrimSpace(spec)
+    if s == "" { return nil, fmt.Errorf("schedule: empty") }
+    var (
+        sc  *Schedule
+        err error
+    )
+    if cal, ok := shorthands[strings.ToLower(strings.TrimPrefix(s, "@"))]; ok {
+        sc, err = parseCalendar(cal)
+    } else if f := strings.Fields(s); len(f) == 5 && !strings.Contains(s, ":") {
+        sc, err = parseCron(f)
+    } else {
+        sc, err = parseCalendar(s)
+    }
+    if err != nil { return nil, fmt.Errorf("schedule %q: %w", spec, err) }
+    if sc.Next(time.Now()).IsZero() { return nil, fmt.Errorf("schedule %q never fires", spec) }
+    sc.spec = s
+    return sc, nil
+}
+
+func (s *Schedule) String() string { return s.spec }
+
+// --------------------------- CRON ---------------------------
+
+func parseCron(f []string) (*Schedule, error) {
+    s := &Schedule{}
+    var err error
+    if s.minute, _, err = parseField(f[0], 0, 59, nil); err != nil { return nil, fmt.Errorf("minute: %w", err) }
+    if s.hour, _, err = parseField(f[1], 0, 23, nil); err != nil { return nil, fmt.Errorf("hour: %w", err) }
+    if s.dom, s.domAny, err = parseField(f[2], 1, 31, nil); err != nil { return nil, fmt.Errorf("day of month: %w", err) }
+    if s.month, _, err = parseField(f[3], 1, 12, monthNames); err != nil { return nil, fmt.Errorf("month: %w", err) }
+    // cron allows 7 for Sunday
+    if s.dow, s.dowAny, err = parseField(f[4], 0, 7, dayNames); err != nil { return nil, fmt.Errorf("day of week: %w", err) }
+    if s.dow&(1<<7) != 0 { s.dow = s.dow&^(1<<7) | 1 }
+    return s, nil
+}
+
+// parseField reads a list of *, n, a-b and either with /step into a bitset
+// over [lo, hi]; any reports a bare *. names maps lower-case names to
+// values. Calendar weekdays use ".." for ranges, everything else "-".
+func parseField(field string, lo, hi int, names map[string]int) (bits uint64, any bool, err error) {
+    rangeSep := "-"
+    if strings.Contains(field, "..") { rangeSep = ".." }
+    for _, part := range strings.Split(field, ",") {
+        expr, step := part, 1
+        if i := strings.IndexByte(part, '/'); i >= 0 {
+            expr = part[:i]
+            if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 { return 0, false, fmt.Errorf("bad step in %q", part) }
+        }
+        from, to := lo, hi
+        switch {
+        case expr == "*":
+            any = any || step == 1
+        case strings.Contains(expr, rangeSep):
+            a, b, _ := strings.Cut(expr, rangeSep)
+            if from, err = fieldValue(a, names); err != nil { return 0, false, err }
+            if to, err = fieldValue(b, names); err != nil { return 0, false, err }
+        default:
+            if from, err = fieldValue(expr, names); err != nil { return 0, false, err }
+            // "5/15" means from 5 to the end in steps of 15
+            if step == 1 { to = from }
+        }
+        if from < lo || to > hi || from > to { return 0, false, fmt.Errorf("%q out of range %d-%d", part, lo, hi) }
+        for v := from; v <= to; v += step { bits |= 1 << uint(v) }
+    }
+    return bits, any, nil
+}
+
+func fieldValue(s string, names map[string]int) (int, error) {
+    if v, ok := names[strings.ToLower(s)]; ok { return v, nil }
+    if len(s) > 3 {
+        if v, ok := names[strings.ToLower(s[:3])]; ok { return v, nil }
+    }
+    v, err := strconv.Atoi(s)
+    if err != nil { return 0, fmt.Errorf("bad value %q", s) }
+    return v, nil
+}
+
+// --------------------------- CALENDAR ---------------------------
+
+// parseCalendar reads "[weekdays] [date] time". The date is [year-]month-day
+// with the year, if given, only "*".
+func parseCalendar(spec string) (*Schedule, error) {
+    s := &Schedule{dowAny: true, domAny: true, dow: 0x7f, dom: fieldAll(1, 31), month: fieldAll(1, 12)}
+    f := strings.Fields(spec)
+    if len(f) == 0 || len(f) > 3 { return nil, fmt.Errorf("want [weekdays] [date] time") }
+    var err error
+    if unicode.IsLetter(rune(f[0][0])) {
+        if s.dow, _, err = parseField(f[0], 0, 6, dayNames); err != nil { return nil, fmt.Errorf("weekday: %w", err) }
+        s.dowAny = false
+        f = f[1:]
+    }
+    if len(f) == 2 {
+        d := strings.Split(f[0], "-")
+        if len(d) == 3 {
+            if d[0] != "*" { return nil, fmt.Errorf("specific years are not supported") }
+            d = d[1:]
+        }
+        if len(d) != 2 { return nil, fmt.Errorf("bad date %q", f[0]) }
+        if s.month, _, err = parseField(d[0], 1, 12, nil); err != nil { return nil, fmt.Errorf("month: %w", err) }
+        if s.dom, _, err = parseField(d[1], 1, 31, nil); err != nil { return nil, fmt.Errorf("day: %w", err) }
+        f = f[1:]
+    }
+    if len(f) != 1 { return nil, fmt.Errorf("missing time") }
+    t := strings.Split(f[0], ":")
+    if len(t) == 3 {
+        if t[2] != "00" && t[2] != "0" { return nil, fmt.Errorf("seconds are not supported") }
+        t = t[:2]
+    }
+    if len(t) != 2 { return nil, fmt.Errorf("bad time %q", f[0]) }
+    if s.hour, _, err = parseField(t[0], 0, 23, nil); err != nil { return nil, fmt.Errorf("hour: %w", err) }
+    if s.minute, _, err = parseField(t[1], 0, 59, nil); err != nil { return nil, fmt.Errorf("minute: %w", err) }
+    return s, nil
+}
+
+func fieldAll(lo, hi int) uint64 {
+    var b uint64
+    for v := lo; v <= hi; v++ { b |= 1 << uint(v) }
+    return b
+}
+
+// --------------------------- NEXT ---------------------------
+
+// dayMatches applies cron's rule: when both day fields are restricted a day
+// matching either is enough.
+func (s *Schedule) dayMatches(t time.Time) bool {
+    dom := s.dom&(1<<uint(t.Day())) != 0
+    dow := s.dow&(1<<uint(t.Weekday())) != 0
+    if s.domAny || s.dowAny { return dom && dow }
+    return dom || dow
+}
+
+// Next is the first matching minute strictly after t, or the zero time if
+// none falls within five years (e.g. February 30th).
+func (s *Schedule) Next(t time.Time) time.Time {
+    loc := t.Location()
+    t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+    limit := t.Year() + 5
+    for t.Year() <= limit {
+        switch {
+        case s.month&(1<<uint(t.Month())) == 0:
+            t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+        case !s.dayMatches(t):
+            t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+        case s.hour&(1<<uint(t.Hour())) == 0:
+            t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+        case s.minute&(1<<uint(t.Minute())) == 0:
+            t = t.Add(time.Minute)
+        default:
+            return t
+        }
+    }
+    return time.Time{}
+}
+
+// --------------------------- SYSTEMD ---------------------------
+
+// OnCalendar renders s as systemd OnCalendar expressions, one per
+// OnCalendar= line; the unit fires when any matches.
+func (s *Schedule) OnCalendar() []string {
+    date := "*-" + calField(s.month, 1, 12, "%02d") + "-" + calField(s.dom, 1, 31, "%02d")
+    clock := calField(s.hour, 0, 23, "%02d") + ":" + calField(s.minute, 0, 59, "%02d") + ":00"
+    if s.dowAny { return []string{date + " " + clock} }
+    var days []string
+    for d := 0; d < 7; d++ {
+        if s.dow&(1<<uint(d)) != 0 { days = append(days, dayOrder[d]) }
+    }
+    wd := strings.Join(days, ",")
+    if s.domAny { return []string{wd + " " + date + " " + clock} }
+    // systemd ANDs weekday and date; cron's either-day rule needs two lines
+    return []string{wd + " *-" + calField(s.month, 1, 12, "%02d") + "-* " + clock, date + " " + clock}
+}
+
+// calField renders a bitset as *, a single value or a comma list.
+func calField(bits uint64, lo, hi int, format string) string {
+    if bits == fieldAll(lo, hi) { return "*" }
+    var vs []string
+    for v := lo; v <= hi; v++ {
+        if bits&(1<<uint(v)) != 0 { vs = append(vs, fmt.Sprintf(format, v)) }
+    }
+    return strings.Join(vs, ",")
+}
@@ -0,0 +1,119 @@
+// File: internal/schedule/schedule_test.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-17
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Table tests of the three spellings: the next firing time from a fixed
+//   Saturday noon, the OnCalendar rendering the timer units use, and the
+//   specs Parse refuses.
+
+package schedule
+
+import (
+    strings "strings"
+    testing "testing"
+    time "time"
+)
+
+// from is Saturday 2026-10-17 12:34:56 UTC.
+var from = time.Date(2026, 10, 17, 12, 34, 56, 0, time.UTC)
+
+func at(s string) time.Time {
+    t, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+    if err != nil { panic(err) }
+    return t
+}
+
+func TestNext(t *testing.T) {
+    cases := []struct {
+        spec string
+        want string
+    }{
+        {"0 3 * * *", "2026-10-18 03:00"},
+        {"*/30 * * * *", "2026-10-17 13:00"},
+        {"5/15 * * * *", "2026-10-17 12:35"},
+        {"0 3 * * mon-fri", "2026-10-19 03:00"},
+        {"0 9 * * 7", "2026-10-18 09:00"},
+        {"0 0 1 jan *", "2027-01-01 00:00"},
+        // either-day rule: the 1st or a Monday, whichever comes first
+        {"0 3 1 * mon", "2026-10-19 03:00"},
+        {"0 3 1 * sun", "2026-10-18 03:00"},
+        {"0 3 1 * wed", "2026-10-21 03:00"},
+        {"hourly", "2026-10-17 13:00"},
+        {"@daily", "2026-10-18 00:00"},
+        {"midnight", "2026-10-18 00:00"},
+        {"weekly", "2026-10-19 00:00"},
+        {"monthly", "2026-11-01 00:00"},
+        {"Yearly", "2027-01-01 00:00"},
+        {"Mon..Fri 02:30", "2026-10-19 02:30"},
+        {"Sat 13:00", "2026-10-17 13:00"},
+        {"Saturday 12:34", "2026-10-24 12:34"},
+        {"12:34:00", "2026-10-18 12:34"},
+        {"*:0/15", "2026-10-17 12:45"},
+        {"*-*-01 03:30", "2026-11-01 03:30"},
+        {"*-02-29 00:00", "2028-02-29 00:00"},
+        // a calendar weekday and date must both match, unlike cron
+        {"Mon *-*-01 03:00", "2027-02-01 03:00"},
+    }
+    for _, c := range cases {
+        s, err := Parse(c.spec)
+        if err != nil { t.Errorf("Parse(%q): %v", c.spec, err); continue }
+        if got := s.Next(from); !got.Equal(at(c.want)) { t.Errorf("%q: Next = %s, want %s", c.spec, got.Format("Mon 2006-01-02 15:04"), c.want) }
+    }
+}
+
+func TestNextIsStrictlyAfter(t *testing.T) {
+    s, err := Parse("0 3 * * *")
+    if err != nil { t.Fatal(err) }
+    if got := s.Next(at("2026-10-17 03:00")); !got.Equal(at("2026-10-18 03:00")) { t.Errorf("Next = %s", got) }
+}
+
+func TestOnCalendar(t *testing.T) {
+    cases := []struct {
+        spec string
+        want string // lines joined by " | "
+    }{
+        {"0 3 * * *", "*-*-* 03:00:00"},
+        {"*/30 1-2 * * *", "*-*-* 01,02:00,30:00"},
+        {"0 0 1 1,7 *", "*-01,07-01 00:00:00"},
+        {"0 3 * * 0,7", "Sun *-*-* 03:00:00"},
+        {"Mon..Fri 02:30", "Mon,Tue,Wed,Thu,Fri *-*-* 02:30:00"},
+        {"weekly", "Mon *-*-* 00:00:00"},
+        {"monthly", "*-*-01 00:00:00"},
+        {"Mon *-*-01 03:00", "Mon *-*-01 03:00:00"},
+        {"0 3 1 * mon", "Mon *-*-* 03:00:00 | *-*-01 03:00:00"},
+        {"0 3 1 6 mon", "Mon *-06-* 03:00:00 | *-06-01 03:00:00"},
+    }
+    for _, c := range cases {
+        s, err := Parse(c.spec)
+        if err != nil { t.Errorf("Parse(%q): %v", c.spec, err); continue }
+        if got := strings.Join(s.OnCalendar(), " | "); got != c.want { t.Errorf("%q: OnCalendar = %s, want %s", c.spec, got, c.want) }
+    }
+}
+
+func TestParseRefuses(t *testing.T) {
+    cases := map[string]string{
+        "empty":             "  ",
+        "four cron fields":  "0 3 * *",
+        "minute 60":         "60 * * * *",
+        "hour 24":           "0 24 * * *",
+        "zero step":         "*/0 * * * *",
+        "reversed range":    "0 5-3 * * *",
+        "unknown day":       "Funday 03:00",
+        "seconds":           "*-*-* 03:00:30",
+        "specific year":     "2026-01-01 03:00",
+        "bad date":          "*-*-5-3 03:00",
+        "no time":           "Mon",
+        "bad time":          "03",
+        "never fires":       "*-02-30 00:00",
+    }
+    for name, spec := range cases {
+        if s, err := Parse(spec); err == nil { t.Errorf("%s: Parse(%q) = %v", name, spec, s.OnCalendar()) }
+    }
+}
+
+func TestString(t *testing.T) {
+    s, err := Parse("  @daily ")
+    if err != nil { t.Fatal(err) }
+    if s.String() != "@daily" { t.Errorf("String = %q", s.String()) }
+}
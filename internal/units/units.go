@@ -0,0 +1,155 @@
+// File: internal/units/units.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-16
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   systemd units for one backup job: octobackup-<job>.service runs the
+//   backup once at low CPU and I/O priority, octobackup-<job>.timer fires it
+//   on the job's schedule (Persistent=true catches up missed runs), and the
+//   octobackup-<job>-failure@.service template is its OnFailure= hook, one
+//   per job so each runs its own job's on_failure command.
+//
+//   Secrets are passed with LoadCredential=: octobackup finds them in
+//   $CREDENTIALS_DIRECTORY under the env var name the config gives, so no
+//   unit file or environment carries them.
+//
+//   Render is pure: it returns the file names and contents for Options and
+//   touches nothing, so the output can be checked or printed as is.
+
+package units
+
+import (
+    fmt "fmt"
+    strings "strings"
+    time "time"
+)
+
+// LegacyFailureUnit is the OnFailure= template once shared by every job;
+// uninstall removes it with the last job.
+const LegacyFailureUnit = "octobackup-failure@.service"
+
+// Credential is a secret loaded into the service: Name is the env var name
+// the config refers to, Path the file holding it.
+type Credential struct {
+    Name string
+    Path string
+}
+
+// Options describes one job's units.
+type Options struct {
+    Job         string
+    Binary      string        // absolute path of octobackup
+    Config      string        // config file the service passes with --config
+    RunArgs     []string      // extra arguments after "run", e.g. the job name
+    Calendar    []string      // OnCalendar= expressions
+    Jitter      time.Duration // RandomizedDelaySec=
+    User        bool          // a --user unit: no system-only settings
+    Nice        int
+    IOClass     string // realtime | best-effort | idle
+    IOPriority  int    // 0 (highest) - 7
+    Credentials []Credential
+    OnFailure   string // shell command run with the failed unit as $1; "" logs it
+}
+
+// File is one rendered unit.
+type File struct {
+    Name    string
+    Content string
+}
+
+// Name is the base name of a job's units, without the suffix.
+func Name(job string) string { return "octobackup-" + job }
+
+// FailureName is the file name of a job's OnFailure= template.
+func FailureName(job string) string { return Name(job) + "-failure@.service" }
+
+// Render returns the service, timer and failure template for o.
+func Render(o Options) ([]File, error) {
+    if o.Job == "" || strings.ContainsAny(o.Job, "/ \t\n@") { return nil, fmt.Errorf("bad job name %q", o.Job) }
+    if !strings.HasPrefix(o.Binary, "/") { return nil, fmt.Errorf("binary path %q is not absolute", o.Binary) }
+    if len(o.Calendar) == 0 { return nil, fmt.Errorf("job %s has no schedule", o.Job) }
+    for _, c := range o.Credentials {
+        if c.Name == "" || strings.ContainsAny(c.Name, ":/ ") { return nil, fmt.Errorf("bad credential name %q", c.Name) }
+    }
+    return []File{
+        {Name(o.Job) + ".service", service(o)},
+        {Name(o.Job) + ".timer", timer(o)},
+        {FailureName(o.Job), failure(o)},
+    }, nil
+}
+
+func service(o Options) string {
+    var b strings.Builder
+    argv := append([]string{o.Binary, "run"}, o.RunArgs...)
+    if o.Config != "" { argv = append(argv, "--config", o.Config) }
+
+    fmt.Fprintf(&b, "# generated by octobackup install-timer %s; changes are overwritten\n", o.Job)
+    fmt.Fprintf(&b, "[Unit]\n")
+    fmt.Fprintf(&b, "Description=OctoBackup job %s\n", o.Job)
+    if !o.User {
+        fmt.Fprintf(&b, "After=network-online.target\n")
+        fmt.Fprintf(&b, "Wants=network-online.target\n")
+    }
+    fmt.Fprintf(&b, "OnFailure=%s\n", strings.Replace(FailureName(o.Job), "@", "@%n", 1))
+    fmt.Fprintf(&b, "\n[Service]\n")
+    fmt.Fprintf(&b, "Type=oneshot\n")
+    fmt.Fprintf(&b, "ExecStart=%s\n", execLine(argv))
+    fmt.Fprintf(&b, "Nice=%d\n", o.Nice)
+    if o.IOClass != "" {
+        fmt.Fprintf(&b, "IOSchedulingClass=%s\n", o.IOClass)
+        fmt.Fprintf(&b, "IOSchedulingPriority=%d\n", o.IOPriority)
+    }
+    for _, c := range o.Credentials {
+        fmt.Fprintf(&b, "LoadCredential=%s:%s\n", c.Name, escape(c.Path))
+    }
+    // give the backup time to clean up partial files after SIGTERM
+    fmt.Fprintf(&b, "TimeoutStopSec=3min\n")
+    return b.String()
+}
+
+func timer(o Options) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "# generated by octobackup install-timer %s; changes are overwritten\n", o.Job)
+    fmt.Fprintf(&b, "[Unit]\n")
+    fmt.Fprintf(&b, "Description=OctoBackup job %s schedule\n", o.Job)
+    fmt.Fprintf(&b, "\n[Timer]\n")
+    for _, c := range o.Calendar { fmt.Fprintf(&b, "OnCalendar=%s\n", c) }
+    fmt.Fprintf(&b, "Persistent=true\n")
+    if o.Jitter > 0 { fmt.Fprintf(&b, "RandomizedDelaySec=%d\n", int(o.Jitter.Seconds())) }
+    fmt.Fprintf(&b, "AccuracySec=1min\n")
+    fmt.Fprintf(&b, "\n[Install]\n")
+    fmt.Fprintf(&b, "WantedBy=timers.target\n")
+    return b.String()
+}
+
+func failure(o Options) string {
+    cmd := o.OnFailure
+    if cmd == "" { cmd = `logger -p user.err -t octobackup "$1 failed; see journalctl -u $1"` }
+    var b strings.Builder
+    fmt.Fprintf(&b, "# generated by octobackup install-timer %s; changes are overwritten\n", o.Job)
+    fmt.Fprintf(&b, "[Unit]\n")
+    fmt.Fprintf(&b, "Description=OctoBackup job %s failure hook for %%i\n", o.Job)
+    fmt.Fprintf(&b, "\n[Service]\n")
+    fmt.Fprintf(&b, "Type=oneshot\n")
+    // %i is expanded by systemd after quoting, so it arrives as $1
+    fmt.Fprintf(&b, "ExecStart=%s %%i\n", execLine([]string{"/bin/sh", "-c", cmd, "sh"}))
+    return b.String()
+}
+
+// execLine quotes argv for ExecStart=: words with blanks or quotes are
+// double-quoted, and % and $ are escaped so systemd passes them through.
+func execLine(argv []string) string {
+    out := make([]string, len(argv))
+    for i, a := range argv {
+        a = escape(a)
+        a = strings.ReplaceAll(a, "$", "$$")
+        if a == "" || strings.ContainsAny(a, " \t\"'\\;") {
+            a = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(a) + `"`
+        }
+        out[i] = a
+    }
+    return strings.Join(out, " ")
+}
+
+// escape protects % from specifier expansion.
+func escape(s string) string { return strings.ReplaceAll(s, "%", "%%") }
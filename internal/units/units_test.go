@@ -0,0 +1,123 @@
+// File: internal/units/units_test.go
+// Author: cbwinslow <blaine.winslow@gmail.com>
+// Date: 2026-10-17
+// Project: CloudCurio — OctoBackup (Neon Octopus Edition)
+// Summary:
+//   Golden tests of the rendered units: each case's files are compared with
+//   testdata/<case>.golden. go test ./internal/units -update rewrites them
+//   after an intended change; review the diff before committing it.
+
+package units
+
+import (
+    flag "flag"
+    os "os"
+    path_file "path/filepath"
+    strings "strings"
+    testing "testing"
+    time "time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestRenderGolden(t *testing.T) {
+    base := Options{
+        Job:        "home",
+        Binary:     "/usr/local/bin/octobackup",
+        Config:     "/etc/octobackup/octobackup.yaml",
+        RunArgs:    []string{"home"},
+        Calendar:   []string{"*-*-* 03:00:00"},
+        Nice:       10,
+        IOClass:    "best-effort",
+        IOPriority: 7,
+    }
+    cases := map[string]func(o *Options){
+        "system": func(o *Options) {},
+        "user": func(o *Options) {
+            o.User = true
+            o.Config = "/home/me/.config/cloudcurio/octobackup.yaml"
+            o.Jitter = 10 * time.Minute
+            o.Calendar = []string{"Mon..Fri 02:30", "Sat 06:00"}
+        },
+        "credentials": func(o *Options) {
+            o.Credentials = []Credential{
+                {Name: "BORG_PASSPHRASE", Path: "/etc/octobackup/credentials/BORG_PASSPHRASE"},
+                {Name: "AGE_KEY", Path: "/etc/octobackup/100%/AGE_KEY"},
+            }
+        },
+        "on-failure": func(o *Options) {
+            o.OnFailure = `curl -fsS -d "$1 failed at 100%" https://ntfy.example/backups`
+        },
+        "escaping": func(o *Options) {
+            o.Job = "odd"
+            o.RunArgs = []string{"odd"}
+            o.Config = "/srv/my configs/50%$HOME.yaml"
+            o.IOClass = ""
+        },
+    }
+    for name, tweak := range cases {
+        t.Run(name, func(t *testing.T) {
+            o := base
+            tweak(&o)
+            files, err := Render(o)
+            if err != nil { t.Fatal(err) }
+            var b strings.Builder
+            for _, f := range files { b.WriteString("# " + f.Name + "\n" + f.Content + "\n") }
+            golden := path_file.Join("testdata", name+".golden")
+            if *update {
+                if err := os.WriteFile(golden, []byte(b.String()), 0o644); err != nil { t.Fatal(err) }
+                return
+            }
+            want, err := os.ReadFile(golden)
+            if err != nil { t.Fatalf("%v (run with -update to create it)", err) }
+            if b.String() != string(want) { t.Errorf("rendered units differ from %s:\n%s", golden, b.String()) }
+        })
+    }
+}
+
+func TestRenderNames(t *testing.T) {
+    files, err := Render(Options{Job: "disk", Binary: "/bin/octobackup", Calendar: []string{"daily"}})
+    if err != nil { t.Fatal(err) }
+    var names []string
+    for _, f := range files { names = append(names, f.Name) }
+    want := "octobackup-disk.service octobackup-disk.timer octobackup-disk-failure@.service"
+    if got := strings.Join(names, " "); got != want { t.Errorf("files = %s, want %s", got, want) }
+    if !strings.Contains(files[0].Content, "OnFailure=octobackup-disk-failure@%n.service\n") { t.Errorf("service does not name its own hook:\n%s", files[0].Content) }
+}
+
+func TestRenderRefuses(t *testing.T) {
+    ok := Options{Job: "home", Binary: "/bin/octobackup", Calendar: []string{"daily"}}
+    cases := map[string]func(o *Options){
+        "no job":         func(o *Options) { o.Job = "" },
+        "slash in job":   func(o *Options) { o.Job = "a/b" },
+        "@ in job":       func(o *Options) { o.Job = "a@b" },
+        "relative bin":   func(o *Options) { o.Binary = "octobackup" },
+        "no schedule":    func(o *Options) { o.Calendar = nil },
+        "bad credential": func(o *Options) { o.Credentials = []Credential{{Name: "A:B", Path: "/x"}} },
+    }
+    for name, tweak := range cases {
+        o := ok
+        tweak(&o)
+        if _, err := Render(o); err == nil { t.Errorf("%s: rendered", name) }
+    }
+}
+
+func TestExecLine(t *testing.T) {
+    cases := []struct {
+        argv []string
+        want string
+    }{
+        {[]string{"/bin/octobackup", "run"}, `/bin/octobackup run`},
+        {[]string{"a b"}, `"a b"`},
+        {[]string{""}, `""`},
+        {[]string{"100%"}, `100%%`},
+        {[]string{"$HOME"}, `$$HOME`},
+        {[]string{`say "hi"`}, `"say \"hi\""`},
+        {[]string{`back\slash`}, `"back\\slash"`},
+        {[]string{"a;b"}, `"a;b"`},
+        {[]string{"50% of $X y"}, `"50%% of $$X y"`},
+    }
+    for _, c := range cases {
+        if got := execLine(c.argv); got != c.want { t.Errorf("execLine(%q) = %s, want %s", c.argv, got, c.want) }
+    }
+}